@@ -0,0 +1,167 @@
+package abtests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+var ErrTestNotFound = errors.New("subject test not found")
+
+// InsertSubjectTest stores the test of an issue, the winner is picked once
+// the window has passed. The window of scheduled issues starts at their
+// send time.
+func InsertSubjectTest(c context.Context, tx pgx.Tx, test *models.SubjectTest, schedule *models.Schedule) (err error) {
+	var start *time.Time
+	if schedule != nil {
+		instant := schedule.Instant()
+		start = &instant
+	}
+
+	query := `INSERT INTO subject_tests (newsletter_issue_id, sample_percent, metric, decide_after)
+			VALUES ($1, $2, $3, greatest(now(), $5::timestamptz) + make_interval(hours => $4))`
+	_, e := tx.Exec(c, query, test.IssueID, test.SamplePercent, test.Metric, test.WindowHours, start)
+	if e != nil {
+		err = fmt.Errorf("failed to insert subject test: %w", e)
+		return
+	}
+
+	for _, variant := range test.Variants {
+		query = "INSERT INTO subject_variants (newsletter_issue_id, variant, subject) VALUES ($1, $2, $3)"
+		if _, e := tx.Exec(c, query, test.IssueID, variant.Variant, variant.Subject); e != nil {
+			err = fmt.Errorf("failed to insert subject variant %d: %w", variant.Variant, e)
+			return
+		}
+	}
+
+	return
+}
+
+// GetSubject returns the subject line of a variant
+func GetSubject(c context.Context, tx pgx.Tx, issueID string, variant int) (subject string, err error) {
+	query := "SELECT subject FROM subject_variants WHERE newsletter_issue_id = $1 AND variant = $2"
+	if e := tx.QueryRow(c, query, issueID, variant).Scan(&subject); e != nil {
+		err = fmt.Errorf("failed to fetch subject variant %d: %w", variant, e)
+		return
+	}
+
+	return
+}
+
+// GetSubjectTest returns the test with the results of each variant. Only
+// deliveries sent before the winner was picked are counted.
+func GetSubjectTest(c context.Context, db handlers.DatabaseInterface, issueID string) (test *models.SubjectTest, err error) {
+	if _, e := uuid.Parse(issueID); e != nil {
+		err = ErrTestNotFound
+		return
+	}
+
+	test = &models.SubjectTest{IssueID: issueID}
+
+	query := `SELECT sample_percent, metric, decide_after, winner, decided
+			FROM subject_tests
+			WHERE newsletter_issue_id = $1`
+	e := db.QueryRow(c, query, issueID).Scan(&test.SamplePercent, &test.Metric, &test.DecideAfter, &test.Winner, &test.Decided)
+	if e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrTestNotFound
+			return
+		}
+
+		err = fmt.Errorf("failed to fetch subject test: %w", e)
+		return
+	}
+
+	query = `SELECT subject_variants.variant, subject_variants.subject,
+				count(delivery_log.delivery_id),
+				count(delivery_log.delivery_id) FILTER (WHERE EXISTS (
+					SELECT 1 FROM engagement_events
+					WHERE engagement_events.delivery_id = delivery_log.delivery_id
+					AND ($2 = 'open' OR engagement_events.type = 'click')
+				))
+			FROM subject_variants
+			JOIN subject_tests ON subject_tests.newsletter_issue_id = subject_variants.newsletter_issue_id
+			LEFT JOIN delivery_log ON delivery_log.newsletter_issue_id = subject_variants.newsletter_issue_id
+				AND delivery_log.variant = subject_variants.variant
+				AND (subject_tests.decided IS NULL OR delivery_log.sent <= subject_tests.decided)
+			WHERE subject_variants.newsletter_issue_id = $1
+			GROUP BY subject_variants.variant, subject_variants.subject
+			ORDER BY subject_variants.variant`
+	rows, e := db.Query(c, query, issueID, test.Metric)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch subject variants: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	test.Variants, e = pgx.CollectRows[*models.SubjectVariant](rows, buildVariant)
+	if e != nil {
+		err = fmt.Errorf("failed to parse subject variants: %w", e)
+		return
+	}
+
+	// rates are filled in for display, the stored winner is kept
+	test.PickWinner()
+	return
+}
+
+// DueTests returns the undecided tests whose window has passed, locking
+// them for the transaction
+func DueTests(c context.Context, tx pgx.Tx) (issueIDs []string, err error) {
+	query := `SELECT newsletter_issue_id
+			FROM subject_tests
+			WHERE winner IS NULL AND decide_after <= now()
+			FOR UPDATE
+			SKIP LOCKED`
+	rows, e := tx.Query(c, query)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch due subject tests: %w", e)
+		return
+	}
+
+	issueIDs, e = pgx.CollectRows(rows, pgx.RowTo[string])
+	if e != nil {
+		err = fmt.Errorf("failed to parse due subject tests: %w", e)
+		return
+	}
+
+	return
+}
+
+// Decide stores the winner and releases the held back recipients with its
+// subject line
+func Decide(c context.Context, tx pgx.Tx, issueID string, winner int) (released int, err error) {
+	query := "UPDATE subject_tests SET winner = $2, decided = now() WHERE newsletter_issue_id = $1"
+	if _, e := tx.Exec(c, query, issueID, winner); e != nil {
+		err = fmt.Errorf("failed to store subject test winner: %w", e)
+		return
+	}
+
+	query = `UPDATE issue_delivery_queue SET variant = $2, execute_after = now()
+			WHERE newsletter_issue_id = $1 AND variant IS NULL`
+	tag, e := tx.Exec(c, query, issueID, winner)
+	if e != nil {
+		err = fmt.Errorf("failed to release held back recipients: %w", e)
+		return
+	}
+
+	released = int(tag.RowsAffected())
+	return
+}
+
+func buildVariant(row pgx.CollectableRow) (variant *models.SubjectVariant, err error) {
+	variant = &models.SubjectVariant{}
+	if e := row.Scan(&variant.Variant, &variant.Subject, &variant.Sent, &variant.Engaged); e != nil {
+		err = fmt.Errorf("failed to scan subject variant: %w", e)
+		return
+	}
+
+	return
+}
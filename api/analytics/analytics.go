@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+var ErrIssueNotFound = errors.New("issue not found")
+
+// GetIssueAnalytics aggregates the delivery log, bounces, engagement and
+// unsubscribe events of the issue
+func GetIssueAnalytics(c context.Context, db handlers.DatabaseInterface, issueID string) (analytics *models.IssueAnalytics, err error) {
+	if _, e := uuid.Parse(issueID); e != nil {
+		err = ErrIssueNotFound
+		return
+	}
+
+	analytics = &models.IssueAnalytics{IssueID: issueID}
+	query := `SELECT title,
+				(SELECT count(*) FROM delivery_log WHERE newsletter_issue_id = $1),
+				(SELECT count(DISTINCT delivery_id) FROM bounces WHERE newsletter_issue_id = $1),
+				(SELECT count(DISTINCT delivery_id) FROM engagement_events WHERE newsletter_issue_id = $1),
+				(SELECT count(DISTINCT delivery_id) FROM engagement_events WHERE newsletter_issue_id = $1 AND type = 'click'),
+				(SELECT count(*) FROM unsubscribe_events WHERE newsletter_issue_id = $1)
+			FROM newsletter_issues
+			WHERE newsletter_issue_id = $1`
+	e := db.QueryRow(c, query, issueID).Scan(
+		&analytics.Title,
+		&analytics.Sent,
+		&analytics.Bounced,
+		&analytics.UniqueOpens,
+		&analytics.UniqueClicks,
+		&analytics.Unsubscribes,
+	)
+	if e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrIssueNotFound
+			return
+		}
+
+		err = fmt.Errorf("failed to fetch issue summary: %w", e)
+		return
+	}
+	analytics.Rates()
+
+	query = `SELECT url, count(*), count(DISTINCT delivery_id)
+			FROM engagement_events
+			WHERE newsletter_issue_id = $1 AND type = 'click'
+			GROUP BY url
+			ORDER BY 2 DESC, url`
+	rows, e := db.Query(c, query, issueID)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch link clicks: %w", e)
+		return
+	}
+	if analytics.Links, e = pgx.CollectRows(rows, buildLinkClicks); e != nil {
+		err = fmt.Errorf("failed to parse link clicks: %w", e)
+		return
+	}
+
+	// a click counts as an open, so the series is built from the first
+	// event of each delivery
+	query = `SELECT date_trunc('hour', first_open), count(*)
+			FROM (
+				SELECT min(created) AS first_open
+				FROM engagement_events
+				WHERE newsletter_issue_id = $1
+				GROUP BY delivery_id
+			) AS first_opens
+			GROUP BY 1
+			ORDER BY 1`
+	rows, e = db.Query(c, query, issueID)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch opens: %w", e)
+		return
+	}
+	if analytics.Opens, e = pgx.CollectRows(rows, buildOpenBucket); e != nil {
+		err = fmt.Errorf("failed to parse opens: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildLinkClicks(row pgx.CollectableRow) (link *models.LinkClicks, err error) {
+	link = &models.LinkClicks{}
+	if e := row.Scan(&link.URL, &link.Clicks, &link.UniqueClicks); e != nil {
+		err = fmt.Errorf("failed to scan link clicks: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildOpenBucket(row pgx.CollectableRow) (bucket *models.OpenBucket, err error) {
+	bucket = &models.OpenBucket{}
+	if e := row.Scan(&bucket.Hour, &bucket.Opens); e != nil {
+		err = fmt.Errorf("failed to scan opens: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,55 @@
+package attachments
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+// issues are delivered one after another, a handful covers issues and
+// sequence steps being sent side by side
+const cacheSize = 8
+
+// Cache keeps the attachments of recently delivered issues in memory so they
+// are read from the database and disk once per issue rather than once per
+// recipient. Attachments are stored with their issue and never change, so
+// entries are only evicted to bound memory.
+type Cache struct {
+	mu      sync.Mutex
+	issues  map[string][]*models.Attachment
+	evictAt []string
+}
+
+func NewCache() *Cache {
+	return &Cache{issues: map[string][]*models.Attachment{}}
+}
+
+// Get returns the attachments of issueID, a nil cache always loads them
+func (cache *Cache) Get(c context.Context, tx pgx.Tx, issueID string) (attachments []*models.Attachment, err error) {
+	if cache == nil {
+		return GetAttachments(c, tx, issueID)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	attachments, ok := cache.issues[issueID]
+	if ok {
+		return
+	}
+
+	if attachments, err = GetAttachments(c, tx, issueID); err != nil {
+		return
+	}
+
+	if len(cache.evictAt) == cacheSize {
+		delete(cache.issues, cache.evictAt[0])
+		cache.evictAt = cache.evictAt[1:]
+	}
+	cache.issues[issueID] = attachments
+	cache.evictAt = append(cache.evictAt, issueID)
+
+	return
+}
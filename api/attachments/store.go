@@ -0,0 +1,146 @@
+package attachments
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+func InsertAttachments(c context.Context, tx pgx.Tx, issueID string, attachments []*models.Attachment, cfg *configs.AttachmentSettings) (err error) {
+	query := `INSERT INTO newsletter_attachments (
+				attachment_id,
+				newsletter_issue_id,
+				filename,
+				content_type,
+				content_id,
+				inline,
+				size,
+				content,
+				path,
+				created
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())`
+
+	for _, attachment := range attachments {
+		attachment.ID = uuid.NewString()
+
+		var contentID *string
+		if attachment.Inline {
+			contentID = &attachment.ContentID
+		}
+
+		var content []byte
+		var path *string
+		switch cfg.Storage {
+		case configs.AttachmentStorageLocal:
+			p, e := writeFile(cfg.Directory, attachment)
+			if e != nil {
+				err = fmt.Errorf("failed to store attachment %s: %w", attachment.Filename, e)
+				return
+			}
+			path = &p
+		default:
+			content = attachment.Content
+		}
+
+		_, e := tx.Exec(
+			c, query,
+			attachment.ID,
+			issueID,
+			attachment.Filename,
+			attachment.ContentType,
+			contentID,
+			attachment.Inline,
+			len(attachment.Content),
+			content,
+			path,
+		)
+		if e != nil {
+			err = fmt.Errorf("failed to insert attachment %s: %w", attachment.Filename, e)
+			return
+		}
+	}
+
+	return
+}
+
+func GetAttachments(c context.Context, tx pgx.Tx, issueID string) (attachments []*models.Attachment, err error) {
+	query := `SELECT attachment_id, filename, content_type, content_id, inline, content, path
+			FROM newsletter_attachments
+			WHERE newsletter_issue_id = $1
+			ORDER BY created`
+	rows, e := tx.Query(c, query, issueID)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch attachments: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	attachments, e = pgx.CollectRows[*models.Attachment](rows, buildAttachment)
+	if e != nil {
+		err = fmt.Errorf("failed to parse attachments: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildAttachment(row pgx.CollectableRow) (attachment *models.Attachment, err error) {
+	var contentID *string
+	var path *string
+
+	attachment = &models.Attachment{}
+	e := row.Scan(
+		&attachment.ID,
+		&attachment.Filename,
+		&attachment.ContentType,
+		&contentID,
+		&attachment.Inline,
+		&attachment.Content,
+		&path,
+	)
+	if e != nil {
+		err = fmt.Errorf("database error: %w", e)
+		return
+	}
+
+	if contentID != nil {
+		attachment.ContentID = *contentID
+	}
+	if path != nil {
+		attachment.Content, e = os.ReadFile(*path)
+		if e != nil {
+			err = fmt.Errorf("failed to read attachment %s: %w", attachment.Filename, e)
+			return
+		}
+	}
+
+	return
+}
+
+// files are stored under their attachment ID, never the client supplied filename
+func writeFile(directory string, attachment *models.Attachment) (path string, err error) {
+	if e := os.MkdirAll(directory, 0o700); e != nil {
+		err = fmt.Errorf("failed to create attachment directory: %w", e)
+		return
+	}
+
+	path, e := filepath.Abs(filepath.Join(directory, attachment.ID))
+	if e != nil {
+		err = fmt.Errorf("failed to resolve attachment path: %w", e)
+		return
+	}
+
+	if e := os.WriteFile(path, attachment.Content, 0o600); e != nil {
+		err = fmt.Errorf("failed to write attachment: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,133 @@
+package attachments
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const (
+	AttachmentField = "attachments"
+	InlineField     = "inline_images"
+)
+
+func ParseUploads(form *multipart.Form, cfg *configs.AttachmentSettings) (attachments []*models.Attachment, err error) {
+	if form == nil {
+		return
+	}
+
+	var parsed []*models.Attachment
+	var total int64
+	contentIDs := make(map[string]bool)
+	for _, field := range []string{AttachmentField, InlineField} {
+		inline := field == InlineField
+		for _, header := range form.File[field] {
+			attachment, e := ParseUpload(header, inline, cfg)
+			if e != nil {
+				err = fmt.Errorf("invalid upload %s: %w", header.Filename, e)
+				return
+			}
+
+			total += int64(len(attachment.Content))
+			if total > cfg.MaxTotalSize {
+				err = fmt.Errorf("uploads exceed maximum total size of: %d bytes", cfg.MaxTotalSize)
+				return
+			}
+
+			if inline {
+				if contentIDs[attachment.ContentID] {
+					err = fmt.Errorf("duplicate inline image: %s", attachment.ContentID)
+					return
+				}
+				contentIDs[attachment.ContentID] = true
+			}
+
+			parsed = append(parsed, attachment)
+		}
+	}
+
+	attachments = parsed
+	return
+}
+
+func ParseUpload(header *multipart.FileHeader, inline bool, cfg *configs.AttachmentSettings) (attachment *models.Attachment, err error) {
+	filename, e := models.ParseFilename(header.Filename)
+	if e != nil {
+		err = fmt.Errorf("invalid filename: %w", e)
+		return
+	}
+
+	if header.Size > cfg.MaxFileSize {
+		err = fmt.Errorf("file exceeds maximum size of: %d bytes", cfg.MaxFileSize)
+		return
+	}
+
+	file, e := header.Open()
+	if e != nil {
+		err = fmt.Errorf("failed to open upload: %w", e)
+		return
+	}
+	defer file.Close()
+
+	// never trust the declared size
+	content, e := io.ReadAll(io.LimitReader(file, cfg.MaxFileSize+1))
+	if e != nil {
+		err = fmt.Errorf("failed to read upload: %w", e)
+		return
+	}
+	if int64(len(content)) > cfg.MaxFileSize {
+		err = fmt.Errorf("file exceeds maximum size of: %d bytes", cfg.MaxFileSize)
+		return
+	}
+	if len(content) == 0 {
+		err = errors.New("file cannot be empty")
+		return
+	}
+
+	contentType, e := ParseContentType(content, cfg.AllowedTypes)
+	if e != nil {
+		err = e
+		return
+	}
+	if inline && !strings.HasPrefix(contentType, "image/") {
+		err = fmt.Errorf("inline content must be an image, got: %s", contentType)
+		return
+	}
+
+	attachment = &models.Attachment{
+		Filename:    filename,
+		ContentType: contentType,
+		Inline:      inline,
+		Content:     content,
+	}
+	if inline {
+		attachment.ContentID = filename
+	}
+
+	return
+}
+
+// the declared Content-Type of an upload is client controlled, sniff instead
+func ParseContentType(content []byte, allowed []string) (contentType string, err error) {
+	contentType, _, e := mime.ParseMediaType(http.DetectContentType(content))
+	if e != nil {
+		err = fmt.Errorf("failed to detect content type: %w", e)
+		return
+	}
+
+	for _, a := range allowed {
+		if strings.EqualFold(contentType, a) {
+			return
+		}
+	}
+
+	err = fmt.Errorf("content type not allowed: %s", contentType)
+	return
+}
@@ -0,0 +1,101 @@
+package attributes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+func GetFieldDefinitions(c context.Context, db handlers.DatabaseInterface) (definitions []*models.FieldDefinition, err error) {
+	rows, e := db.Query(c, "SELECT field_key, label, field_type FROM subscriber_fields ORDER BY field_key")
+	if e != nil {
+		err = fmt.Errorf("failed to fetch field definitions: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	definitions, e = pgx.CollectRows[*models.FieldDefinition](rows, BuildFieldDefinition)
+	if e != nil {
+		err = fmt.Errorf("failed to parse field definitions: %w", e)
+		return
+	}
+
+	return
+}
+
+func InsertFieldDefinition(c context.Context, db handlers.DatabaseInterface, definition *models.FieldDefinition) (err error) {
+	query := "INSERT INTO subscriber_fields (field_key, label, field_type, created) VALUES ($1, $2, $3, now())"
+	if _, e := db.Exec(c, query, definition.Key, definition.Label, definition.Type); e != nil {
+		err = fmt.Errorf("failed to insert field definition: %w", e)
+		return
+	}
+
+	return
+}
+
+// DeleteFieldDefinition removes the field and every stored value for it
+func DeleteFieldDefinition(c context.Context, tx pgx.Tx, key string) (deleted bool, err error) {
+	tag, e := tx.Exec(c, "DELETE FROM subscriber_fields WHERE field_key = $1", key)
+	if e != nil {
+		err = fmt.Errorf("failed to delete field definition: %w", e)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		return
+	}
+
+	query := "UPDATE subscriptions SET attributes = attributes - $1::text WHERE attributes ? $1::text"
+	if _, e := tx.Exec(c, query, key); e != nil {
+		err = fmt.Errorf("failed to delete field values: %w", e)
+		return
+	}
+
+	deleted = true
+	return
+}
+
+// UpdateAttributes merges parsed attributes into the stored ones, null
+// values clear the attribute
+func UpdateAttributes(c context.Context, db handlers.DatabaseInterface, subscriberID string, attributes models.Attributes) (updated bool, err error) {
+	set, cleared := Split(attributes)
+
+	query := `UPDATE subscriptions
+			SET attributes = (attributes || $2::jsonb) - $3::text[]
+			WHERE id = $1`
+	tag, e := db.Exec(c, query, subscriberID, set, cleared)
+	if e != nil {
+		err = fmt.Errorf("failed to update attributes: %w", e)
+		return
+	}
+
+	updated = tag.RowsAffected() > 0
+	return
+}
+
+// Split separates values to store from the keys of attributes to clear
+func Split(attributes models.Attributes) (set models.Attributes, cleared []string) {
+	set = make(models.Attributes, len(attributes))
+	cleared = []string{}
+	for key, value := range attributes {
+		if value == nil {
+			cleared = append(cleared, key)
+			continue
+		}
+		set[key] = value
+	}
+
+	return
+}
+
+func BuildFieldDefinition(row pgx.CollectableRow) (definition *models.FieldDefinition, err error) {
+	definition = &models.FieldDefinition{}
+	if e := row.Scan(&definition.Key, &definition.Label, &definition.Type); e != nil {
+		err = fmt.Errorf("database error: %w", e)
+		return
+	}
+
+	return
+}
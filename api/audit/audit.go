@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+// Record stores entry, callers pass their transaction so the entry is only
+// kept alongside the mutation it describes
+func Record(c context.Context, db handlers.DatabaseInterface, entry *models.AuditEntry) (err error) {
+	if entry.Details == nil {
+		entry.Details = map[string]interface{}{}
+	}
+
+	query := `INSERT INTO audit_log (audit_id, actor, action, subscriber_id, details, created)
+			VALUES ($1, $2, $3, $4, $5, now())`
+	_, e := db.Exec(c, query, uuid.NewString(), entry.Actor, entry.Action, entry.SubscriberID, entry.Details)
+	if e != nil {
+		err = fmt.Errorf("failed to record audit entry: %w", e)
+		return
+	}
+
+	return
+}
+
+func GetEntries(c context.Context, db handlers.DatabaseInterface, subscriberID string) (entries []*models.AuditEntry, err error) {
+	query := `SELECT audit_id, actor, action, subscriber_id, details, created
+			FROM audit_log
+			WHERE subscriber_id = $1
+			ORDER BY created DESC`
+	rows, e := db.Query(c, query, subscriberID)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch audit entries: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	entries, e = pgx.CollectRows[*models.AuditEntry](rows, BuildEntry)
+	if e != nil {
+		err = fmt.Errorf("failed to parse audit entries: %w", e)
+		return
+	}
+
+	return
+}
+
+func BuildEntry(row pgx.CollectableRow) (entry *models.AuditEntry, err error) {
+	entry = &models.AuditEntry{}
+	e := row.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.SubscriberID, &entry.Details, &entry.Created)
+	if e != nil {
+		err = fmt.Errorf("failed to scan audit entry: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,125 @@
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+// Blocklist matches disposable domains and role accounts read from a locally
+// maintained file. Lines ending in @ are role account local parts, e.g.
+// postmaster@, any other line is a disposable domain. Blank lines and lines
+// starting with # are ignored.
+type Blocklist struct {
+	path    string
+	mu      sync.RWMutex
+	domains map[string]bool
+	roles   map[string]bool
+}
+
+func NewBlocklist(path string) (blocklist *Blocklist, err error) {
+	blocklist = &Blocklist{path: path}
+	if err = blocklist.Reload(); err != nil {
+		blocklist = nil
+		return
+	}
+
+	return
+}
+
+// Reload re-reads the file, the current entries are kept when it fails
+func (b *Blocklist) Reload() (err error) {
+	file, e := os.Open(b.path)
+	if e != nil {
+		err = fmt.Errorf("failed to open blocklist: %w", e)
+		return
+	}
+	defer file.Close()
+
+	domains, roles, e := Parse(file)
+	if e != nil {
+		err = e
+		return
+	}
+
+	b.mu.Lock()
+	b.domains, b.roles = domains, roles
+	b.mu.Unlock()
+
+	return
+}
+
+func Parse(r io.Reader) (domains map[string]bool, roles map[string]bool, err error) {
+	domains, roles = make(map[string]bool), make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry := models.LowerASCII(strings.TrimSpace(scanner.Text()))
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		if local, found := strings.CutSuffix(entry, "@"); found {
+			if local == "" || strings.Contains(local, "@") {
+				err = fmt.Errorf("invalid role account on line %d: %s", line, entry)
+				return
+			}
+			roles[local] = true
+			continue
+		}
+
+		if strings.ContainsAny(entry, "@ ") || !strings.Contains(entry, ".") {
+			err = fmt.Errorf("invalid domain on line %d: %s", line, entry)
+			return
+		}
+		domains[strings.TrimPrefix(entry, ".")] = true
+	}
+	if e := scanner.Err(); e != nil {
+		err = fmt.Errorf("failed to read blocklist: %w", e)
+		return
+	}
+
+	return
+}
+
+// Match returns the reason the address is blocklisted, empty when it is
+// not. Disposable domains also match their subdomains and role accounts
+// match with any +tag. A nil blocklist matches nothing.
+func (b *Blocklist) Match(email models.SubscriberEmail) (reason string) {
+	if b == nil {
+		return
+	}
+
+	address := models.LowerASCII(email.String())
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return
+	}
+	local, domain := address[:at], address[at+1:]
+	local, _, _ = strings.Cut(local, "+")
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for domain != "" {
+		if b.domains[domain] {
+			return models.BlockReasonDisposable
+		}
+
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+	if b.roles[local] {
+		return models.BlockReasonRole
+	}
+
+	return
+}
@@ -0,0 +1,141 @@
+package bounces
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+)
+
+// suppression reasons for addresses that reached a bounce threshold
+const (
+	hardBounceReason = "hard bounce"
+	softBounceReason = "repeated soft bounces"
+)
+
+var ErrUnattributed = errors.New("bounce does not match a delivery")
+
+// Attribute builds the bounce reported by dsn from the delivery its VERP
+// return path points at
+func Attribute(c context.Context, tx pgx.Tx, verp *VERP, dsn *DSN) (bounce *models.Bounce, err error) {
+	kind, e := Classify(dsn)
+	if e != nil {
+		err = e
+		return
+	}
+
+	bounce = &models.Bounce{
+		MessageID:  dsn.MessageID,
+		Kind:       kind,
+		Status:     dsn.Status,
+		Diagnostic: dsn.Diagnostic,
+	}
+	for _, recipient := range dsn.Recipients {
+		if deliveryID, e := verp.Parse(recipient); e == nil {
+			bounce.DeliveryID = &deliveryID
+			break
+		}
+	}
+	if bounce.DeliveryID == nil {
+		err = ErrUnattributed
+		return
+	}
+
+	err = LookupDelivery(c, tx, bounce)
+	return
+}
+
+// LookupDelivery fills in the issue, list and address of the delivery bounce
+// points at
+func LookupDelivery(c context.Context, tx pgx.Tx, bounce *models.Bounce) (err error) {
+	query := `SELECT newsletter_issue_id, list_id, subscriber_email
+			FROM delivery_log
+			WHERE delivery_id = $1`
+	e := tx.QueryRow(c, query, *bounce.DeliveryID).Scan(&bounce.IssueID, &bounce.ListID, &bounce.Email)
+	if e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrUnattributed
+			return
+		}
+
+		err = fmt.Errorf("failed to fetch delivery: %w", e)
+		return
+	}
+
+	return
+}
+
+// RecordBounce stores bounce once and marks the address as bounced on every
+// list when it reaches a threshold. Soft bounces only count within the
+// configured window. A bounced address is also suppressed so neither new
+// subscriptions, imports nor transactional email reach it again, source
+// records where the bounce was reported.
+func RecordBounce(c context.Context, tx pgx.Tx, bounce *models.Bounce, settings *configs.BounceSettings, source string) (recorded bool, bounced bool, err error) {
+	bounce.ID = uuid.NewString()
+	query := `INSERT INTO bounces (
+				bounce_id, message_id, delivery_id, newsletter_issue_id, list_id,
+				subscriber_email, kind, status, diagnostic, received
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+			ON CONFLICT (message_id) DO NOTHING`
+	tag, e := tx.Exec(
+		c, query,
+		bounce.ID, bounce.MessageID, bounce.DeliveryID, bounce.IssueID, bounce.ListID,
+		bounce.Email.String(), bounce.Kind, bounce.Status, bounce.Diagnostic,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to insert bounce: %w", e)
+		return
+	}
+	if recorded = tag.RowsAffected() > 0; !recorded {
+		return
+	}
+
+	var hard, soft int
+	query = `SELECT
+				COUNT(*) FILTER (WHERE kind = 'hard'),
+				COUNT(*) FILTER (WHERE kind = 'soft' AND received > now() - make_interval(days => $2))
+			FROM bounces
+			WHERE normalize_email(subscriber_email) = normalize_email($1)`
+	if e := tx.QueryRow(c, query, bounce.Email.String(), settings.SoftWindow).Scan(&hard, &soft); e != nil {
+		err = fmt.Errorf("failed to count bounces: %w", e)
+		return
+	}
+	if hard < settings.HardThreshold && soft < settings.SoftThreshold {
+		return
+	}
+
+	query = "UPDATE subscriptions SET status = 'bounced' WHERE normalized_email = normalize_email($1) AND status IN ('pending', 'confirmed')"
+	if _, e := tx.Exec(c, query, bounce.Email.String()); e != nil {
+		err = fmt.Errorf("failed to mark subscriptions bounced: %w", e)
+		return
+	}
+
+	query = "DELETE FROM issue_delivery_queue WHERE normalize_email(subscriber_email) = normalize_email($1)"
+	if _, e := tx.Exec(c, query, bounce.Email.String()); e != nil {
+		err = fmt.Errorf("failed to delete delivery tasks: %w", e)
+		return
+	}
+
+	reason := hardBounceReason
+	if hard < settings.HardThreshold {
+		reason = softBounceReason
+	}
+	suppression, e := models.ParseSuppression(bounce.Email.String(), reason, source)
+	if e != nil {
+		err = fmt.Errorf("failed to parse suppression: %w", e)
+		return
+	}
+	if _, e := suppressions.Insert(c, tx, suppression); e != nil {
+		err = e
+		return
+	}
+
+	bounced = true
+	return
+}
@@ -0,0 +1,156 @@
+package bounces
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const maxDiagnosticLength = 500
+
+var (
+	ErrNotDSN    = errors.New("message is not a delivery status notification")
+	ErrNotBounce = errors.New("notification does not report a failed delivery")
+)
+
+// headers a DSN may carry its envelope recipient, i.e. our return path, in
+var recipientHeaders = []string{"Delivered-To", "X-Original-To", "Envelope-To", "To"}
+
+// DSN is the part of an RFC 3464 delivery status notification needed to
+// attribute and classify a bounce
+type DSN struct {
+	MessageID  string
+	Recipients []string
+	Action     string
+	Status     string
+	Diagnostic string
+}
+
+func ParseDSN(r io.Reader) (dsn *DSN, err error) {
+	raw, e := io.ReadAll(r)
+	if e != nil {
+		err = fmt.Errorf("failed to read message: %w", e)
+		return
+	}
+
+	message, e := mail.ReadMessage(bytes.NewReader(raw))
+	if e != nil {
+		err = fmt.Errorf("failed to parse message: %w", e)
+		return
+	}
+
+	mediaType, params, e := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if e != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		err = ErrNotDSN
+		return
+	}
+
+	dsn = &DSN{MessageID: strings.TrimSpace(message.Header.Get("Message-Id"))}
+	if dsn.MessageID == "" {
+		sum := sha256.Sum256(raw)
+		dsn.MessageID = hex.EncodeToString(sum[:])
+	}
+	for _, header := range recipientHeaders {
+		addresses, e := message.Header.AddressList(header)
+		if e != nil {
+			continue
+		}
+		for _, address := range addresses {
+			dsn.Recipients = append(dsn.Recipients, address.Address)
+		}
+	}
+
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, e := parts.NextPart()
+		if errors.Is(e, io.EOF) {
+			break
+		}
+		if e != nil {
+			err = fmt.Errorf("failed to read report: %w", e)
+			return
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType != "message/delivery-status" && partType != "message/global-delivery-status" {
+			continue
+		}
+
+		if e := parseDeliveryStatus(part, dsn); e != nil {
+			err = e
+			return
+		}
+		return
+	}
+
+	err = ErrNotDSN
+	return
+}
+
+// the delivery-status part is a per-message header block followed by one
+// block per recipient, the first failed or delayed recipient is reported
+func parseDeliveryStatus(r io.Reader, dsn *DSN) (err error) {
+	reader := textproto.NewReader(bufio.NewReader(r))
+
+	// per-message fields are not needed
+	if _, e := reader.ReadMIMEHeader(); e != nil {
+		err = ErrNotBounce
+		return
+	}
+
+	for {
+		fields, e := reader.ReadMIMEHeader()
+		if len(fields) > 0 {
+			action := strings.ToLower(strings.TrimSpace(fields.Get("Action")))
+			if action == "failed" || action == "delayed" {
+				dsn.Action = action
+				dsn.Status, _, _ = strings.Cut(strings.TrimSpace(fields.Get("Status")), " ")
+				dsn.Diagnostic = truncate(strings.TrimSpace(fields.Get("Diagnostic-Code")), maxDiagnosticLength)
+				return
+			}
+		}
+		if e != nil {
+			break
+		}
+	}
+
+	err = ErrNotBounce
+	return
+}
+
+// Classify sorts a failed delivery into a hard or soft bounce by its RFC 3463
+// status code. A full mailbox is permanent on paper but usually clears up.
+func Classify(dsn *DSN) (kind string, err error) {
+	class, detail, _ := strings.Cut(dsn.Status, ".")
+	switch {
+	case dsn.Action == "delayed" || class == "4":
+		kind = models.BounceKindSoft
+	case class == "5" && detail == "2.2":
+		kind = models.BounceKindSoft
+	case class == "5":
+		kind = models.BounceKindHard
+	default:
+		err = ErrNotBounce
+	}
+
+	return
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+
+	return s[:length]
+}
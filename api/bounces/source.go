@@ -0,0 +1,122 @@
+package bounces
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadMaildir hands every message in new/ to handle and moves it to cur/,
+// messages handle fails on are left for the next read
+func ReadMaildir(dir string, handle func(io.Reader) error) (err error) {
+	entries, e := os.ReadDir(filepath.Join(dir, "new"))
+	if e != nil {
+		err = fmt.Errorf("failed to read maildir: %w", e)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, "new", entry.Name())
+		if e := handleFile(path, handle); e != nil {
+			err = e
+			return
+		}
+
+		// ":2,S" marks the message as seen
+		if e := os.Rename(path, filepath.Join(dir, "cur", entry.Name()+":2,S")); e != nil {
+			err = fmt.Errorf("failed to move message: %w", e)
+			return
+		}
+	}
+
+	return
+}
+
+// ReadMbox hands every message in the mbox to handle. The file is renamed
+// before reading so new mail starts a fresh mbox, a failed read is resumed
+// from the renamed file and relies on handle ignoring messages seen before.
+func ReadMbox(path string, handle func(io.Reader) error) (err error) {
+	processing := path + ".processing"
+	if _, e := os.Stat(processing); errors.Is(e, os.ErrNotExist) {
+		if e := os.Rename(path, processing); e != nil {
+			if errors.Is(e, os.ErrNotExist) {
+				return
+			}
+
+			err = fmt.Errorf("failed to claim mbox: %w", e)
+			return
+		}
+	}
+
+	file, e := os.Open(processing)
+	if e != nil {
+		err = fmt.Errorf("failed to open mbox: %w", e)
+		return
+	}
+	defer file.Close()
+
+	var message bytes.Buffer
+	flush := func() error {
+		if message.Len() == 0 {
+			return nil
+		}
+		defer message.Reset()
+
+		return handle(bytes.NewReader(message.Bytes()))
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "From ") {
+			if e := flush(); e != nil {
+				err = e
+				return
+			}
+			continue
+		}
+
+		// mboxrd escapes body lines starting with From
+		if unquoted := strings.TrimLeft(line, ">"); len(unquoted) < len(line) && strings.HasPrefix(unquoted, "From ") {
+			line = line[1:]
+		}
+		message.WriteString(line)
+		message.WriteString("\r\n")
+	}
+	if e := scanner.Err(); e != nil {
+		err = fmt.Errorf("failed to read mbox: %w", e)
+		return
+	}
+	if e := flush(); e != nil {
+		err = e
+		return
+	}
+
+	if e := os.Remove(processing); e != nil {
+		err = fmt.Errorf("failed to remove processed mbox: %w", e)
+		return
+	}
+
+	return
+}
+
+func handleFile(path string, handle func(io.Reader) error) (err error) {
+	file, e := os.Open(path)
+	if e != nil {
+		err = fmt.Errorf("failed to open message: %w", e)
+		return
+	}
+	defer file.Close()
+
+	return handle(file)
+}
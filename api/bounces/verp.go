@@ -0,0 +1,70 @@
+package bounces
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/signing"
+)
+
+var ErrInvalidVERP = errors.New("address is not a valid VERP return path")
+
+// VERP encodes a delivery into its return path so bounces can be attributed
+// without trusting the bounced message, e.g. bounces+<delivery>-<tag>@domain
+type VERP struct {
+	prefix string
+	domain string
+	signer *signing.Signer
+}
+
+// NewVERP returns nil when bounce processing is disabled
+func NewVERP(settings *configs.BounceSettings, signer *signing.Signer) *VERP {
+	if settings.Domain == "" {
+		return nil
+	}
+
+	return &VERP{
+		prefix: strings.ToLower(settings.Prefix),
+		domain: strings.ToLower(settings.Domain),
+		signer: signer,
+	}
+}
+
+func (verp *VERP) Address(deliveryID string) models.SubscriberEmail {
+	id := strings.ReplaceAll(deliveryID, "-", "")
+
+	return models.SubscriberEmail(verp.prefix + "+" + id + "-" + verp.signer.Tag(signing.PurposeVERP, id) + "@" + verp.domain)
+}
+
+// Parse returns the delivery encoded in address, the tag guards against
+// forged bounces unsubscribing arbitrary deliveries
+func (verp *VERP) Parse(address string) (deliveryID string, err error) {
+	local, domain, found := strings.Cut(strings.ToLower(strings.TrimSpace(address)), "@")
+	if !found || domain != verp.domain {
+		err = ErrInvalidVERP
+		return
+	}
+
+	token, found := strings.CutPrefix(local, verp.prefix+"+")
+	if !found {
+		err = ErrInvalidVERP
+		return
+	}
+	id, tag, found := strings.Cut(token, "-")
+	if !found || !verp.signer.VerifyTag(signing.PurposeVERP, id, tag) {
+		err = ErrInvalidVERP
+		return
+	}
+
+	parsed, e := uuid.Parse(id)
+	if e != nil {
+		err = ErrInvalidVERP
+		return
+	}
+
+	deliveryID = parsed.String()
+	return
+}
@@ -1,13 +1,29 @@
 package clients
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
 
 	"github.com/go-gomail/gomail"
 	"github.com/solomonbaez/hyacinth/api/configs"
 	"github.com/solomonbaez/hyacinth/api/models"
 )
 
+const (
+	smtpTimeout = 10 * time.Second
+	smtpSSLPort = 465
+)
+
+// raised when an address has a non-ASCII local part and the server can not
+// relay it, retrying will not help
+var ErrSMTPUTF8Unsupported = errors.New("server does not support SMTPUTF8")
+
 type EmailClient interface {
 	SendEmail(email *models.Newsletter) error
 }
@@ -63,17 +79,155 @@ func (client *SMTPClient) SendEmail(newsletter *models.Newsletter) (err error) {
 	}
 
 	m := gomail.NewMessage()
-	m.SetHeader("From", client.Sender.String())
-	m.SetHeader("To", newsletter.Recipient.String())
+	if newsletter.Sender != nil {
+		m.SetAddressHeader("From", newsletter.Sender.Email.String(), newsletter.Sender.Name)
+	} else {
+		m.SetAddressHeader("From", client.Sender.String(), "")
+	}
+	// address headers are written raw, RFC 6532 allows UTF-8 in them
+	m.SetAddressHeader("To", newsletter.Recipient.String(), "")
 	m.SetHeader("Subject", newsletter.Content.Title)
+	for header, value := range newsletter.Headers {
+		m.SetHeader(header, value)
+	}
 	m.SetBody("text/plain", newsletter.Content.Text)
 	m.AddAlternative("text/html", newsletter.Content.Html)
 
+	for _, attachment := range newsletter.Attachments {
+		addAttachment(m, attachment)
+	}
+
+	from := newsletter.ReturnPath
+	if from == "" {
+		from = *client.Sender
+		if newsletter.Sender != nil {
+			from = newsletter.Sender.Email
+		}
+	}
+	if from.RequiresSMTPUTF8() || newsletter.Recipient.RequiresSMTPUTF8() {
+		return client.sendUTF8(from.String(), newsletter.Recipient.String(), m)
+	}
+
 	dialer := gomail.NewDialer(client.SmtpServer, client.SmtpPort, client.smtpUsername, client.smtpPassword)
-	if e := dialer.DialAndSend(m); e != nil {
+	if newsletter.ReturnPath == "" {
+		if e := dialer.DialAndSend(m); e != nil {
+			err = fmt.Errorf("failed to send email: %w", e)
+			return
+		}
+
+		return
+	}
+
+	// gomail derives the envelope sender from the headers, so the VERP
+	// return path is passed to the connection directly
+	sender, e := dialer.Dial()
+	if e != nil {
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+	defer sender.Close()
+
+	if e := sender.Send(newsletter.ReturnPath.String(), []string{newsletter.Recipient.String()}, m); e != nil {
 		err = fmt.Errorf("failed to send email: %w", e)
 		return
 	}
 
 	return
 }
+
+// sendUTF8 delivers over a session of our own since gomail hides the
+// extensions the server advertises, net/smtp requests SMTPUTF8 in MAIL FROM
+// once it is advertised
+func (client *SMTPClient) sendUTF8(from string, recipient string, m *gomail.Message) (err error) {
+	address := net.JoinHostPort(client.SmtpServer, strconv.Itoa(client.SmtpPort))
+	tlsConfig := &tls.Config{ServerName: client.SmtpServer}
+
+	var conn net.Conn
+	var e error
+	if client.SmtpPort == smtpSSLPort {
+		conn, e = tls.DialWithDialer(&net.Dialer{Timeout: smtpTimeout}, "tcp", address, tlsConfig)
+	} else {
+		conn, e = net.DialTimeout("tcp", address, smtpTimeout)
+	}
+	if e != nil {
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+
+	session, e := smtp.NewClient(conn, client.SmtpServer)
+	if e != nil {
+		conn.Close()
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+	defer session.Close()
+
+	if e := session.Hello("localhost"); e != nil {
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+	if ok, _ := session.Extension("STARTTLS"); ok && client.SmtpPort != smtpSSLPort {
+		if e := session.StartTLS(tlsConfig); e != nil {
+			err = fmt.Errorf("failed to send email: %w", e)
+			return
+		}
+	}
+	if ok, _ := session.Extension("AUTH"); ok && client.smtpUsername != "" {
+		auth := smtp.PlainAuth("", client.smtpUsername, client.smtpPassword, client.SmtpServer)
+		if e := session.Auth(auth); e != nil {
+			err = fmt.Errorf("failed to send email: %w", e)
+			return
+		}
+	}
+	if ok, _ := session.Extension("SMTPUTF8"); !ok {
+		err = ErrSMTPUTF8Unsupported
+		return
+	}
+
+	if e := session.Mail(from); e != nil {
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+	if e := session.Rcpt(recipient); e != nil {
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+	w, e := session.Data()
+	if e != nil {
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+	if _, e := m.WriteTo(w); e != nil {
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+	if e := w.Close(); e != nil {
+		err = fmt.Errorf("failed to send email: %w", e)
+		return
+	}
+
+	return session.Quit()
+}
+
+func addAttachment(m *gomail.Message, attachment *models.Attachment) {
+	content := attachment.Content
+	settings := []gomail.FileSetting{
+		gomail.SetCopyFunc(func(w io.Writer) error {
+			_, e := w.Write(content)
+			return e
+		}),
+		gomail.SetHeader(map[string][]string{
+			"Content-Type": {fmt.Sprintf("%s; name=%q", attachment.ContentType, attachment.Filename)},
+		}),
+	}
+
+	if attachment.Inline {
+		settings = append(settings, gomail.SetHeader(map[string][]string{
+			"Content-ID": {fmt.Sprintf("<%s>", attachment.ContentID)},
+		}))
+		m.Embed(attachment.Filename, settings...)
+		return
+	}
+
+	m.Attach(attachment.Filename, settings...)
+}
@@ -103,3 +103,275 @@ func ConfigureEmailClient(cfg string) (settings *EmailClientSettings, err error)
 
 	return
 }
+
+// ATTACHMENTS
+const (
+	AttachmentStoragePostgres = "postgres"
+	AttachmentStorageLocal    = "local"
+)
+
+type AttachmentSettings struct {
+	Storage      string
+	Directory    string
+	MaxFileSize  int64
+	MaxTotalSize int64
+	AllowedTypes []string
+}
+
+func ConfigureAttachments() (settings *AttachmentSettings, err error) {
+	if e := viper.ReadInConfig(); e != nil {
+		err = fmt.Errorf("failed to read configuration: %w", e)
+		return
+	}
+
+	settings = &AttachmentSettings{
+		viper.GetString("attachments.storage"),
+		viper.GetString("attachments.directory"),
+		viper.GetInt64("attachments.max_file_size"),
+		viper.GetInt64("attachments.max_total_size"),
+		viper.GetStringSlice("attachments.allowed_types"),
+	}
+
+	switch settings.Storage {
+	case AttachmentStoragePostgres, AttachmentStorageLocal:
+	default:
+		err = fmt.Errorf("invalid attachment storage: %s", settings.Storage)
+		return
+	}
+
+	return
+}
+
+// SIGNING
+const minSigningSecretLength = 32
+
+type SigningSettings struct {
+	Secret string
+	// days preferences links stay valid, 0 keeps them valid forever
+	PreferencesExpiry int
+}
+
+func ConfigureSigning() (settings *SigningSettings, err error) {
+	if e := viper.ReadInConfig(); e != nil {
+		err = fmt.Errorf("failed to read configuration: %w", e)
+		return
+	}
+
+	settings = &SigningSettings{
+		viper.GetString("signing.secret"),
+		viper.GetInt("signing.preferences_expiry"),
+	}
+
+	if len(settings.Secret) < minSigningSecretLength {
+		err = fmt.Errorf("signing secret must be at least %d characters", minSigningSecretLength)
+		return
+	}
+	if settings.PreferencesExpiry < 0 {
+		err = fmt.Errorf("preferences expiry can not be negative")
+		return
+	}
+
+	return
+}
+
+// BOUNCES
+const (
+	BounceFormatMaildir = "maildir"
+	BounceFormatMbox    = "mbox"
+)
+
+// BounceSettings configures VERP return paths and the bounce processor,
+// both are disabled when Domain is empty
+type BounceSettings struct {
+	Domain        string
+	Prefix        string
+	Source        string
+	Format        string
+	Interval      int
+	HardThreshold int
+	SoftThreshold int
+	SoftWindow    int
+}
+
+func ConfigureBounces() (settings *BounceSettings, err error) {
+	if e := viper.ReadInConfig(); e != nil {
+		err = fmt.Errorf("failed to read configuration: %w", e)
+		return
+	}
+
+	settings = &BounceSettings{
+		viper.GetString("bounces.domain"),
+		viper.GetString("bounces.prefix"),
+		viper.GetString("bounces.source"),
+		viper.GetString("bounces.format"),
+		viper.GetInt("bounces.interval"),
+		viper.GetInt("bounces.hard_threshold"),
+		viper.GetInt("bounces.soft_threshold"),
+		viper.GetInt("bounces.soft_window"),
+	}
+	// thresholds also apply to bounces reported by the mail event webhook
+	if settings.HardThreshold < 1 || settings.SoftThreshold < 1 || settings.SoftWindow < 1 {
+		err = fmt.Errorf("bounce thresholds and window must be positive")
+		return
+	}
+	if settings.Domain == "" {
+		return
+	}
+
+	switch settings.Format {
+	case BounceFormatMaildir, BounceFormatMbox:
+	default:
+		err = fmt.Errorf("invalid bounce format: %s", settings.Format)
+		return
+	}
+	if settings.Prefix == "" || settings.Source == "" || settings.Interval < 1 {
+		err = fmt.Errorf("bounce prefix, source and interval are required")
+		return
+	}
+
+	return
+}
+
+// WEBHOOKS
+// WebhookSettings configures the mail event webhook, it is disabled when
+// Secret is empty
+type WebhookSettings struct {
+	Secret string
+}
+
+func ConfigureWebhooks() (settings *WebhookSettings, err error) {
+	if e := viper.ReadInConfig(); e != nil {
+		err = fmt.Errorf("failed to read configuration: %w", e)
+		return
+	}
+
+	settings = &WebhookSettings{
+		viper.GetString("webhooks.secret"),
+	}
+
+	if settings.Secret != "" && len(settings.Secret) < minSigningSecretLength {
+		err = fmt.Errorf("webhook secret must be at least %d characters", minSigningSecretLength)
+		return
+	}
+
+	return
+}
+
+// BLOCKLIST
+// BlocklistSettings locates the disposable domain and role account
+// blocklist, it is disabled when File is empty
+type BlocklistSettings struct {
+	File string
+}
+
+func ConfigureBlocklist() (settings *BlocklistSettings, err error) {
+	if e := viper.ReadInConfig(); e != nil {
+		err = fmt.Errorf("failed to read configuration: %w", e)
+		return
+	}
+
+	settings = &BlocklistSettings{
+		viper.GetString("blocklist.file"),
+	}
+
+	return
+}
+
+// NORMALIZATION
+// NormalizationSettings enables reporting subscriptions that only reach the
+// same mailbox under provider rules, e.g. Gmail ignoring dots and +tags.
+// Provider rules are never part of the uniqueness key.
+type NormalizationSettings struct {
+	ProviderRules bool
+}
+
+func ConfigureNormalization() (settings *NormalizationSettings, err error) {
+	if e := viper.ReadInConfig(); e != nil {
+		err = fmt.Errorf("failed to read configuration: %w", e)
+		return
+	}
+
+	settings = &NormalizationSettings{
+		viper.GetBool("normalization.provider_rules"),
+	}
+
+	return
+}
+
+// SUNSET
+// SunsetSettings configures engagement scoring and the sunset policy, the
+// policy is disabled when InactiveIssues is 0
+type SunsetSettings struct {
+	ScoreWindow    int
+	InactiveIssues int
+	GracePeriod    int
+	Interval       int
+	Title          string
+	Text           string
+	Html           string
+}
+
+func ConfigureSunset() (settings *SunsetSettings, err error) {
+	if e := viper.ReadInConfig(); e != nil {
+		err = fmt.Errorf("failed to read configuration: %w", e)
+		return
+	}
+
+	settings = &SunsetSettings{
+		viper.GetInt("sunset.score_window"),
+		viper.GetInt("sunset.inactive_issues"),
+		viper.GetInt("sunset.grace_period"),
+		viper.GetInt("sunset.interval"),
+		viper.GetString("sunset.title"),
+		viper.GetString("sunset.text"),
+		viper.GetString("sunset.html"),
+	}
+	if settings.ScoreWindow < 1 || settings.InactiveIssues < 0 {
+		err = fmt.Errorf("sunset score window must be positive and inactive issues not negative")
+		return
+	}
+	if settings.InactiveIssues == 0 {
+		return
+	}
+
+	if settings.GracePeriod < 1 || settings.Interval < 1 {
+		err = fmt.Errorf("sunset grace period and interval must be positive")
+		return
+	}
+	if settings.Title == "" || settings.Text == "" || settings.Html == "" {
+		err = fmt.Errorf("sunset title, text and html are required")
+		return
+	}
+
+	return
+}
+
+// FREQUENCY CAP
+// FrequencyCapSettings limits the messages a subscriber receives per
+// rolling window of Window hours, the cap is disabled when MaxMessages is 0
+type FrequencyCapSettings struct {
+	MaxMessages int
+	Window      int
+}
+
+func ConfigureFrequencyCap() (settings *FrequencyCapSettings, err error) {
+	if e := viper.ReadInConfig(); e != nil {
+		err = fmt.Errorf("failed to read configuration: %w", e)
+		return
+	}
+
+	settings = &FrequencyCapSettings{
+		viper.GetInt("frequency_cap.max_messages"),
+		viper.GetInt("frequency_cap.window"),
+	}
+	if settings.MaxMessages < 0 {
+		err = fmt.Errorf("frequency cap max messages must not be negative")
+		return
+	}
+	if settings.MaxMessages > 0 && settings.Window < 1 {
+		err = fmt.Errorf("frequency cap window must be positive")
+		return
+	}
+
+	return
+}
@@ -0,0 +1,57 @@
+package frequency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/preferences"
+	"github.com/solomonbaez/hyacinth/api/privacy"
+)
+
+// transactional deliveries are neither held back nor counted towards the cap
+var transactionalIssueIDs = []string{
+	preferences.EmailChangeConfirmationIssueID,
+	preferences.EmailChangeNotificationIssueID,
+	privacy.DataExportIssueID,
+}
+
+// IsTransactional reports whether the issue is a subscription confirmation,
+// email change or data export message, which are sent regardless of the cap
+func IsTransactional(issueID string, list *models.List) bool {
+	return issueID == list.ConfirmationIssueID || preferences.IsEmailChangeIssue(issueID) || privacy.IsDataExportIssue(issueID)
+}
+
+// NextSlot returns when the address may be sent another message, nil while
+// it is under the cap. Deliveries are counted across every list and every
+// form of the address. The address stays locked until tx ends, so workers
+// sending to it concurrently can not both pass the check.
+func NextSlot(c context.Context, tx pgx.Tx, email models.SubscriberEmail, settings *configs.FrequencyCapSettings) (next *time.Time, err error) {
+	query := "SELECT pg_advisory_xact_lock(hashtext(normalize_email($1)))"
+	if _, e := tx.Exec(c, query, email.String()); e != nil {
+		err = fmt.Errorf("failed to lock address: %w", e)
+		return
+	}
+
+	// the cap is reached while the window holds MaxMessages deliveries, a
+	// slot opens once the oldest of them leaves the window
+	query = `SELECT (array_agg(sent ORDER BY sent DESC))[$2] + make_interval(hours => $3)
+			FROM delivery_log
+			WHERE normalize_email(subscriber_email) = normalize_email($1)
+			AND sent > now() - make_interval(hours => $3)
+			AND newsletter_issue_id <> ALL($4::uuid[])
+			AND NOT EXISTS (
+				SELECT 1 FROM lists WHERE lists.confirmation_issue_id = delivery_log.newsletter_issue_id
+			)`
+	e := tx.QueryRow(c, query, email.String(), settings.MaxMessages, settings.Window, transactionalIssueIDs).Scan(&next)
+	if e != nil {
+		err = fmt.Errorf("failed to check frequency cap: %w", e)
+		return
+	}
+
+	return
+}
@@ -29,6 +29,9 @@ func NewDatabaseHandler(database DatabaseInterface) *DatabaseHandler {
 }
 
 type Loader struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	Email      string                 `json:"email"`
+	Name       string                 `json:"name"`
+	List       string                 `json:"list"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Timezone   string                 `json:"timezone"`
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/signing"
 )
 
 const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -32,10 +33,10 @@ func StoreToken(c context.Context, tx pgx.Tx, id string, token string) (err erro
 	return
 }
 
-func getToken(c context.Context, tx pgx.Tx, subscriberEmail *models.SubscriberEmail) (token string, err error) {
-	query := "SELECT id FROM subscriptions WHERE email = $1"
+func getToken(c context.Context, tx pgx.Tx, listID string, subscriberEmail *models.SubscriberEmail) (token string, err error) {
+	query := "SELECT id FROM subscriptions WHERE list_id = $1 AND email = $2"
 	var subscriberID string
-	if e := tx.QueryRow(c, query, subscriberEmail.String()).Scan(&subscriberID); e != nil {
+	if e := tx.QueryRow(c, query, listID, subscriberEmail.String()).Scan(&subscriberID); e != nil {
 		err = fmt.Errorf("database error: %w", e)
 		return
 	}
@@ -49,8 +50,8 @@ func getToken(c context.Context, tx pgx.Tx, subscriberEmail *models.SubscriberEm
 	return
 }
 
-func GenerateConfirmationLink(c context.Context, tx pgx.Tx, subscriberEmail *models.SubscriberEmail) (confirmation string, err error) {
-	token, e := getToken(c, tx, subscriberEmail)
+func GenerateConfirmationLink(c context.Context, tx pgx.Tx, listID string, subscriberEmail *models.SubscriberEmail) (confirmation string, err error) {
+	token, e := getToken(c, tx, listID, subscriberEmail)
 	if e != nil {
 		err = fmt.Errorf("failed to retrieve subscription token: %w", e)
 		log.Error().
@@ -69,6 +70,19 @@ func GenerateConfirmationLink(c context.Context, tx pgx.Tx, subscriberEmail *mod
 	return
 }
 
+// GeneratePreferencesLink signs the subscriber ID so the preference center
+// can be reached without logging in, the link expires if the signer has an
+// expiry
+func GeneratePreferencesLink(signer *signing.Signer, subscriberID string) string {
+	return BaseURL + "/preferences/" + signer.SignExpiring(signing.PurposePreferences, subscriberID)
+}
+
+// GenerateUnsubscribeLink is the RFC 8058 one-click target for the
+// List-Unsubscribe header
+func GenerateUnsubscribeLink(signer *signing.Signer, subscriberID string) string {
+	return GeneratePreferencesLink(signer, subscriberID) + "/unsubscribe"
+}
+
 func GenerateCSPRNG(tokenLen int) (csprng string, err error) {
 	b := make([]byte, tokenLen)
 	maxIndex := big.NewInt(int64(len(charset)))
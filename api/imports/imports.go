@@ -0,0 +1,282 @@
+package imports
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/sequences"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+)
+
+const (
+	importBatchSize = 1000
+	maxImportRows   = 100000
+	tokenLength     = 25
+)
+
+var subscriberColumns = []string{"id", "email", "name", "status", "list_id", "created", "consent_source", "consented_at"}
+
+// ParseCSV validates every row, rows failing validation are reported rather
+// than aborting the import. Emails repeated in their normalized form are
+// counted as duplicates.
+func ParseCSV(r io.Reader, options *models.ImportOptions) (rows []*models.ImportRow, report *models.ImportReport, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, e := reader.Read()
+	if e != nil {
+		err = fmt.Errorf("failed to read header: %w", e)
+		return
+	}
+	emailIndex, nameIndex := -1, -1
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		switch {
+		case strings.EqualFold(column, options.EmailColumn):
+			emailIndex = i
+		case strings.EqualFold(column, options.NameColumn):
+			nameIndex = i
+		}
+	}
+	if emailIndex < 0 {
+		err = fmt.Errorf("missing email column: %s", options.EmailColumn)
+		return
+	}
+	if nameIndex < 0 {
+		err = fmt.Errorf("missing name column: %s", options.NameColumn)
+		return
+	}
+
+	report = &models.ImportReport{Errors: []*models.ImportRowError{}}
+	seen := make(map[string]bool)
+	for {
+		record, e := reader.Read()
+		if errors.Is(e, io.EOF) {
+			break
+		}
+
+		var parseError *csv.ParseError
+		if errors.As(e, &parseError) {
+			report.Errors = append(report.Errors, &models.ImportRowError{Row: parseError.Line, Error: parseError.Err.Error()})
+			continue
+		}
+		if e != nil {
+			err = fmt.Errorf("failed to read csv: %w", e)
+			return
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(rows)+len(report.Errors)+report.Duplicates >= maxImportRows {
+			err = fmt.Errorf("import exceeds maximum of: %d rows", maxImportRows)
+			return
+		}
+
+		row, e := parseRecord(record, emailIndex, nameIndex)
+		if e != nil {
+			rowError := &models.ImportRowError{Row: line, Error: e.Error()}
+			if emailIndex < len(record) {
+				rowError.Email = record[emailIndex]
+			}
+
+			report.Errors = append(report.Errors, rowError)
+			continue
+		}
+		normalized := models.NormalizeEmail(row.Email)
+		if seen[normalized] {
+			report.Duplicates++
+			continue
+		}
+		seen[normalized] = true
+
+		row.Row = line
+		rows = append(rows, row)
+	}
+
+	return
+}
+
+func parseRecord(record []string, emailIndex int, nameIndex int) (row *models.ImportRow, err error) {
+	if emailIndex >= len(record) || nameIndex >= len(record) {
+		err = errors.New("missing columns")
+		return
+	}
+
+	row = &models.ImportRow{}
+	if row.Email, err = models.ParseEmail(strings.TrimSpace(record[emailIndex])); err != nil {
+		return
+	}
+	if row.Name, err = models.ParseName(strings.TrimSpace(record[nameIndex])); err != nil {
+		return
+	}
+
+	return
+}
+
+// ImportSubscribers copies rows into the list in batches, skipping emails
+// already subscribed or suppressed. Pending imports receive a confirmation
+// email.
+func ImportSubscribers(c context.Context, tx pgx.Tx, list *models.List, rows []*models.ImportRow, options *models.ImportOptions, report *models.ImportReport) (err error) {
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := min(start+importBatchSize, len(rows))
+
+		imported, suppressed, e := importBatch(c, tx, list, rows[start:end], options)
+		if e != nil {
+			err = fmt.Errorf("failed to import rows %d to %d: %w", rows[start].Row, rows[end-1].Row, e)
+			return
+		}
+
+		report.Imported += imported
+		report.Suppressed += suppressed
+		report.Duplicates += end - start - imported - suppressed
+	}
+
+	return
+}
+
+func importBatch(c context.Context, tx pgx.Tx, list *models.List, batch []*models.ImportRow, options *models.ImportOptions) (imported int, suppressed int, err error) {
+	emails := make([]string, len(batch))
+	normalized := make([]string, len(batch))
+	for i, row := range batch {
+		emails[i], normalized[i] = row.Email.String(), models.NormalizeEmail(row.Email)
+	}
+
+	existing, e := getExistingEmails(c, tx, list.ID, normalized)
+	if e != nil {
+		err = e
+		return
+	}
+	suppressedEmails, e := getSuppressedEmails(c, tx, emails)
+	if e != nil {
+		err = e
+		return
+	}
+
+	status := "pending"
+	var consentSource, consentedAt interface{}
+	now := time.Now()
+	if options.Confirmed {
+		status = "confirmed"
+		consentSource, consentedAt = options.ConsentSource, now
+	}
+
+	var subscribers, tokens [][]interface{}
+	var ids []string
+	for _, row := range batch {
+		if suppressedEmails[row.Email.String()] {
+			suppressed++
+			continue
+		}
+		if existing[models.NormalizeEmail(row.Email)] {
+			continue
+		}
+
+		id := uuid.NewString()
+		ids = append(ids, id)
+		subscribers = append(subscribers, []interface{}{
+			id, row.Email.String(), row.Name.String(), status, list.ID, now, consentSource, consentedAt,
+		})
+
+		if !options.Confirmed {
+			token, e := handlers.GenerateCSPRNG(tokenLength)
+			if e != nil {
+				err = fmt.Errorf("failed to generate subscription token: %w", e)
+				return
+			}
+			tokens = append(tokens, []interface{}{token, id})
+		}
+	}
+	if len(subscribers) == 0 {
+		return
+	}
+
+	copied, e := tx.CopyFrom(c, pgx.Identifier{"subscriptions"}, subscriberColumns, pgx.CopyFromRows(subscribers))
+	if e != nil {
+		err = fmt.Errorf("failed to copy subscribers: %w", e)
+		return
+	}
+	imported = int(copied)
+
+	if options.Confirmed {
+		err = sequences.EnqueSequences(c, tx, ids...)
+		return
+	}
+
+	columns := []string{"subscription_token", "subscriber_id"}
+	if _, e := tx.CopyFrom(c, pgx.Identifier{"subscription_tokens"}, columns, pgx.CopyFromRows(tokens)); e != nil {
+		err = fmt.Errorf("failed to copy subscription tokens: %w", e)
+		return
+	}
+
+	query := `INSERT INTO issue_delivery_queue (
+				newsletter_issue_id,
+				list_id,
+				subscriber_email
+			)
+			SELECT $1, list_id, email
+			FROM subscriptions
+			WHERE id = ANY($2)`
+	if _, e := tx.Exec(c, query, list.ConfirmationIssueID, ids); e != nil {
+		err = fmt.Errorf("failed to enque confirmation tasks: %w", e)
+		return
+	}
+
+	return
+}
+
+// existing subscribers are matched and returned by their normalized email
+func getExistingEmails(c context.Context, tx pgx.Tx, listID string, normalized []string) (existing map[string]bool, err error) {
+	query := "SELECT normalized_email FROM subscriptions WHERE list_id = $1 AND normalized_email = ANY($2)"
+	rows, e := tx.Query(c, query, listID, normalized)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch existing subscribers: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	found, e := pgx.CollectRows[string](rows, pgx.RowTo[string])
+	if e != nil {
+		err = fmt.Errorf("failed to parse existing subscribers: %w", e)
+		return
+	}
+
+	existing = make(map[string]bool, len(found))
+	for _, email := range found {
+		existing[email] = true
+	}
+
+	return
+}
+
+// suppressions are matched by address or by domain
+func getSuppressedEmails(c context.Context, tx pgx.Tx, emails []string) (suppressed map[string]bool, err error) {
+	query := "SELECT candidate.email FROM unnest($1::text[]) AS candidate(email) WHERE " + suppressions.Matches("candidate.email")
+	rows, e := tx.Query(c, query, emails)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch suppressions: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	found, e := pgx.CollectRows[string](rows, pgx.RowTo[string])
+	if e != nil {
+		err = fmt.Errorf("failed to parse suppressions: %w", e)
+		return
+	}
+
+	suppressed = make(map[string]bool, len(found))
+	for _, email := range found {
+		suppressed[email] = true
+	}
+
+	return
+}
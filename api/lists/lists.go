@@ -0,0 +1,171 @@
+package lists
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const DefaultListID = "00000000-0000-0000-0000-000000000000"
+
+// confirmation template seeded with the default list, shared by every list
+// without one of its own
+const BaseConfirmationIssueID = "00000000-0000-0000-0000-000000000000"
+
+var ErrListNotFound = errors.New("list not found")
+
+const listColumns = "list_id, slug, name, sender_name, sender_email, confirmation_issue_id, disposable_policy, role_policy"
+
+// GetList resolves a list by ID or slug, the default list when empty
+func GetList(c context.Context, db handlers.DatabaseInterface, identifier string) (list *models.List, err error) {
+	if identifier == "" {
+		identifier = models.DefaultListSlug
+	}
+
+	query := "SELECT " + listColumns + " FROM lists WHERE slug = $1"
+	if id, e := uuid.Parse(identifier); e == nil {
+		query = "SELECT " + listColumns + " FROM lists WHERE list_id = $1"
+		identifier = id.String()
+	}
+
+	rows, e := db.Query(c, query, identifier)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch list: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	list, e = pgx.CollectOneRow[*models.List](rows, BuildList)
+	if e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrListNotFound
+			return
+		}
+
+		err = fmt.Errorf("failed to parse list: %w", e)
+		return
+	}
+
+	return
+}
+
+func GetLists(c context.Context, db handlers.DatabaseInterface) (lists []*models.List, err error) {
+	rows, e := db.Query(c, "SELECT "+listColumns+" FROM lists ORDER BY name")
+	if e != nil {
+		err = fmt.Errorf("failed to fetch lists: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	lists, e = pgx.CollectRows[*models.List](rows, BuildList)
+	if e != nil {
+		err = fmt.Errorf("failed to parse lists: %w", e)
+		return
+	}
+
+	return
+}
+
+// InsertList stores the list and its confirmation template, lists without a
+// template share the base confirmation email
+func InsertList(c context.Context, tx pgx.Tx, list *models.List, confirmation *models.Body) (err error) {
+	list.ID = uuid.NewString()
+	list.ConfirmationIssueID = BaseConfirmationIssueID
+
+	if confirmation != nil {
+		list.ConfirmationIssueID = uuid.NewString()
+		query := `INSERT INTO newsletter_issues (
+					newsletter_issue_id,
+					title,
+					text_content,
+					html_content,
+					published_at
+				)
+				VALUES ($1, $2, $3, $4, now())`
+		_, e := tx.Exec(c, query, list.ConfirmationIssueID, confirmation.Title, confirmation.Text, confirmation.Html)
+		if e != nil {
+			err = fmt.Errorf("failed to insert confirmation template: %w", e)
+			return
+		}
+	}
+
+	var senderName, senderEmail *string
+	if list.SenderName != "" {
+		senderName = &list.SenderName
+	}
+	if list.SenderEmail != "" {
+		email := list.SenderEmail.String()
+		senderEmail = &email
+	}
+
+	query := `INSERT INTO lists (
+				list_id,
+				slug,
+				name,
+				sender_name,
+				sender_email,
+				confirmation_issue_id,
+				disposable_policy,
+				role_policy,
+				created
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())`
+	_, e := tx.Exec(c, query, list.ID, list.Slug, list.Name, senderName, senderEmail, list.ConfirmationIssueID, list.DisposablePolicy, list.RolePolicy)
+	if e != nil {
+		err = fmt.Errorf("failed to insert list: %w", e)
+		return
+	}
+
+	return
+}
+
+// UpdateListRules replaces the blocklist rules of the list, subscriptions
+// already accepted are unaffected
+func UpdateListRules(c context.Context, tx pgx.Tx, listID string, rules *models.ListRules) (err error) {
+	query := "UPDATE lists SET disposable_policy = $2, role_policy = $3 WHERE list_id = $1"
+	result, e := tx.Exec(c, query, listID, rules.DisposablePolicy, rules.RolePolicy)
+	if e != nil {
+		err = fmt.Errorf("failed to update list rules: %w", e)
+		return
+	}
+	if result.RowsAffected() == 0 {
+		err = ErrListNotFound
+		return
+	}
+
+	return
+}
+
+func BuildList(row pgx.CollectableRow) (list *models.List, err error) {
+	var senderName, senderEmail *string
+
+	list = &models.List{}
+	e := row.Scan(
+		&list.ID,
+		&list.Slug,
+		&list.Name,
+		&senderName,
+		&senderEmail,
+		&list.ConfirmationIssueID,
+		&list.DisposablePolicy,
+		&list.RolePolicy,
+	)
+	if e != nil {
+		err = fmt.Errorf("database error: %w", e)
+		return
+	}
+
+	if senderName != nil {
+		list.SenderName = *senderName
+	}
+	if senderEmail != nil {
+		list.SenderEmail = models.SubscriberEmail(*senderEmail)
+	}
+
+	return
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	// "sync"
 	"time"
 
@@ -25,12 +28,16 @@ import (
 	"go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/semconv/v1.18.0"
 
+	"github.com/solomonbaez/hyacinth/api/blocklist"
 	"github.com/solomonbaez/hyacinth/api/blog"
+	"github.com/solomonbaez/hyacinth/api/bounces"
 	"github.com/solomonbaez/hyacinth/api/clients"
 	"github.com/solomonbaez/hyacinth/api/configs"
 	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
 	"github.com/solomonbaez/hyacinth/api/routes"
 	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/signing"
 	"github.com/solomonbaez/hyacinth/api/workers"
 )
 
@@ -48,6 +55,15 @@ var readHeaderTimeout = 5 * time.Second
 
 var app *App
 var client *clients.SMTPClient
+var attachmentCFG *configs.AttachmentSettings
+var signer *signing.Signer
+var bounceCFG *configs.BounceSettings
+var verp *bounces.VERP
+var webhookSigner *signing.Signer
+var emailBlocklist *blocklist.Blocklist
+var sunsetCFG *configs.SunsetSettings
+var normalizationCFG *configs.NormalizationSettings
+var frequencyCap *configs.FrequencyCapSettings
 
 func init() {
 	appCFG, e := configs.ConfigureApp()
@@ -73,6 +89,84 @@ func init() {
 			Err(e).
 			Msg("Failed to create new SMTP Client")
 	}
+
+	attachmentCFG, e = configs.ConfigureAttachments()
+	if e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Failed to read attachment config")
+	}
+
+	signingCFG, e := configs.ConfigureSigning()
+	if e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Failed to read signing config")
+	}
+	signer = signing.NewSigner(signingCFG.Secret, time.Duration(signingCFG.PreferencesExpiry)*24*time.Hour)
+
+	bounceCFG, e = configs.ConfigureBounces()
+	if e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Failed to read bounce config")
+	}
+	verp = bounces.NewVERP(bounceCFG, signer)
+
+	webhookCFG, e := configs.ConfigureWebhooks()
+	if e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Failed to read webhook config")
+	}
+	if webhookCFG.Secret != "" {
+		webhookSigner = signing.NewSigner(webhookCFG.Secret, 0)
+	}
+
+	sunsetCFG, e = configs.ConfigureSunset()
+	if e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Failed to read sunset config")
+	}
+	reengagement := &models.Body{Title: sunsetCFG.Title, Text: sunsetCFG.Text, Html: sunsetCFG.Html}
+	if e := models.ParseTemplates(reengagement); e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Invalid re-engagement email")
+	}
+
+	normalizationCFG, e = configs.ConfigureNormalization()
+	if e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Failed to read normalization config")
+	}
+
+	frequencyCapCFG, e := configs.ConfigureFrequencyCap()
+	if e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Failed to read frequency cap config")
+	}
+	if frequencyCapCFG.MaxMessages > 0 {
+		frequencyCap = frequencyCapCFG
+	}
+
+	blocklistCFG, e := configs.ConfigureBlocklist()
+	if e != nil {
+		log.Fatal().
+			Err(e).
+			Msg("Failed to read blocklist config")
+	}
+	if blocklistCFG.File != "" {
+		emailBlocklist, e = blocklist.NewBlocklist(blocklistCFG.File)
+		if e != nil {
+			log.Fatal().
+				Err(e).
+				Msg("Failed to load blocklist")
+		}
+	}
 }
 
 var enableTracing = false
@@ -107,7 +201,17 @@ func main() {
 	dh := handlers.NewDatabaseHandler(pool)
 
 	go workers.PruningWorker(parentContext, dh)
-	go workers.DeliveryWorker(parentContext, dh, client)
+	go workers.DeliveryWorker(parentContext, dh, client, signer, verp, frequencyCap)
+	go workers.SubjectTestWorker(parentContext, dh)
+	if sunsetCFG.InactiveIssues > 0 {
+		go workers.SunsetWorker(parentContext, dh, sunsetCFG)
+	}
+	if verp != nil {
+		go workers.BounceWorker(parentContext, dh, bounceCFG, verp)
+	}
+	if emailBlocklist != nil {
+		go reloadBlocklistOnHangup()
+	}
 
 	router, listener, e := initializeServer(dh)
 	if e != nil {
@@ -139,6 +243,25 @@ func main() {
 	}
 }
 
+// the blocklist file is maintained by hand, SIGHUP picks up edits
+func reloadBlocklistOnHangup() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		if e := emailBlocklist.Reload(); e != nil {
+			log.Error().
+				Err(e).
+				Msg("Failed to reload blocklist")
+
+			continue
+		}
+
+		log.Info().
+			Msg("Blocklist reloaded")
+	}
+}
+
 func initializeTracing() (err error) {
 	exporter, e := stdouttrace.New(stdouttrace.WithPrettyPrint())
 	if e != nil {
@@ -224,23 +347,72 @@ func initializeServer(dh *handlers.DatabaseHandler) (router *gin.Engine, listene
 	// define admin group
 	admin := router.Group("/admin")
 	admin.Use(AdminMiddleware())
-	admin.GET("/dashboard", adminRoutes.GetAdminDashboard)
+	admin.GET("/dashboard", func(c *gin.Context) { adminRoutes.GetAdminDashboard(c, dh) })
 	admin.GET("/password", adminRoutes.GetChangePassword)
 	admin.POST("/password", func(c *gin.Context) { adminRoutes.PostChangePassword(c, dh) })
 	admin.GET("/logout", adminRoutes.Logout)
 	admin.GET("/subscribers", func(c *gin.Context) { adminRoutes.GetSubscribers(c, dh) })
+	admin.GET("/subscribers/export", func(c *gin.Context) { adminRoutes.GetSubscriberExport(c, dh) })
+	admin.GET("/subscribers/collisions", func(c *gin.Context) { adminRoutes.GetEmailCollisions(c, dh, normalizationCFG) })
+	admin.GET("/subscribers/flagged", func(c *gin.Context) { adminRoutes.GetFlaggedSubscribers(c, dh) })
 	admin.GET("/subscribers/:id", func(c *gin.Context) { adminRoutes.GetSubscriberByID(c, dh) })
-	admin.GET("/newsletter", adminRoutes.GetNewsletter)
-	admin.POST("/newsletter", func(c *gin.Context) { adminRoutes.PostNewsletter(c, dh, client) })
+	admin.POST("/subscribers/import", func(c *gin.Context) { adminRoutes.PostSubscriberImport(c, dh) })
+	admin.GET("/subscribers/:id/manage", func(c *gin.Context) { adminRoutes.GetManageSubscriber(c, dh) })
+	admin.POST("/subscribers/:id/edit", func(c *gin.Context) { adminRoutes.PostSubscriberEdit(c, dh) })
+	admin.POST("/subscribers/:id/confirm", func(c *gin.Context) { adminRoutes.PostSubscriberConfirm(c, dh) })
+	admin.POST("/subscribers/:id/resend", func(c *gin.Context) { adminRoutes.PostSubscriberResendConfirmation(c, dh) })
+	admin.POST("/subscribers/:id/suppress", func(c *gin.Context) { adminRoutes.PostSubscriberSuppress(c, dh) })
+	admin.POST("/subscribers/:id/delete", func(c *gin.Context) { adminRoutes.PostSubscriberDelete(c, dh) })
+	admin.GET("/subscribers/:id/engagement", func(c *gin.Context) { adminRoutes.GetSubscriberEngagement(c, dh, sunsetCFG) })
+	admin.GET("/subscribers/:id/data", func(c *gin.Context) { adminRoutes.GetSubscriberData(c, dh) })
+	admin.POST("/subscribers/:id/erase", func(c *gin.Context) { adminRoutes.PostSubscriberErase(c, dh) })
+	admin.POST("/subscribers/:id/tags", func(c *gin.Context) { adminRoutes.PostSubscriberTags(c, dh) })
+	admin.DELETE("/subscribers/:id/tags/:tag", func(c *gin.Context) { adminRoutes.DeleteSubscriberTag(c, dh) })
+	admin.PUT("/subscribers/:id/attributes", func(c *gin.Context) { adminRoutes.PutSubscriberAttributes(c, dh) })
+	admin.GET("/suppressions", func(c *gin.Context) { adminRoutes.GetSuppressions(c, dh) })
+	admin.POST("/suppressions", func(c *gin.Context) { adminRoutes.PostSuppression(c, dh) })
+	admin.POST("/suppressions/import", func(c *gin.Context) { adminRoutes.PostSuppressionImport(c, dh) })
+	admin.DELETE("/suppressions/:id", func(c *gin.Context) { adminRoutes.DeleteSuppression(c, dh) })
+	admin.GET("/fields", func(c *gin.Context) { adminRoutes.GetFields(c, dh) })
+	admin.POST("/fields", func(c *gin.Context) { adminRoutes.PostField(c, dh) })
+	admin.DELETE("/fields/:key", func(c *gin.Context) { adminRoutes.DeleteField(c, dh) })
+	admin.GET("/lists", func(c *gin.Context) { adminRoutes.GetLists(c, dh) })
+	admin.POST("/lists", func(c *gin.Context) { adminRoutes.PostList(c, dh) })
+	admin.PUT("/lists/:id/rules", func(c *gin.Context) { adminRoutes.PutListRules(c, dh) })
+	admin.POST("/blocklist/reload", func(c *gin.Context) { adminRoutes.PostBlocklistReload(c, emailBlocklist) })
+	admin.GET("/segments", func(c *gin.Context) { adminRoutes.GetSegments(c, dh) })
+	admin.POST("/segments", func(c *gin.Context) { adminRoutes.PostSegment(c, dh) })
+	admin.GET("/segments/preview", func(c *gin.Context) { adminRoutes.GetSegmentPreview(c, dh) })
+	admin.DELETE("/segments/:id", func(c *gin.Context) { adminRoutes.DeleteSegment(c, dh) })
+	admin.GET("/sequences", func(c *gin.Context) { adminRoutes.GetSequences(c, dh) })
+	admin.POST("/sequences", func(c *gin.Context) { adminRoutes.PostSequence(c, dh) })
+	admin.DELETE("/sequences/:id", func(c *gin.Context) { adminRoutes.DeleteSequence(c, dh) })
+	admin.GET("/newsletter", func(c *gin.Context) { adminRoutes.GetNewsletter(c, dh) })
+	admin.POST("/newsletter", func(c *gin.Context) { adminRoutes.PostNewsletter(c, dh, client, attachmentCFG) })
+	admin.GET("/reports/growth", func(c *gin.Context) { adminRoutes.GetGrowthReport(c, dh) })
 	admin.GET("/issues", func(c *gin.Context) { blog.GetNewlsetterIssues(c, dh) })
 	admin.GET("/issues/:title", func(c *gin.Context) { blog.GetNewlsetterIssueByTitle(c, dh) })
+	admin.GET("/issues/:title/analytics", func(c *gin.Context) { adminRoutes.GetIssueAnalytics(c, dh) })
+	admin.GET("/issues/:title/subject-test", func(c *gin.Context) { adminRoutes.GetSubjectTest(c, dh) })
 
 	router.GET("/debug/pprof/:id", gin.WrapH(http.DefaultServeMux))
 	router.GET("/health", handlers.HealthCheck)
 	router.GET("/login", routes.GetLogin)
 	router.POST("/login", func(c *gin.Context) { routes.PostLogin(c, dh) })
-	router.POST("/subscribe", func(c *gin.Context) { routes.Subscribe(c, dh) })
+	router.POST("/subscribe", func(c *gin.Context) { routes.Subscribe(c, dh, emailBlocklist) })
 	router.GET("/confirm/:token", func(c *gin.Context) { routes.ConfirmSubscriber(c, dh) })
+	router.GET("/preferences/:token", func(c *gin.Context) { routes.GetPreferences(c, dh, signer) })
+	router.POST("/preferences/:token", func(c *gin.Context) { routes.PostPreferences(c, dh, signer) })
+	router.POST("/preferences/:token/unsubscribe", func(c *gin.Context) { routes.PostOneClickUnsubscribe(c, dh, signer) })
+	router.POST("/preferences/:token/email", func(c *gin.Context) { routes.PostEmailChange(c, dh, signer) })
+	router.POST("/preferences/:token/data", func(c *gin.Context) { routes.PostDataExport(c, dh, signer) })
+	router.GET("/data-export/:token", func(c *gin.Context) { routes.GetDataExport(c, dh) })
+	router.GET("/confirm-email/:token", func(c *gin.Context) { routes.ConfirmEmailChange(c, dh) })
+	router.GET("/t/o/:token", func(c *gin.Context) { routes.GetOpenPixel(c, dh, signer) })
+	router.GET("/t/c/:token", func(c *gin.Context) { routes.GetClickRedirect(c, dh, signer) })
+	if webhookSigner != nil {
+		router.POST("/webhooks/mail-events", func(c *gin.Context) { routes.PostMailEvents(c, dh, webhookSigner, bounceCFG, verp) })
+	}
 
 	// listener
 	listener, e = net.Listen("tcp", fmt.Sprintf("localhost:%v", app.port))
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	textTemplate "text/template"
+	"time"
+)
+
+const (
+	SubjectMetricOpen  = "open"
+	SubjectMetricClick = "click"
+)
+
+const (
+	maxSubjectVariants = 5
+	maxSamplePercent   = 90
+	// one week
+	maxTestWindowHours = 168
+)
+
+// SubjectTest sends each subject line to an equal share of a random sample
+// of the audience. Once the window has passed the variant with the best
+// open or click rate goes to the remaining recipients.
+type SubjectTest struct {
+	IssueID       string            `json:"issue_id"`
+	Variants      []*SubjectVariant `json:"variants"`
+	SamplePercent int               `json:"sample_percent"`
+	Metric        string            `json:"metric"`
+	WindowHours   int               `json:"window_hours,omitempty"`
+	DecideAfter   *time.Time        `json:"decide_after"`
+	Winner        *int              `json:"winner"`
+	Decided       *time.Time        `json:"decided"`
+}
+
+// SubjectVariant is numbered from 1, Sent and Engaged are only filled in
+// when results are read
+type SubjectVariant struct {
+	Variant int     `json:"variant"`
+	Subject string  `json:"subject"`
+	Sent    int     `json:"sent"`
+	Engaged int     `json:"engaged"`
+	Rate    float64 `json:"rate"`
+}
+
+// ParseSubjectTest validates the test and numbers its variants, the metric
+// must be tracked by the issue
+func ParseSubjectTest(test *SubjectTest, tracking Tracking) (err error) {
+	if len(test.Variants) < 2 || len(test.Variants) > maxSubjectVariants {
+		err = fmt.Errorf("subject tests need between 2 and %d subject lines", maxSubjectVariants)
+		return
+	}
+
+	seen := map[string]bool{}
+	for i, variant := range test.Variants {
+		variant.Subject = strings.TrimSpace(variant.Subject)
+		if variant.Subject == "" {
+			err = fmt.Errorf("subject line %d is empty", i+1)
+			return
+		}
+		if seen[variant.Subject] {
+			err = fmt.Errorf("subject line %d is repeated", i+1)
+			return
+		}
+		if _, e := textTemplate.New("title").Parse(variant.Subject); e != nil {
+			err = fmt.Errorf("invalid subject line %d: %w", i+1, e)
+			return
+		}
+
+		seen[variant.Subject] = true
+		variant.Variant = i + 1
+	}
+
+	if test.SamplePercent < 1 || test.SamplePercent > maxSamplePercent {
+		err = fmt.Errorf("sample must be between 1 and %d percent", maxSamplePercent)
+		return
+	}
+	if test.WindowHours < 1 || test.WindowHours > maxTestWindowHours {
+		err = fmt.Errorf("test window must be between 1 and %d hours", maxTestWindowHours)
+		return
+	}
+
+	switch test.Metric {
+	case SubjectMetricOpen:
+		if !tracking.Opens {
+			err = fmt.Errorf("open tracking is required to test open rates")
+			return
+		}
+	case SubjectMetricClick:
+		if !tracking.Clicks {
+			err = fmt.Errorf("click tracking is required to test click rates")
+			return
+		}
+	default:
+		err = fmt.Errorf("invalid test metric: %s", test.Metric)
+		return
+	}
+
+	return
+}
+
+// PickWinner returns the variant with the best rate, ties go to the earlier
+// variant
+func (test *SubjectTest) PickWinner() (winner int) {
+	best := -1.0
+	for _, variant := range test.Variants {
+		variant.Rate = 0
+		if variant.Sent > 0 {
+			variant.Rate = float64(variant.Engaged) / float64(variant.Sent)
+		}
+
+		if variant.Rate > best {
+			best = variant.Rate
+			winner = variant.Variant
+		}
+	}
+
+	return
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxLocalPartLength = 64
+	maxLabelLength     = 63
+)
+
+var errInvalidEmail = errors.New("invalid email format")
+
+// parseAddrSpec validates an RFC 5322 addr-spec, extended by RFC 6531 to
+// UTF-8 local parts and internationalized domains. The local part is kept as
+// written, non-ASCII domains are converted to Punycode so the address can be
+// routed by any server.
+func parseAddrSpec(address string) (parsed string, err error) {
+	at := strings.LastIndexByte(address, '@')
+	if at < 1 || at == len(address)-1 {
+		err = errInvalidEmail
+		return
+	}
+
+	local, domain := address[:at], address[at+1:]
+	if !utf8.ValidString(address) || len(local) > maxLocalPartLength {
+		err = errInvalidEmail
+		return
+	}
+	if strings.HasPrefix(local, `"`) {
+		if !isQuotedString(local) {
+			err = errInvalidEmail
+			return
+		}
+	} else if !isDotAtom(local) {
+		err = errInvalidEmail
+		return
+	}
+
+	if domain, err = parseDomain(domain); err != nil {
+		return
+	}
+
+	parsed = local + "@" + domain
+	return
+}
+
+// ASCII domains are kept as written, Punycode conversion also lowercases
+func parseDomain(domain string) (parsed string, err error) {
+	ascii, e := idna.Lookup.ToASCII(domain)
+	if e != nil {
+		err = errInvalidEmail
+		return
+	}
+
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 || len(ascii) > maxDomainLength {
+		err = errInvalidEmail
+		return
+	}
+	for _, label := range labels {
+		if !isHostLabel(label) {
+			err = errInvalidEmail
+			return
+		}
+	}
+	// top level domains are never numeric
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		err = errInvalidEmail
+		return
+	}
+
+	parsed = ascii
+	if isASCII(domain) {
+		parsed = domain
+	}
+
+	return
+}
+
+func isDotAtom(local string) bool {
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			if !isAtext(r) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func isQuotedString(local string) bool {
+	if len(local) < 2 || !strings.HasSuffix(local, `"`) {
+		return false
+	}
+
+	escaped := false
+	for _, r := range local[1 : len(local)-1] {
+		switch {
+		case escaped:
+			if r < ' ' || r == 0x7f {
+				return false
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"' || r < ' ' || r == 0x7f:
+			return false
+		}
+	}
+
+	return !escaped
+}
+
+// RFC 5322 atext, RFC 6531 adds every non-ASCII character
+func isAtext(r rune) bool {
+	switch {
+	case r >= utf8.RuneSelf:
+		return true
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return true
+	}
+
+	return strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
+
+func isHostLabel(label string) bool {
+	if label == "" || len(label) > maxLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RequiresSMTPUTF8 reports whether the address can only be delivered by
+// servers supporting SMTPUTF8, i.e. its local part is not ASCII
+func (email SubscriberEmail) RequiresSMTPUTF8() bool {
+	return !isASCII(email.String())
+}
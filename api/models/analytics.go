@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// IssueAnalytics summarises the delivery and engagement of an issue. Opens
+// include recipients who clicked without loading images.
+type IssueAnalytics struct {
+	IssueID      string        `json:"issue_id"`
+	Title        string        `json:"title"`
+	Sent         int           `json:"sent"`
+	Delivered    int           `json:"delivered"`
+	Bounced      int           `json:"bounced"`
+	UniqueOpens  int           `json:"unique_opens"`
+	UniqueClicks int           `json:"unique_clicks"`
+	Unsubscribes int           `json:"unsubscribes"`
+	OpenRate     float64       `json:"open_rate"`
+	ClickRate    float64       `json:"click_rate"`
+	Links        []*LinkClicks `json:"links"`
+	Opens        []*OpenBucket `json:"opens"`
+}
+
+// LinkClicks counts the clicks on a single link of the issue
+type LinkClicks struct {
+	URL          string `json:"url"`
+	Clicks       int    `json:"clicks"`
+	UniqueClicks int    `json:"unique_clicks"`
+}
+
+// OpenBucket counts the first opens in the hour starting at Hour
+type OpenBucket struct {
+	Hour  time.Time `json:"hour"`
+	Opens int       `json:"opens"`
+}
+
+var AnalyticsCSVHeader = []string{"section", "label", "count", "unique"}
+
+// Rates derives the open and click rates from the delivered count
+func (analytics *IssueAnalytics) Rates() {
+	analytics.Delivered = analytics.Sent - analytics.Bounced
+	if analytics.Delivered <= 0 {
+		analytics.Delivered = 0
+		analytics.OpenRate, analytics.ClickRate = 0, 0
+		return
+	}
+
+	analytics.OpenRate = float64(analytics.UniqueOpens) / float64(analytics.Delivered)
+	analytics.ClickRate = float64(analytics.UniqueClicks) / float64(analytics.Delivered)
+}
+
+// CSVRecords flattens the summary, links and opens series into one table,
+// unique is left empty where it does not apply
+func (analytics *IssueAnalytics) CSVRecords() (records [][]string) {
+	summary := []struct {
+		label string
+		count int
+	}{
+		{"sent", analytics.Sent},
+		{"delivered", analytics.Delivered},
+		{"bounced", analytics.Bounced},
+		{"opens", analytics.UniqueOpens},
+		{"clicks", analytics.UniqueClicks},
+		{"unsubscribes", analytics.Unsubscribes},
+	}
+	for _, row := range summary {
+		records = append(records, []string{"summary", row.label, strconv.Itoa(row.count), ""})
+	}
+
+	for _, link := range analytics.Links {
+		records = append(records, []string{"link", link.URL, strconv.Itoa(link.Clicks), strconv.Itoa(link.UniqueClicks)})
+	}
+
+	for _, bucket := range analytics.Opens {
+		records = append(records, []string{"opens", bucket.Hour.UTC().Format(time.RFC3339), strconv.Itoa(bucket.Opens), ""})
+	}
+
+	return
+}
+
+func (analytics *IssueAnalytics) Filename() string {
+	return fmt.Sprintf("issue-%s-analytics.csv", analytics.IssueID)
+}
+
+// Percent formats a rate for display
+func (analytics *IssueAnalytics) Percent(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', 1, 64) + "%"
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const maxFilenameLength = 255
+
+type Attachment struct {
+	ID          string
+	Filename    string
+	ContentType string
+	// inline images are referenced from the html body as cid:<ContentID>
+	ContentID string
+	Inline    bool
+	Content   []byte
+}
+
+func ParseFilename(filename string) (parsed string, err error) {
+	base := filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+
+	// empty field check
+	emptyField := strings.Trim(base, " ")
+	if emptyField == "" || base == "." || base == "/" || base == ".." {
+		err = errors.New("filename cannot be empty or whitespace")
+		return
+	}
+
+	// length checks
+	if len(base) > maxFilenameLength {
+		err = fmt.Errorf("filename exceeds maximum length of: %d characters", maxFilenameLength)
+		return
+	}
+
+	// header injection check
+	for _, r := range base {
+		if unicode.IsControl(r) || r == '"' {
+			err = fmt.Errorf("invalid character in filename: %q", r)
+			return
+		}
+	}
+
+	parsed = base
+	return
+}
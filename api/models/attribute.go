@@ -0,0 +1,181 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	maxFieldLabelLength     = 100
+	maxAttributeValueLength = 255
+	FieldTypeString         = "string"
+	FieldTypeNumber         = "number"
+	FieldTypeDate           = "date"
+	FieldTypeBoolean        = "boolean"
+)
+
+var (
+	fieldKeyRegex = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+)
+
+type FieldDefinition struct {
+	Key   string `json:"key" binding:"required"`
+	Label string `json:"label" binding:"required"`
+	Type  string `json:"type" binding:"required"`
+}
+
+// Attributes holds typed custom field values keyed by field key, dates are
+// stored as calendar dates
+type Attributes map[string]interface{}
+
+// Strings formats every value for use in per-recipient templates
+func (attributes Attributes) Strings() map[string]string {
+	formatted := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		switch v := value.(type) {
+		case float64:
+			formatted[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			formatted[key] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	return formatted
+}
+
+func ParseFieldKey(key string) (parsed string, err error) {
+	if !fieldKeyRegex.MatchString(key) {
+		err = fmt.Errorf("invalid field key format: %s", key)
+		return
+	}
+
+	parsed = key
+	return
+}
+
+func ParseFieldDefinition(definition *FieldDefinition) (err error) {
+	if definition.Key, err = ParseFieldKey(definition.Key); err != nil {
+		return
+	}
+
+	if definition.Label, err = parseDisplayName(definition.Label); err != nil {
+		err = fmt.Errorf("invalid field label: %w", err)
+		return
+	}
+
+	switch definition.Type {
+	case FieldTypeString, FieldTypeNumber, FieldTypeDate, FieldTypeBoolean:
+	default:
+		err = fmt.Errorf("invalid field type: %s", definition.Type)
+	}
+
+	return
+}
+
+// ParseAttributes validates raw values against their field definitions,
+// null values are kept so callers can use them to clear an attribute
+func ParseAttributes(raw map[string]interface{}, definitions []*FieldDefinition) (attributes Attributes, err error) {
+	byKey := make(map[string]*FieldDefinition, len(definitions))
+	for _, definition := range definitions {
+		byKey[definition.Key] = definition
+	}
+
+	parsed := make(Attributes, len(raw))
+	for key, value := range raw {
+		definition, ok := byKey[key]
+		if !ok {
+			err = fmt.Errorf("unknown field: %s", key)
+			return
+		}
+
+		if value == nil {
+			parsed[key] = nil
+			continue
+		}
+
+		parsed[key], err = ParseAttribute(definition, value)
+		if err != nil {
+			err = fmt.Errorf("invalid value for %s: %w", key, err)
+			return
+		}
+	}
+
+	attributes = parsed
+	return
+}
+
+// accepts native JSON values as well as their form encoded string forms
+func ParseAttribute(definition *FieldDefinition, value interface{}) (parsed interface{}, err error) {
+	switch definition.Type {
+	case FieldTypeString:
+		s, ok := value.(string)
+		if !ok {
+			err = errors.New("expected a string")
+			return
+		}
+		if len(s) > maxAttributeValueLength {
+			err = fmt.Errorf("value exceeds maximum length of: %d characters", maxAttributeValueLength)
+			return
+		}
+		for _, r := range s {
+			if unicode.IsControl(r) || strings.ContainsRune("{}<>", r) {
+				err = fmt.Errorf("invalid character in value: %q", r)
+				return
+			}
+		}
+		parsed = s
+
+	case FieldTypeNumber:
+		switch v := value.(type) {
+		case float64:
+			parsed = v
+		case string:
+			f, e := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if e != nil {
+				err = errors.New("expected a number")
+				return
+			}
+			parsed = f
+		default:
+			err = errors.New("expected a number")
+		}
+
+	case FieldTypeDate:
+		s, ok := value.(string)
+		if !ok {
+			err = errors.New("expected a date")
+			return
+		}
+		date, e := ParseDate(s)
+		if e != nil {
+			err = e
+			return
+		}
+		parsed = date.Format(time.DateOnly)
+
+	case FieldTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			parsed = v
+		case string:
+			b, e := strconv.ParseBool(strings.TrimSpace(v))
+			if e != nil {
+				err = errors.New("expected a boolean")
+				return
+			}
+			parsed = b
+		default:
+			err = errors.New("expected a boolean")
+		}
+
+	default:
+		err = fmt.Errorf("invalid field type: %s", definition.Type)
+	}
+
+	return
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// AuditActorSubscriber marks self-service actions taken through a signed link
+const AuditActorSubscriber = "subscriber"
+
+// AuditActorSunset marks subscribers unsubscribed by the sunset policy
+const AuditActorSunset = "sunset"
+
+const (
+	AuditActionUpdate             = "subscriber.update"
+	AuditActionConfirm            = "subscriber.confirm"
+	AuditActionResendConfirmation = "subscriber.resend_confirmation"
+	AuditActionSuppress           = "subscriber.suppress"
+	AuditActionDelete             = "subscriber.delete"
+	AuditActionExport             = "subscriber.export"
+	AuditActionErase              = "subscriber.erase"
+	AuditActionSunset             = "subscriber.sunset"
+)
+
+// AuditEntry records an admin mutation, SubscriberID outlives deleted
+// subscribers so their history stays readable
+type AuditEntry struct {
+	ID           string                 `json:"id"`
+	Actor        string                 `json:"actor"`
+	Action       string                 `json:"action"`
+	SubscriberID string                 `json:"subscriber_id"`
+	Details      map[string]interface{} `json:"details"`
+	Created      time.Time              `json:"created"`
+}
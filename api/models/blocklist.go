@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// policies a list applies to blocklisted addresses
+const (
+	BlockPolicyAllow  = "allow"
+	BlockPolicyFlag   = "flag"
+	BlockPolicyReject = "reject"
+)
+
+// reasons an address matches the blocklist, stored as the subscription flag
+const (
+	BlockReasonDisposable = "disposable"
+	BlockReasonRole       = "role"
+)
+
+// ListRules decide what happens when an address subscribing to the list
+// matches the blocklist
+type ListRules struct {
+	DisposablePolicy string `json:"disposable_policy"`
+	RolePolicy       string `json:"role_policy"`
+}
+
+// Policy returns the rule for a blocklist match, matches without a rule are
+// allowed
+func (rules *ListRules) Policy(reason string) string {
+	switch reason {
+	case BlockReasonDisposable:
+		return rules.DisposablePolicy
+	case BlockReasonRole:
+		return rules.RolePolicy
+	}
+
+	return BlockPolicyAllow
+}
+
+func ParseListRules(rules *ListRules) (err error) {
+	if rules.DisposablePolicy, err = parseBlockPolicy(rules.DisposablePolicy); err != nil {
+		return
+	}
+	if rules.RolePolicy, err = parseBlockPolicy(rules.RolePolicy); err != nil {
+		return
+	}
+
+	return
+}
+
+// lists default to allowing every address
+func parseBlockPolicy(policy string) (parsed string, err error) {
+	switch policy {
+	case "":
+		parsed = BlockPolicyAllow
+	case BlockPolicyAllow, BlockPolicyFlag, BlockPolicyReject:
+		parsed = policy
+	default:
+		err = fmt.Errorf("invalid blocklist policy: %s", policy)
+	}
+
+	return
+}
+
+// FlaggedSubscriber is a subscription accepted under a flag rule
+type FlaggedSubscriber struct {
+	ID      string          `json:"id"`
+	Email   SubscriberEmail `json:"email"`
+	Name    SubscriberName  `json:"name"`
+	Status  string          `json:"status"`
+	ListID  string          `json:"list_id"`
+	Flag    string          `json:"flag"`
+	Created time.Time       `json:"created"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	BounceKindHard = "hard"
+	BounceKindSoft = "soft"
+)
+
+// Bounce is a failed delivery reported by a delivery status notification or
+// a mail event webhook. DSNs are attributed to the delivery through their
+// VERP return path, webhook events only when they carry a delivery ID.
+type Bounce struct {
+	ID         string          `json:"id"`
+	MessageID  string          `json:"message_id"`
+	DeliveryID *string         `json:"delivery_id"`
+	IssueID    *string         `json:"issue_id"`
+	ListID     *string         `json:"list_id"`
+	Email      SubscriberEmail `json:"email"`
+	Kind       string          `json:"kind"`
+	Status     string          `json:"status"`
+	Diagnostic string          `json:"diagnostic"`
+	Received   time.Time       `json:"received"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	EngagementOpen  = "open"
+	EngagementClick = "click"
+)
+
+// Tracking is the engagement an issue records, both are off unless the
+// issue opts in
+type Tracking struct {
+	Opens  bool
+	Clicks bool
+}
+
+// EngagementEvent is an open or click attributed to a delivery, URL is only
+// set for clicks
+type EngagementEvent struct {
+	ID         string          `json:"id"`
+	DeliveryID string          `json:"delivery_id"`
+	IssueID    string          `json:"issue_id"`
+	ListID     string          `json:"list_id"`
+	Email      SubscriberEmail `json:"email"`
+	Type       string          `json:"type"`
+	URL        *string         `json:"url"`
+	Created    time.Time       `json:"created"`
+}
+
+// EngagementScore rates a subscriber from 0 to 100 over their last tracked
+// deliveries, an open scores half and a click the full delivery. Score is
+// nil until a tracked issue was delivered.
+type EngagementScore struct {
+	SubscriberID     string     `json:"subscriber_id"`
+	Deliveries       int        `json:"deliveries"`
+	Opened           int        `json:"opened"`
+	Clicked          int        `json:"clicked"`
+	Score            *int       `json:"score"`
+	LastEngaged      *time.Time `json:"last_engaged"`
+	ReengagementSent *time.Time `json:"reengagement_sent"`
+}
+
+func (score *EngagementScore) Compute() {
+	score.Score = nil
+	if score.Deliveries == 0 {
+		return
+	}
+
+	value := (score.Opened + score.Clicked) * 100 / (2 * score.Deliveries)
+	score.Score = &value
+}
+
+// SunsetRun counts what a single run of the sunset policy did
+type SunsetRun struct {
+	Reengaged int
+	Contacted int
+	Sunset    int
+}
+
+// user agents of crawlers, link scanners and prefetching proxies, matched
+// case insensitively
+var automatedAgents = []string{
+	"bot", "crawler", "spider", "slurp", "preview", "prefetch", "scanner",
+	"facebookexternalhit", "barracuda", "mimecast", "proofpoint", "symantec",
+	"curl", "wget", "python-requests", "go-http-client", "headlesschrome",
+}
+
+// IsAutomatedAgent reports whether a request was made by software rather
+// than a reader, such requests are not recorded as engagement
+func IsAutomatedAgent(userAgent string) bool {
+	agent := strings.ToLower(strings.TrimSpace(userAgent))
+	if agent == "" {
+		return true
+	}
+
+	for _, automated := range automatedAgents {
+		if strings.Contains(agent, automated) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultEmailColumn = "email"
+	DefaultNameColumn  = "name"
+)
+
+// ImportOptions maps CSV headers onto subscriber fields, pre-confirmed
+// imports must record where consent was collected
+type ImportOptions struct {
+	EmailColumn   string
+	NameColumn    string
+	Confirmed     bool
+	ConsentSource string
+}
+
+type ImportRow struct {
+	Row   int
+	Email SubscriberEmail
+	Name  SubscriberName
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	Imported   int               `json:"imported"`
+	Duplicates int               `json:"duplicates"`
+	Suppressed int               `json:"suppressed"`
+	Errors     []*ImportRowError `json:"errors"`
+}
+
+func ParseImportOptions(options *ImportOptions) (err error) {
+	options.EmailColumn = strings.TrimSpace(options.EmailColumn)
+	if options.EmailColumn == "" {
+		options.EmailColumn = DefaultEmailColumn
+	}
+	options.NameColumn = strings.TrimSpace(options.NameColumn)
+	if options.NameColumn == "" {
+		options.NameColumn = DefaultNameColumn
+	}
+
+	if !options.Confirmed {
+		options.ConsentSource = ""
+		return
+	}
+	if strings.TrimSpace(options.ConsentSource) == "" {
+		err = errors.New("pre-confirmed imports require a consent source")
+		return
+	}
+	if options.ConsentSource, err = parseDisplayName(options.ConsentSource); err != nil {
+		err = fmt.Errorf("invalid consent source: %w", err)
+		return
+	}
+
+	return
+}
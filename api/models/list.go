@@ -0,0 +1,140 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxSlugLength     = 50
+	maxListNameLength = 100
+	DefaultListSlug   = "default"
+	// confirmation templates must render the confirmation link
+	ConfirmationLinkPlaceholder = "{{.link}}"
+)
+
+var (
+	slugRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+type List struct {
+	ID                  string          `json:"id"`
+	Slug                string          `json:"slug" binding:"required"`
+	Name                string          `json:"name" binding:"required"`
+	SenderName          string          `json:"sender_name"`
+	SenderEmail         SubscriberEmail `json:"sender_email"`
+	ConfirmationIssueID string          `json:"confirmation_issue_id"`
+	ListRules
+}
+
+// Sender returns the identity list mail is sent from, nil when the list uses
+// the email client default
+func (list *List) Sender() *Sender {
+	if list.SenderEmail == "" {
+		return nil
+	}
+
+	return &Sender{Name: list.SenderName, Email: list.SenderEmail}
+}
+
+type Sender struct {
+	Name  string
+	Email SubscriberEmail
+}
+
+func ParseSlug(slug string) (parsed string, err error) {
+	// empty field check
+	emptyField := strings.Trim(slug, " ")
+	if emptyField == "" {
+		err = errors.New("slug cannot be empty or whitespace")
+		return
+	}
+
+	// length checks
+	if len(slug) > maxSlugLength {
+		err = fmt.Errorf("slug exceeds maximum length of: %d characters", maxSlugLength)
+		return
+	}
+
+	// format check
+	if !slugRegex.MatchString(slug) {
+		err = fmt.Errorf("invalid slug format: %s", slug)
+		return
+	}
+
+	parsed = slug
+	return
+}
+
+func ParseList(list *List) (err error) {
+	if list.Slug, err = ParseSlug(list.Slug); err != nil {
+		return
+	}
+
+	if list.Name, err = parseDisplayName(list.Name); err != nil {
+		err = fmt.Errorf("invalid list name: %w", err)
+		return
+	}
+
+	if list.SenderEmail != "" {
+		if list.SenderEmail, err = ParseEmail(list.SenderEmail.String()); err != nil {
+			err = fmt.Errorf("invalid sender email: %w", err)
+			return
+		}
+	}
+	if list.SenderName != "" {
+		if list.SenderName, err = parseDisplayName(list.SenderName); err != nil {
+			err = fmt.Errorf("invalid sender name: %w", err)
+			return
+		}
+	}
+
+	if err = ParseListRules(&list.ListRules); err != nil {
+		return
+	}
+
+	return
+}
+
+// unlike subscriber names, display names may contain spaces
+func parseDisplayName(name string) (parsed string, err error) {
+	trimmed := strings.TrimSpace(name)
+	if trimmed == "" {
+		err = errors.New("name cannot be empty or whitespace")
+		return
+	}
+
+	if len(trimmed) > maxListNameLength {
+		err = fmt.Errorf("name exceeds maximum length of: %d characters", maxListNameLength)
+		return
+	}
+
+	for _, r := range trimmed {
+		if strings.ContainsRune("{}<>\\\"", r) || unicode.IsControl(r) {
+			err = fmt.Errorf("invalid character in name: %q", r)
+			return
+		}
+	}
+
+	parsed = trimmed
+	return
+}
+
+func ParseConfirmationTemplate(body *Body) (err error) {
+	if err = ParseNewsletter(body); err != nil {
+		return
+	}
+	if err = ParseTemplates(body); err != nil {
+		return
+	}
+
+	if !strings.Contains(body.Text, ConfirmationLinkPlaceholder) || !strings.Contains(body.Html, ConfirmationLinkPlaceholder) {
+		err = fmt.Errorf("confirmation template must contain %s", ConfirmationLinkPlaceholder)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MailEventBounce    = "bounce"
+	MailEventComplaint = "complaint"
+)
+
+const (
+	maxMailEventIDLength  = 256
+	maxMailEventDiagnosis = 500
+)
+
+// MailEvent is a bounce or spam complaint reported by a mail provider
+// callback or an ARF abuse report. An event names its address, the delivery
+// it belongs to, or both.
+type MailEvent struct {
+	ID    string          `json:"id"`
+	Type  string          `json:"type"`
+	Email SubscriberEmail `json:"email"`
+	// hard or soft, bounces only
+	Bounce     string `json:"bounce"`
+	Status     string `json:"status"`
+	Diagnostic string `json:"diagnostic"`
+	DeliveryID string `json:"delivery_id"`
+}
+
+func ParseMailEvent(event *MailEvent) (err error) {
+	event.ID = strings.TrimSpace(event.ID)
+	if event.ID == "" || len(event.ID) > maxMailEventIDLength {
+		err = fmt.Errorf("event id must be between 1 and %d characters", maxMailEventIDLength)
+		return
+	}
+
+	event.Type = strings.ToLower(strings.TrimSpace(event.Type))
+	switch event.Type {
+	case MailEventComplaint:
+		event.Bounce = ""
+	case MailEventBounce:
+		event.Bounce = strings.ToLower(strings.TrimSpace(event.Bounce))
+		if event.Bounce != BounceKindHard && event.Bounce != BounceKindSoft {
+			err = fmt.Errorf("invalid bounce type: %s", event.Bounce)
+			return
+		}
+	default:
+		err = fmt.Errorf("invalid event type: %s", event.Type)
+		return
+	}
+
+	if event.Email == "" && event.DeliveryID == "" {
+		err = errors.New("event requires an email or a delivery id")
+		return
+	}
+	if event.Email != "" {
+		if event.Email, err = ParseEmail(strings.TrimSpace(event.Email.String())); err != nil {
+			return
+		}
+	}
+	if event.DeliveryID != "" {
+		id, e := uuid.Parse(event.DeliveryID)
+		if e != nil {
+			err = fmt.Errorf("invalid delivery id: %w", e)
+			return
+		}
+		event.DeliveryID = id.String()
+	}
+
+	event.Status = strings.TrimSpace(event.Status)
+	if event.Diagnostic = strings.TrimSpace(event.Diagnostic); len(event.Diagnostic) > maxMailEventDiagnosis {
+		event.Diagnostic = event.Diagnostic[:maxMailEventDiagnosis]
+	}
+
+	return
+}
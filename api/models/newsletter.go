@@ -7,8 +7,14 @@ import (
 )
 
 type Newsletter struct {
-	Recipient SubscriberEmail
-	Content   *Body
+	Recipient   SubscriberEmail
+	Content     *Body
+	Sender      *Sender       `parse:"optional"`
+	Attachments []*Attachment `parse:"optional"`
+	// additional message headers, e.g. List-Unsubscribe
+	Headers map[string]string `parse:"optional"`
+	// envelope sender, bounces are returned here instead of the From address
+	ReturnPath SubscriberEmail `parse:"optional"`
 }
 
 type Body struct {
@@ -17,11 +23,16 @@ type Body struct {
 	Html  string `json:"html" binding:"required"`
 }
 
+// fields tagged `parse:"optional"` may be left empty
 func ParseNewsletter(newsletter interface{}) (err error) {
 	value := reflect.ValueOf(newsletter).Elem()
 	nFields := value.NumField()
 
 	for i := 0; i < nFields; i++ {
+		if value.Type().Field(i).Tag.Get("parse") == "optional" {
+			continue
+		}
+
 		field := value.Field(i)
 		if !field.IsValid() || reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface()) {
 			name := value.Type().Field(i).Name
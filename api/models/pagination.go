@@ -0,0 +1,107 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+
+	SortCreated = "created"
+	SortEmail   = "email"
+	SortName    = "name"
+)
+
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// Page selects a window of a keyset paginated listing
+type Page struct {
+	Sort       string
+	Descending bool
+	Limit      int
+	After      *PageCursor
+}
+
+// PageCursor records the sort value and ID of the last row of a page, it
+// is only valid for the sort that produced it
+type PageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func (cursor *PageCursor) Encode() string {
+	encoded, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// ParsePage accepts a sort such as "email" or "-created" for descending
+// order, newest subscribers come first by default
+func ParsePage(sort string, limit string, cursor string) (page *Page, err error) {
+	page = &Page{Sort: SortCreated, Descending: true, Limit: DefaultPageSize}
+
+	if sort = strings.TrimSpace(sort); sort != "" {
+		page.Descending = strings.HasPrefix(sort, "-")
+		page.Sort = strings.TrimPrefix(sort, "-")
+		switch page.Sort {
+		case SortCreated, SortEmail, SortName:
+		default:
+			err = fmt.Errorf("invalid sort: %s", sort)
+			return
+		}
+	}
+
+	if limit != "" {
+		if page.Limit, err = strconv.Atoi(limit); err != nil || page.Limit < 1 || page.Limit > MaxPageSize {
+			err = fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+			return
+		}
+	}
+
+	if cursor != "" {
+		decoded, e := base64.RawURLEncoding.DecodeString(cursor)
+		if e != nil {
+			err = ErrInvalidCursor
+			return
+		}
+
+		page.After = &PageCursor{}
+		if e := json.Unmarshal(decoded, page.After); e != nil {
+			err = ErrInvalidCursor
+			return
+		}
+		// the values are cast in the query, a tampered cursor must not reach
+		// the database
+		if _, e := uuid.Parse(page.After.ID); e != nil {
+			err = ErrInvalidCursor
+			return
+		}
+		if page.Sort == SortCreated {
+			if _, e := time.Parse(time.RFC3339Nano, page.After.Value); e != nil {
+				err = ErrInvalidCursor
+				return
+			}
+		}
+
+		sortKey := page.Sort
+		if page.Descending {
+			sortKey = "-" + sortKey
+		}
+		if page.After.Sort != sortKey {
+			err = fmt.Errorf("%w: cursor was issued for sort %s", ErrInvalidCursor, page.After.Sort)
+			return
+		}
+	}
+
+	return
+}
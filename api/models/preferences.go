@@ -0,0 +1,45 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxPauseDays = 365
+
+// Preferences is the subscriber facing view of every subscription held by
+// a single email address
+type Preferences struct {
+	SubscriberID string
+	Email        SubscriberEmail
+	Name         SubscriberName
+	PausedUntil  *time.Time
+	// opens and clicks are not tracked when set
+	TrackingOptOut bool
+	// scheduled issues sent in local time use the list's zone without one
+	Timezone *string
+	Lists    []*ListPreference
+}
+
+type ListPreference struct {
+	ID         string
+	Name       string
+	Subscribed bool
+}
+
+// ParsePauseDays accepts a pause length in days, zero resumes delivery
+func ParsePauseDays(raw string) (days int, err error) {
+	days, e := strconv.Atoi(strings.TrimSpace(raw))
+	if e != nil {
+		err = fmt.Errorf("invalid pause length: %s", raw)
+		return
+	}
+	if days < 0 || days > maxPauseDays {
+		err = fmt.Errorf("pause length must be between 0 and %d days", maxPauseDays)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DataArchive is everything stored about an email address, returned for
+// data subject access requests
+type DataArchive struct {
+	Email         SubscriberEmail         `json:"email"`
+	Generated     time.Time               `json:"generated"`
+	Subscriptions []*ArchivedSubscription `json:"subscriptions"`
+	Tokens        []*ArchivedToken        `json:"tokens"`
+	EmailChanges  []*ArchivedEmailChange  `json:"email_changes"`
+	Deliveries    []*ArchivedDelivery     `json:"deliveries"`
+	Bounces       []*Bounce               `json:"bounces"`
+	Engagement    []*EngagementEvent      `json:"engagement"`
+	Unsubscribes  []*ArchivedUnsubscribe  `json:"unsubscribes"`
+	StatusHistory []*ArchivedStatusChange `json:"status_history"`
+	Audit         []*AuditEntry           `json:"audit"`
+	Suppressed    bool                    `json:"suppressed"`
+}
+
+func (archive *DataArchive) Filename() string {
+	return fmt.Sprintf("data-export-%s.json", archive.Generated.Format(time.DateOnly))
+}
+
+type ArchivedSubscription struct {
+	ID            string         `json:"id"`
+	ListID        string         `json:"list_id"`
+	ListName      string         `json:"list_name"`
+	Name          SubscriberName `json:"name"`
+	Status        string         `json:"status"`
+	Created       time.Time      `json:"created"`
+	ConsentSource *string        `json:"consent_source"`
+	ConsentedAt   *time.Time     `json:"consented_at"`
+	PausedUntil   *time.Time     `json:"paused_until"`
+	Timezone      *string        `json:"timezone"`
+	Attributes    Attributes     `json:"attributes"`
+	Tags          []string       `json:"tags"`
+}
+
+type ArchivedToken struct {
+	Token        string `json:"token"`
+	SubscriberID string `json:"subscriber_id"`
+}
+
+type ArchivedEmailChange struct {
+	Email     string     `json:"email"`
+	NewEmail  string     `json:"new_email"`
+	Created   time.Time  `json:"created"`
+	Confirmed *time.Time `json:"confirmed"`
+}
+
+type ArchivedDelivery struct {
+	IssueID string     `json:"issue_id"`
+	ListID  string     `json:"list_id"`
+	Title   string     `json:"title"`
+	Status  string     `json:"status"`
+	Sent    *time.Time `json:"sent"`
+}
+
+type ArchivedUnsubscribe struct {
+	ListID  string    `json:"list_id"`
+	IssueID *string   `json:"issue_id"`
+	Created time.Time `json:"created"`
+}
+
+type ArchivedStatusChange struct {
+	ListID         string    `json:"list_id"`
+	PreviousStatus *string   `json:"previous_status"`
+	Status         string    `json:"status"`
+	Changed        time.Time `json:"changed"`
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportRanges are the number of days a growth report can cover
+var ReportRanges = []int{7, 30, 90, 365}
+
+const DefaultReportRange = 30
+
+// GrowthDay counts the status changes of a single UTC day. NetGrowth is the
+// change in confirmed subscribers.
+type GrowthDay struct {
+	Day           time.Time `json:"day"`
+	Subscriptions int       `json:"subscriptions"`
+	Confirmations int       `json:"confirmations"`
+	Unsubscribes  int       `json:"unsubscribes"`
+	Bounces       int       `json:"bounces"`
+	NetGrowth     int       `json:"net_growth"`
+}
+
+// GrowthReport is the daily series over Days days ending today, ListID is
+// nil when it covers every list
+type GrowthReport struct {
+	Days             int          `json:"days"`
+	ListID           *string      `json:"list_id"`
+	Totals           GrowthDay    `json:"totals"`
+	ConfirmationRate float64      `json:"confirmation_rate"`
+	Series           []*GrowthDay `json:"series"`
+}
+
+// ChartBar is a single day of a dashboard chart, Height is a percentage of
+// the largest day
+type ChartBar struct {
+	Day      time.Time
+	Value    int
+	Height   int
+	Negative bool
+}
+
+func ParseReportRange(raw string) (days int, err error) {
+	if strings.TrimSpace(raw) == "" {
+		days = DefaultReportRange
+		return
+	}
+
+	days, e := strconv.Atoi(strings.TrimSpace(raw))
+	if e != nil || !slices.Contains(ReportRanges, days) {
+		err = fmt.Errorf("invalid report range: %s", raw)
+		return
+	}
+
+	return
+}
+
+// Summarise totals the series and derives the confirmation rate
+func (report *GrowthReport) Summarise() {
+	report.Totals = GrowthDay{}
+	for _, day := range report.Series {
+		report.Totals.Subscriptions += day.Subscriptions
+		report.Totals.Confirmations += day.Confirmations
+		report.Totals.Unsubscribes += day.Unsubscribes
+		report.Totals.Bounces += day.Bounces
+		report.Totals.NetGrowth += day.NetGrowth
+	}
+
+	report.ConfirmationRate = 0
+	if report.Totals.Subscriptions > 0 {
+		report.ConfirmationRate = float64(report.Totals.Confirmations) / float64(report.Totals.Subscriptions)
+	}
+}
+
+// Chart scales one metric of the series for display, metric is one of the
+// GrowthDay JSON names
+func (report *GrowthReport) Chart(metric string) (bars []*ChartBar) {
+	var largest int
+	for _, day := range report.Series {
+		bar := &ChartBar{Day: day.Day, Value: day.metric(metric)}
+		bar.Negative = bar.Value < 0
+		largest = max(largest, abs(bar.Value))
+		bars = append(bars, bar)
+	}
+
+	if largest == 0 {
+		return
+	}
+	for _, bar := range bars {
+		bar.Height = abs(bar.Value) * 100 / largest
+	}
+
+	return
+}
+
+// Percent formats the confirmation rate for display
+func (report *GrowthReport) Percent() string {
+	return strconv.FormatFloat(report.ConfirmationRate*100, 'f', 1, 64) + "%"
+}
+
+func (day *GrowthDay) metric(metric string) int {
+	switch metric {
+	case "subscriptions":
+		return day.Subscriptions
+	case "confirmations":
+		return day.Confirmations
+	case "unsubscribes":
+		return day.Unsubscribes
+	case "bounces":
+		return day.Bounces
+	case "net_growth":
+		return day.NetGrowth
+	}
+
+	return 0
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
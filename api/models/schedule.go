@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	// zones are validated against the embedded database so parsing does not
+	// depend on the host
+	_ "time/tzdata"
+)
+
+const (
+	DefaultTimezone = "UTC"
+	// layout of datetime-local form inputs
+	sendAtLayout = "2006-01-02T15:04"
+	// Etc/GMT+12 is the furthest zone behind UTC
+	maxZoneLag = 12 * time.Hour
+)
+
+// Schedule delays an issue until the wall clock time SendAt in Timezone.
+// With LocalTime each recipient is sent the issue at SendAt in their own
+// zone, Timezone covers recipients without one.
+type Schedule struct {
+	SendAt    time.Time
+	Timezone  string
+	LocalTime bool
+}
+
+// ParseTimezone accepts IANA zone names, e.g. Europe/Berlin
+func ParseTimezone(raw string) (timezone string, err error) {
+	timezone = strings.TrimSpace(raw)
+	if timezone == "" {
+		err = errors.New("timezone can not be empty")
+		return
+	}
+
+	// Local is the zone of the server, not of the subscriber
+	if _, e := time.LoadLocation(timezone); e != nil || timezone == "Local" {
+		err = fmt.Errorf("unknown timezone: %s", timezone)
+		return
+	}
+
+	return
+}
+
+// ParseSchedule reads the send time of an issue, no schedule is returned
+// without one. The send time must not have passed, in local time mode it
+// only has to be ahead somewhere.
+func ParseSchedule(sendAt string, timezone string, localTime bool, now time.Time) (schedule *Schedule, err error) {
+	sendAt = strings.TrimSpace(sendAt)
+	if sendAt == "" {
+		if localTime {
+			err = errors.New("local time delivery needs a send time")
+		}
+		return
+	}
+
+	if strings.TrimSpace(timezone) == "" {
+		timezone = DefaultTimezone
+	}
+	if timezone, err = ParseTimezone(timezone); err != nil {
+		return
+	}
+
+	wallClock, e := time.Parse(sendAtLayout, sendAt)
+	if e != nil {
+		err = fmt.Errorf("invalid send time: %s", sendAt)
+		return
+	}
+
+	schedule = &Schedule{SendAt: wallClock, Timezone: timezone, LocalTime: localTime}
+	if localTime {
+		if wallClock.Add(maxZoneLag).Before(now) {
+			err = errors.New("send time has passed in every timezone")
+		}
+		return
+	}
+	if schedule.Instant().Before(now) {
+		err = errors.New("send time has passed")
+	}
+
+	return
+}
+
+// Instant is the send time in the issue's zone
+func (schedule *Schedule) Instant() time.Time {
+	location, e := time.LoadLocation(schedule.Timezone)
+	if e != nil {
+		location = time.UTC
+	}
+
+	at := schedule.SendAt
+	return time.Date(at.Year(), at.Month(), at.Day(), at.Hour(), at.Minute(), 0, 0, location)
+}
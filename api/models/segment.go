@@ -0,0 +1,256 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxTagLength          = 50
+	maxSegmentNameLength  = 100
+	maxSegmentRules       = 25
+	SegmentMatchAll       = "all"
+	SegmentMatchAny       = "any"
+	SegmentFieldTag       = "tag"
+	SegmentFieldCreated   = "created"
+	SegmentFieldAttribute = "attribute"
+	SegmentOperatorHas    = "has"
+	SegmentOperatorNotHas = "not_has"
+	SegmentOperatorBefore = "before"
+	SegmentOperatorAfter  = "after"
+	// attribute operators, before and after compare date attributes
+	SegmentOperatorEquals      = "equals"
+	SegmentOperatorNotEquals   = "not_equals"
+	SegmentOperatorGreaterThan = "greater_than"
+	SegmentOperatorLessThan    = "less_than"
+	SegmentOperatorIsSet       = "is_set"
+	SegmentOperatorIsNotSet    = "is_not_set"
+	// engagement operators, an open or click on the list counts
+	SegmentFieldEngagement         = "engagement"
+	SegmentOperatorWithinDays      = "within_days"
+	SegmentOperatorNotWithinDays   = "not_within_days"
+	SegmentOperatorInLastIssues    = "in_last_issues"
+	SegmentOperatorNotInLastIssues = "not_in_last_issues"
+	maxEngagementDays              = 3650
+	maxEngagementIssues            = 100
+)
+
+var (
+	tagRegex         = regexp.MustCompile(`^[a-z0-9._:-]+$`)
+	segmentNameRegex = regexp.MustCompile(`^[a-zA-Z0-9 ._-]+$`)
+)
+
+type Segment struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name" binding:"required"`
+	Match string         `json:"match"`
+	Rules []*SegmentRule `json:"rules" binding:"required"`
+}
+
+type SegmentRule struct {
+	Field string `json:"field"`
+	// custom field key for attribute rules
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+type SubscriberTag string
+
+func (tag SubscriberTag) String() string {
+	return string(tag)
+}
+
+// tags are case insensitive and stored lowercase
+func ParseTag(tag string) (subscriberTag SubscriberTag, err error) {
+	normalized := strings.ToLower(strings.TrimSpace(tag))
+
+	// empty field check
+	if normalized == "" {
+		err = errors.New("tag cannot be empty or whitespace")
+		return
+	}
+
+	// length checks
+	if len(normalized) > maxTagLength {
+		err = fmt.Errorf("tag exceeds maximum length of: %d characters", maxTagLength)
+		return
+	}
+
+	// format check
+	if !tagRegex.MatchString(normalized) {
+		err = fmt.Errorf("invalid tag format: %s", tag)
+		return
+	}
+
+	subscriberTag = SubscriberTag(normalized)
+	return
+}
+
+func ParseSegment(segment *Segment) (err error) {
+	// empty field check
+	if strings.TrimSpace(segment.Name) == "" {
+		err = errors.New("segment name cannot be empty or whitespace")
+		return
+	}
+
+	// length checks
+	if len(segment.Name) > maxSegmentNameLength {
+		err = fmt.Errorf("segment name exceeds maximum length of: %d characters", maxSegmentNameLength)
+		return
+	}
+
+	// format check
+	if !segmentNameRegex.MatchString(segment.Name) {
+		err = fmt.Errorf("invalid segment name format: %s", segment.Name)
+		return
+	}
+
+	switch segment.Match {
+	case "":
+		segment.Match = SegmentMatchAll
+	case SegmentMatchAll, SegmentMatchAny:
+	default:
+		err = fmt.Errorf("invalid segment match: %s", segment.Match)
+		return
+	}
+
+	if len(segment.Rules) == 0 {
+		err = errors.New("segment must have at least one rule")
+		return
+	}
+	if len(segment.Rules) > maxSegmentRules {
+		err = fmt.Errorf("segment exceeds maximum of: %d rules", maxSegmentRules)
+		return
+	}
+
+	for i, rule := range segment.Rules {
+		if rule == nil {
+			err = fmt.Errorf("rule %d cannot be empty", i)
+			return
+		}
+		if e := ParseSegmentRule(rule); e != nil {
+			err = fmt.Errorf("invalid rule %d: %w", i, e)
+			return
+		}
+	}
+
+	return
+}
+
+// rule values are normalized in place so they can be bound as query arguments
+func ParseSegmentRule(rule *SegmentRule) (err error) {
+	switch rule.Field {
+	case SegmentFieldTag:
+		if rule.Operator != SegmentOperatorHas && rule.Operator != SegmentOperatorNotHas {
+			err = fmt.Errorf("invalid operator for %s: %s", rule.Field, rule.Operator)
+			return
+		}
+
+		tag, e := ParseTag(rule.Value)
+		if e != nil {
+			err = e
+			return
+		}
+		rule.Value = tag.String()
+
+	case SegmentFieldCreated:
+		if rule.Operator != SegmentOperatorBefore && rule.Operator != SegmentOperatorAfter {
+			err = fmt.Errorf("invalid operator for %s: %s", rule.Field, rule.Operator)
+			return
+		}
+
+		date, e := ParseDate(rule.Value)
+		if e != nil {
+			err = e
+			return
+		}
+		rule.Value = date.Format(time.RFC3339)
+
+	case SegmentFieldAttribute:
+		if rule.Key, err = ParseFieldKey(rule.Key); err != nil {
+			return
+		}
+		err = parseAttributeRule(rule)
+
+	case SegmentFieldEngagement:
+		err = parseEngagementRule(rule)
+
+	default:
+		err = fmt.Errorf("invalid rule field: %s", rule.Field)
+	}
+
+	return
+}
+
+func parseAttributeRule(rule *SegmentRule) (err error) {
+	switch rule.Operator {
+	case SegmentOperatorEquals, SegmentOperatorNotEquals:
+		if len(rule.Value) > maxAttributeValueLength {
+			err = fmt.Errorf("value exceeds maximum length of: %d characters", maxAttributeValueLength)
+			return
+		}
+	case SegmentOperatorGreaterThan, SegmentOperatorLessThan:
+		if _, e := strconv.ParseFloat(strings.TrimSpace(rule.Value), 64); e != nil {
+			err = fmt.Errorf("invalid number: %s", rule.Value)
+			return
+		}
+		rule.Value = strings.TrimSpace(rule.Value)
+	case SegmentOperatorBefore, SegmentOperatorAfter:
+		date, e := ParseDate(rule.Value)
+		if e != nil {
+			err = e
+			return
+		}
+		rule.Value = date.Format(time.DateOnly)
+	case SegmentOperatorIsSet, SegmentOperatorIsNotSet:
+		rule.Value = ""
+	default:
+		err = fmt.Errorf("invalid operator for %s: %s", rule.Field, rule.Operator)
+	}
+
+	return
+}
+
+// engagement rules count days or the subscriber's latest issues
+func parseEngagementRule(rule *SegmentRule) (err error) {
+	limit := maxEngagementDays
+	switch rule.Operator {
+	case SegmentOperatorWithinDays, SegmentOperatorNotWithinDays:
+	case SegmentOperatorInLastIssues, SegmentOperatorNotInLastIssues:
+		limit = maxEngagementIssues
+	default:
+		err = fmt.Errorf("invalid operator for %s: %s", rule.Field, rule.Operator)
+		return
+	}
+
+	n, e := strconv.Atoi(strings.TrimSpace(rule.Value))
+	if e != nil || n < 1 || n > limit {
+		err = fmt.Errorf("%s must be a whole number between 1 and %d: %s", rule.Operator, limit, rule.Value)
+		return
+	}
+	rule.Value = strconv.Itoa(n)
+
+	return
+}
+
+// accepts either a calendar date or a full RFC 3339 timestamp
+func ParseDate(value string) (date time.Time, err error) {
+	value = strings.TrimSpace(value)
+
+	if d, e := time.Parse(time.DateOnly, value); e == nil {
+		date = d
+		return
+	}
+	if d, e := time.Parse(time.RFC3339, value); e == nil {
+		date = d
+		return
+	}
+
+	err = fmt.Errorf("invalid date: %s", value)
+	return
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	maxSequenceSteps = 25
+	// a year, later steps belong in a regular issue
+	maxSequenceDelayHours = 24 * 365
+)
+
+// Sequence is a series of issues delivered to every subscriber confirming
+// to the list, such as a welcome email followed by onboarding tips
+type Sequence struct {
+	ID      string          `json:"id"`
+	ListID  string          `json:"list_id"`
+	Name    string          `json:"name" binding:"required"`
+	Steps   []*SequenceStep `json:"steps" binding:"required"`
+	Created *time.Time      `json:"created,omitempty"`
+}
+
+// SequenceStep is delivered DelayHours after confirmation, zero for the
+// welcome email
+type SequenceStep struct {
+	Position   int    `json:"position"`
+	IssueID    string `json:"issue_id"`
+	DelayHours int    `json:"delay_hours"`
+	Content    *Body  `json:"content,omitempty"`
+}
+
+// ParseSequence validates the name and steps, steps are numbered in the
+// order given and may not be scheduled before the step preceding them
+func ParseSequence(sequence *Sequence) (err error) {
+	if sequence.Name, err = parseDisplayName(sequence.Name); err != nil {
+		err = fmt.Errorf("invalid sequence name: %w", err)
+		return
+	}
+
+	if len(sequence.Steps) == 0 {
+		err = errors.New("sequence must have at least one step")
+		return
+	}
+	if len(sequence.Steps) > maxSequenceSteps {
+		err = fmt.Errorf("sequence exceeds maximum of: %d steps", maxSequenceSteps)
+		return
+	}
+
+	previous := 0
+	for i, step := range sequence.Steps {
+		if step == nil || step.Content == nil {
+			err = fmt.Errorf("step %d is missing content", i+1)
+			return
+		}
+		if step.DelayHours < previous || step.DelayHours > maxSequenceDelayHours {
+			err = fmt.Errorf("step %d delay must be between %d and %d hours", i+1, previous, maxSequenceDelayHours)
+			return
+		}
+		if e := ParseNewsletter(step.Content); e != nil {
+			err = fmt.Errorf("invalid step %d: %w", i+1, e)
+			return
+		}
+		if e := ParseTemplates(step.Content); e != nil {
+			err = fmt.Errorf("invalid step %d: %w", i+1, e)
+			return
+		}
+
+		step.Position = i + 1
+		previous = step.DelayHours
+	}
+
+	return
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,15 +13,37 @@ const (
 	maxNameLength  = 100
 )
 
-var (
-	emailRegex = regexp.MustCompile((`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`))
+type Subscriber struct {
+	ID         string          `json:"id"`
+	Email      SubscriberEmail `json:"email" binding:"required"`
+	Name       SubscriberName  `json:"name" binding:"required"`
+	Status     string          `json:"status"`
+	ListID     string          `json:"list_id,omitempty"`
+	Attributes Attributes      `json:"attributes,omitempty"`
+	Flag       string          `json:"flag,omitempty"`
+	Timezone   *string         `json:"timezone,omitempty"`
+	Created    *time.Time      `json:"created,omitempty"`
+}
+
+const (
+	// the subscriptions share a normalized email
+	CollisionRuleNormalized = "normalized"
+	// the subscriptions only reach the same mailbox under provider rules,
+	// e.g. Gmail ignoring dots
+	CollisionRuleProvider = "provider"
 )
 
-type Subscriber struct {
-	ID     string          `json:"id"`
-	Email  SubscriberEmail `json:"email" binding:"required"`
-	Name   SubscriberName  `json:"name" binding:"required"`
-	Status string          `json:"status"`
+// EmailCollision pairs a subscription with the older subscription on the
+// same list whose email normalizes to the same mailbox
+type EmailCollision struct {
+	// CollisionRuleNormalized or CollisionRuleProvider
+	Rule             string          `json:"rule"`
+	SubscriberID     string          `json:"subscriber_id"`
+	Email            SubscriberEmail `json:"email"`
+	DuplicateOfID    string          `json:"duplicate_of_id"`
+	DuplicateOfEmail SubscriberEmail `json:"duplicate_of_email"`
+	ListID           string          `json:"list_id"`
+	Detected         time.Time       `json:"detected"`
 }
 
 type SubscriberEmail string
@@ -60,15 +82,41 @@ func ParseEmail(email string) (subscriber SubscriberEmail, err error) {
 	}
 
 	// email format check
-	if !emailRegex.MatchString(email) {
-		err = fmt.Errorf("invalid email format")
+	parsed, e := parseAddrSpec(email)
+	if e != nil {
+		err = e
 		return
 	}
 
-	subscriber = SubscriberEmail(email)
+	subscriber = SubscriberEmail(parsed)
 	return
 }
 
+// NormalizeEmail is the canonical form subscriptions are unique by, the
+// address is trimmed and its domain lowercased. The local part is kept as
+// only the receiving server knows how to compare it. The normalize_email SQL
+// function must stay in step.
+func NormalizeEmail(email SubscriberEmail) string {
+	address := strings.TrimSpace(email.String())
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return address + "@"
+	}
+
+	return address[:at] + "@" + LowerASCII(address[at+1:])
+}
+
+// LowerASCII folds only ASCII letters, matching the ascii_lower SQL function
+// whatever the database collation is
+func LowerASCII(value string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, value)
+}
+
 type SubscriberName string
 
 func (name SubscriberName) String() string {
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const maxSearchLength = 100
+
+var SubscriberStatuses = []string{"pending", "confirmed", "unsubscribed", "suppressed", "bounced"}
+
+func ParseStatus(status string) (parsed string, err error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	for _, s := range SubscriberStatuses {
+		if status == s {
+			parsed = s
+			return
+		}
+	}
+
+	err = fmt.Errorf("invalid status: %s", status)
+	return
+}
+
+// SubscriberFilter narrows admin listings and exports, empty fields match
+// every subscriber
+type SubscriberFilter struct {
+	// case insensitive substring of the email or name
+	Search        string
+	Status        string
+	ListID        string
+	Tag           SubscriberTag
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// ParseSubscriberFilter validates raw query values, ListID is resolved by
+// the caller since it may be given as a slug
+func ParseSubscriberFilter(search string, status string, tag string, createdAfter string, createdBefore string) (filter *SubscriberFilter, err error) {
+	filter = &SubscriberFilter{}
+	if filter.Search, err = ParseSearch(search); err != nil {
+		return
+	}
+	if status != "" {
+		if filter.Status, err = ParseStatus(status); err != nil {
+			return
+		}
+	}
+	if tag != "" {
+		if filter.Tag, err = ParseTag(tag); err != nil {
+			return
+		}
+	}
+	if createdAfter != "" {
+		date, e := ParseDate(createdAfter)
+		if e != nil {
+			err = e
+			return
+		}
+		filter.CreatedAfter = &date
+	}
+	if createdBefore != "" {
+		date, e := ParseDate(createdBefore)
+		if e != nil {
+			err = e
+			return
+		}
+		filter.CreatedBefore = &date
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore) {
+		err = fmt.Errorf("created_after must be before created_before")
+		return
+	}
+
+	return
+}
+
+func ParseSearch(search string) (parsed string, err error) {
+	search = strings.TrimSpace(search)
+	if len(search) > maxSearchLength {
+		err = fmt.Errorf("search exceeds maximum length of: %d characters", maxSearchLength)
+		return
+	}
+	for _, r := range search {
+		if unicode.IsControl(r) {
+			err = fmt.Errorf("invalid character in search: %q", r)
+			return
+		}
+	}
+
+	parsed = search
+	return
+}
+
+// ExportedSubscriber is a single row of a subscriber export
+type ExportedSubscriber struct {
+	ID            string          `json:"id"`
+	Email         SubscriberEmail `json:"email"`
+	Name          SubscriberName  `json:"name"`
+	Status        string          `json:"status"`
+	List          string          `json:"list"`
+	Created       time.Time       `json:"created"`
+	Tags          []string        `json:"tags"`
+	Attributes    Attributes      `json:"attributes"`
+	ConsentSource *string         `json:"consent_source"`
+}
+
+var ExportCSVHeader = []string{"id", "email", "name", "status", "list", "created", "tags", "attributes", "consent_source"}
+
+// CSVRecord flattens the row, tags are joined by ; and attributes are
+// encoded as JSON
+func (subscriber *ExportedSubscriber) CSVRecord() (record []string, err error) {
+	attributes, e := json.Marshal(subscriber.Attributes)
+	if e != nil {
+		err = fmt.Errorf("failed to encode attributes: %w", e)
+		return
+	}
+
+	var consentSource string
+	if subscriber.ConsentSource != nil {
+		consentSource = *subscriber.ConsentSource
+	}
+
+	record = []string{
+		subscriber.ID,
+		subscriber.Email.String(),
+		subscriber.Name.String(),
+		subscriber.Status,
+		subscriber.List,
+		subscriber.Created.Format(time.RFC3339),
+		strings.Join(subscriber.Tags, ";"),
+		string(attributes),
+		consentSource,
+	}
+	return
+}
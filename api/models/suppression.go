@@ -0,0 +1,116 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	SuppressionSourceAdmin   = "admin"
+	SuppressionSourceImport  = "import"
+	SuppressionSourceErasure = "erasure"
+	SuppressionSourceWebhook = "webhook"
+	SuppressionSourceSunset  = "sunset"
+	SuppressionSourceBounce  = "bounce"
+)
+
+const (
+	SuppressionKindEmail  = "email"
+	SuppressionKindDomain = "domain"
+)
+
+const maxDomainLength = 253
+
+var domainRegex = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// Suppression blocks every future email to an address or a whole domain,
+// erased addresses are only known by their EmailHash
+type Suppression struct {
+	ID        string           `json:"id"`
+	Kind      string           `json:"kind"`
+	Email     *SubscriberEmail `json:"email"`
+	EmailHash *string          `json:"email_hash"`
+	Domain    *string          `json:"domain"`
+	Reason    string           `json:"reason"`
+	Source    string           `json:"source"`
+	Created   time.Time        `json:"created"`
+}
+
+// ParseSuppression accepts an entry that is either an email address or a
+// domain, optionally written as @domain
+func ParseSuppression(entry string, reason string, source string) (suppression *Suppression, err error) {
+	suppression = &Suppression{Source: source}
+	if suppression.Reason, err = ParseReason(reason); err != nil {
+		err = fmt.Errorf("invalid reason: %w", err)
+		return
+	}
+
+	// addresses keep the case of their local part, see NormalizeEmail
+	entry = strings.TrimSpace(entry)
+	if strings.HasPrefix(entry, "@") || !strings.Contains(entry, "@") {
+		domain, e := ParseDomain(strings.TrimPrefix(entry, "@"))
+		if e != nil {
+			err = e
+			return
+		}
+
+		suppression.Kind = SuppressionKindDomain
+		suppression.Domain = &domain
+		return
+	}
+
+	email, e := ParseEmail(entry)
+	if e != nil {
+		err = e
+		return
+	}
+
+	hash := HashEmail(email)
+	suppression.Kind = SuppressionKindEmail
+	suppression.Email, suppression.EmailHash = &email, &hash
+	return
+}
+
+func ParseDomain(domain string) (parsed string, err error) {
+	domain = LowerASCII(strings.TrimSpace(domain))
+	if domain == "" {
+		err = errors.New("domain cannot be empty or whitespace")
+		return
+	}
+	if len(domain) > maxDomainLength {
+		err = fmt.Errorf("domain exceeds maximum length of: %d characters", maxDomainLength)
+		return
+	}
+	if !domainRegex.MatchString(domain) {
+		err = fmt.Errorf("invalid domain format")
+		return
+	}
+
+	parsed = domain
+	return
+}
+
+// ParseReason accepts a short free text explanation
+func ParseReason(reason string) (parsed string, err error) {
+	return parseDisplayName(reason)
+}
+
+// HashEmail identifies an address by its normalized email without storing
+// it, the migration backfilling suppressions.email_hash and
+// suppressions.Matches must stay in step
+func HashEmail(email SubscriberEmail) string {
+	sum := sha256.Sum256([]byte(NormalizeEmail(email)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+type SuppressionImportReport struct {
+	Added      int               `json:"added"`
+	Duplicates int               `json:"duplicates"`
+	Errors     []*ImportRowError `json:"errors"`
+}
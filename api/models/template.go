@@ -0,0 +1,97 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	htmlTemplate "html/template"
+	"strings"
+	textTemplate "text/template"
+)
+
+// TemplateData is made available to per-recipient templates, e.g. {{.name}}
+// or {{.attributes.company}}
+type TemplateData map[string]interface{}
+
+func NewTemplateData(email SubscriberEmail, name SubscriberName, attributes Attributes) TemplateData {
+	return TemplateData{
+		"email":      email.String(),
+		"name":       name.String(),
+		"attributes": attributes.Strings(),
+	}
+}
+
+// ParseTemplates ensures issue content can be rendered before it is stored
+func ParseTemplates(body *Body) (err error) {
+	if _, e := textTemplate.New("title").Parse(body.Title); e != nil {
+		err = fmt.Errorf("invalid title template: %w", e)
+		return
+	}
+	if _, e := textTemplate.New("text").Parse(body.Text); e != nil {
+		err = fmt.Errorf("invalid text template: %w", e)
+		return
+	}
+	if _, e := htmlTemplate.New("html").Parse(body.Html); e != nil {
+		err = fmt.Errorf("invalid html template: %w", e)
+		return
+	}
+
+	return
+}
+
+// RenderBody renders content for a single recipient, values in the html
+// part are escaped
+func RenderBody(body *Body, data TemplateData) (rendered *Body, err error) {
+	var title, text, html bytes.Buffer
+
+	t, e := textTemplate.New("title").Option("missingkey=zero").Parse(body.Title)
+	if e == nil {
+		e = t.Execute(&title, data)
+	}
+	if e != nil {
+		err = fmt.Errorf("failed to render title: %w", e)
+		return
+	}
+
+	t, e = textTemplate.New("text").Option("missingkey=zero").Parse(body.Text)
+	if e == nil {
+		e = t.Execute(&text, data)
+	}
+	if e != nil {
+		err = fmt.Errorf("failed to render text: %w", e)
+		return
+	}
+
+	h, e := htmlTemplate.New("html").Option("missingkey=zero").Parse(body.Html)
+	if e == nil {
+		e = h.Execute(&html, data)
+	}
+	if e != nil {
+		err = fmt.Errorf("failed to render html: %w", e)
+		return
+	}
+
+	rendered = &Body{
+		Title: title.String(),
+		Text:  text.String(),
+		Html:  html.String(),
+	}
+	return
+}
+
+// AppendPreferencesLink adds a footer linking to the preference center
+// unless the rendered content already carries the link
+func AppendPreferencesLink(body *Body, link string) {
+	if !strings.Contains(body.Text, link) {
+		body.Text += "\n\nManage your subscription: " + link
+	}
+
+	if !strings.Contains(body.Html, html.EscapeString(link)) {
+		footer := fmt.Sprintf(`<p><a href="%s">Manage your subscription</a></p>`, html.EscapeString(link))
+		if i := strings.LastIndex(strings.ToLower(body.Html), "</body>"); i >= 0 {
+			body.Html = body.Html[:i] + footer + body.Html[i:]
+		} else {
+			body.Html += footer
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+// transactional templates seeded alongside the subscription confirmation
+const (
+	EmailChangeConfirmationIssueID = "00000000-0000-0000-0000-000000000001"
+	EmailChangeNotificationIssueID = "00000000-0000-0000-0000-000000000002"
+)
+
+// change requests must be confirmed within a day
+const emailChangeExpiry = "24 hours"
+
+// history recorded under the address follows it to the new one, so
+// engagement, bounce and unsubscribe records keep counting for the subscriber
+var emailHistory = []string{
+	"delivery_log",
+	"engagement_events",
+	"bounces",
+	"unsubscribe_events",
+	"subscription_status_history",
+}
+
+var (
+	ErrEmailChangeNotFound = errors.New("email change request not found or expired")
+	ErrEmailTaken          = errors.New("email address is already subscribed")
+)
+
+type EmailChange struct {
+	Token    string
+	Email    models.SubscriberEmail
+	NewEmail models.SubscriberEmail
+}
+
+// EmailTaken reports whether any subscription already uses the address or
+// another form of it reaching the same mailbox
+func EmailTaken(c context.Context, db handlers.DatabaseInterface, email models.SubscriberEmail) (taken bool, err error) {
+	query := "SELECT EXISTS(SELECT 1 FROM subscriptions WHERE normalized_email = normalize_email($1))"
+	if e := db.QueryRow(c, query, email.String()).Scan(&taken); e != nil {
+		err = fmt.Errorf("failed to check email: %w", e)
+		return
+	}
+
+	return
+}
+
+// InsertEmailChange replaces any outstanding request for the same address
+func InsertEmailChange(c context.Context, tx pgx.Tx, change *EmailChange) (err error) {
+	query := "DELETE FROM email_change_requests WHERE normalize_email(subscriber_email) = normalize_email($1) AND confirmed IS NULL"
+	if _, e := tx.Exec(c, query, change.Email.String()); e != nil {
+		err = fmt.Errorf("failed to clear email change requests: %w", e)
+		return
+	}
+
+	query = `INSERT INTO email_change_requests (change_token, subscriber_email, new_email, created)
+			VALUES ($1, $2, $3, now())`
+	if _, e := tx.Exec(c, query, change.Token, change.Email.String(), change.NewEmail.String()); e != nil {
+		err = fmt.Errorf("failed to insert email change request: %w", e)
+		return
+	}
+
+	return
+}
+
+func GetEmailChange(c context.Context, tx pgx.Tx, token string) (change *EmailChange, err error) {
+	change = &EmailChange{Token: token}
+	query := `SELECT subscriber_email, new_email
+			FROM email_change_requests
+			WHERE change_token = $1 AND confirmed IS NULL AND created > now() - $2::interval`
+	e := tx.QueryRow(c, query, token, emailChangeExpiry).Scan(&change.Email, &change.NewEmail)
+	if e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrEmailChangeNotFound
+			return
+		}
+
+		err = fmt.Errorf("failed to fetch email change request: %w", e)
+		return
+	}
+
+	return
+}
+
+// ApplyEmailChange moves every subscription and pending delivery of the old
+// address to the new one, keeping subscriber history intact. Rows are matched
+// by the normalized address since each list may store its own form of it.
+func ApplyEmailChange(c context.Context, tx pgx.Tx, change *EmailChange) (err error) {
+	taken, e := EmailTaken(c, tx, change.NewEmail)
+	if e != nil {
+		err = e
+		return
+	}
+	if taken {
+		err = ErrEmailTaken
+		return
+	}
+
+	email, newEmail := change.Email.String(), change.NewEmail.String()
+	query := "UPDATE subscriptions SET email = $2 WHERE normalized_email = normalize_email($1)"
+	if _, e := tx.Exec(c, query, email, newEmail); e != nil {
+		err = fmt.Errorf("failed to update subscriptions: %w", e)
+		return
+	}
+
+	query = "UPDATE issue_delivery_queue SET subscriber_email = $2 WHERE normalize_email(subscriber_email) = normalize_email($1)"
+	if _, e := tx.Exec(c, query, email, newEmail); e != nil {
+		err = fmt.Errorf("failed to migrate delivery tasks: %w", e)
+		return
+	}
+
+	for _, table := range emailHistory {
+		query = "UPDATE " + table + " SET subscriber_email = $2 WHERE normalize_email(subscriber_email) = normalize_email($1)"
+		if _, e := tx.Exec(c, query, email, newEmail); e != nil {
+			err = fmt.Errorf("failed to migrate %s: %w", table, e)
+			return
+		}
+	}
+
+	// confirmed requests are kept to address the change notification
+	query = "UPDATE email_change_requests SET confirmed = now() WHERE change_token = $1"
+	if _, e := tx.Exec(c, query, change.Token); e != nil {
+		err = fmt.Errorf("failed to confirm email change request: %w", e)
+		return
+	}
+
+	query = "DELETE FROM email_change_requests WHERE normalize_email(subscriber_email) = normalize_email($1) AND confirmed IS NULL"
+	if _, e := tx.Exec(c, query, email); e != nil {
+		err = fmt.Errorf("failed to clear email change requests: %w", e)
+		return
+	}
+
+	return
+}
+
+func IsEmailChangeIssue(issueID string) bool {
+	return issueID == EmailChangeConfirmationIssueID || issueID == EmailChangeNotificationIssueID
+}
+
+// GetEmailChangeTemplateData loads the request behind an email change task,
+// the recipient has no subscription of their own at that point
+func GetEmailChangeTemplateData(c context.Context, tx pgx.Tx, issueID string, recipient models.SubscriberEmail) (data models.TemplateData, err error) {
+	change := &EmailChange{}
+	query := `SELECT change_token, subscriber_email, new_email
+			FROM email_change_requests
+			WHERE new_email = $1 AND confirmed IS NULL
+			ORDER BY created DESC
+			LIMIT 1`
+	if issueID == EmailChangeNotificationIssueID {
+		query = `SELECT change_token, subscriber_email, new_email
+				FROM email_change_requests
+				WHERE subscriber_email = $1 AND confirmed IS NOT NULL
+				ORDER BY confirmed DESC
+				LIMIT 1`
+	}
+
+	e := tx.QueryRow(c, query, recipient.String()).Scan(&change.Token, &change.Email, &change.NewEmail)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch email change request: %w", e)
+		return
+	}
+
+	data = models.TemplateData{
+		"email":     change.Email.String(),
+		"new_email": change.NewEmail.String(),
+	}
+	if issueID == EmailChangeConfirmationIssueID {
+		data["link"] = handlers.BaseURL + "/confirm-email/" + change.Token
+	}
+
+	return
+}
@@ -0,0 +1,165 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/sequences"
+)
+
+// unsubscribes are attributed to the last issue received within this many days
+const unsubscribeAttributionDays = 30
+
+var ErrSubscriberNotFound = errors.New("subscriber not found")
+
+// GetPreferences loads every list alongside the subscription state of the
+// email address behind subscriberID
+func GetPreferences(c context.Context, db handlers.DatabaseInterface, subscriberID string) (preferences *models.Preferences, err error) {
+	preferences = &models.Preferences{SubscriberID: subscriberID}
+	query := "SELECT email, name, paused_until, tracking_opt_out, timezone FROM subscriptions WHERE id = $1"
+	e := db.QueryRow(c, query, subscriberID).Scan(&preferences.Email, &preferences.Name, &preferences.PausedUntil, &preferences.TrackingOptOut, &preferences.Timezone)
+	if e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrSubscriberNotFound
+			return
+		}
+
+		err = fmt.Errorf("failed to fetch subscriber: %w", e)
+		return
+	}
+
+	query = `SELECT lists.list_id, lists.name, COALESCE(subscriptions.status IN ('confirmed', 'pending'), false)
+			FROM lists
+			LEFT JOIN subscriptions ON subscriptions.list_id = lists.list_id AND subscriptions.normalized_email = normalize_email($1)
+			ORDER BY lists.name`
+	rows, e := db.Query(c, query, preferences.Email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch list preferences: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	preferences.Lists, e = pgx.CollectRows[*models.ListPreference](rows, BuildListPreference)
+	if e != nil {
+		err = fmt.Errorf("failed to parse list preferences: %w", e)
+		return
+	}
+
+	return
+}
+
+// UpdatePreferences applies preferences to every subscription of the email,
+// lists that are no longer selected are unsubscribed. Selected lists are
+// confirmed outright since the signed link proves ownership of the address,
+// bounced and suppressed subscriptions are never revived this way. Saving
+// preferences also counts as engagement for the sunset policy.
+func UpdatePreferences(c context.Context, tx pgx.Tx, preferences *models.Preferences) (err error) {
+	email := preferences.Email.String()
+	query := `UPDATE subscriptions SET name = $2, paused_until = $3, tracking_opt_out = $4, timezone = $5,
+				reengagement_sent = NULL, reengagement_issue_id = NULL
+			WHERE normalized_email = normalize_email($1)`
+	if _, e := tx.Exec(c, query, email, preferences.Name.String(), preferences.PausedUntil, preferences.TrackingOptOut, preferences.Timezone); e != nil {
+		err = fmt.Errorf("failed to update subscriptions: %w", e)
+		return
+	}
+
+	selected := []string{}
+	for _, list := range preferences.Lists {
+		if !list.Subscribed {
+			continue
+		}
+		selected = append(selected, list.ID)
+
+		query = `INSERT INTO subscriptions (id, email, name, status, list_id, paused_until, tracking_opt_out, timezone, created)
+				VALUES ($1, $2, $3, 'confirmed', $4, $5, $6, $7, now())
+				ON CONFLICT ON CONSTRAINT subscriptions_list_id_normalized_email_key
+				DO UPDATE SET status = 'confirmed'
+				WHERE subscriptions.status IN ('pending', 'unsubscribed')
+				RETURNING id`
+		var subscriberID string
+		e := tx.QueryRow(c, query, uuid.NewString(), email, preferences.Name.String(), list.ID, preferences.PausedUntil, preferences.TrackingOptOut, preferences.Timezone).Scan(&subscriberID)
+		if errors.Is(e, pgx.ErrNoRows) {
+			// already confirmed, or bounced or suppressed
+			continue
+		}
+		if e != nil {
+			err = fmt.Errorf("failed to subscribe to list %s: %w", list.ID, e)
+			return
+		}
+
+		// the subscription was just confirmed
+		if e := sequences.EnqueSequences(c, tx, subscriberID); e != nil {
+			err = e
+			return
+		}
+	}
+
+	query = unsubscribeQuery("status = 'unsubscribed'", "normalized_email = normalize_email($1) AND NOT list_id = ANY($2)")
+	if _, e := tx.Exec(c, query, email, selected); e != nil {
+		err = fmt.Errorf("failed to unsubscribe from lists: %w", e)
+		return
+	}
+
+	return
+}
+
+// Unsubscribe removes the email from every list
+func Unsubscribe(c context.Context, db handlers.DatabaseInterface, email models.SubscriberEmail) (err error) {
+	query := unsubscribeQuery("status = 'unsubscribed', paused_until = NULL", "normalized_email = normalize_email($1)")
+	if _, e := db.Exec(c, query, email.String()); e != nil {
+		err = fmt.Errorf("failed to unsubscribe: %w", e)
+		return
+	}
+
+	return
+}
+
+// unsubscribeQuery applies set to the subscriptions matching filter and
+// records an unsubscribe event for each subscription leaving its list.
+// Bounced and suppressed subscriptions keep their status, otherwise a later
+// save would revive them.
+func unsubscribeQuery(set string, filter string) string {
+	return `WITH unsubscribed AS (
+				UPDATE subscriptions SET ` + set + `
+				WHERE ` + filter + ` AND status IN ('pending', 'confirmed')
+				RETURNING list_id, email
+			)
+			INSERT INTO unsubscribe_events (list_id, subscriber_email, newsletter_issue_id, created)
+			SELECT unsubscribed.list_id, unsubscribed.email, (
+				SELECT delivery_log.newsletter_issue_id
+				FROM delivery_log
+				WHERE delivery_log.list_id = unsubscribed.list_id
+				AND normalize_email(delivery_log.subscriber_email) = normalize_email(unsubscribed.email)
+				AND delivery_log.sent > now() - make_interval(days => ` + strconv.Itoa(unsubscribeAttributionDays) + `)
+				ORDER BY delivery_log.sent DESC
+				LIMIT 1
+			), now()
+			FROM unsubscribed`
+}
+
+// PauseUntil returns when a pause of the given length ends, nil resumes
+func PauseUntil(days int) *time.Time {
+	if days == 0 {
+		return nil
+	}
+
+	until := time.Now().AddDate(0, 0, days)
+	return &until
+}
+
+func BuildListPreference(row pgx.CollectableRow) (list *models.ListPreference, err error) {
+	list = &models.ListPreference{}
+	if e := row.Scan(&list.ID, &list.Name, &list.Subscribed); e != nil {
+		err = fmt.Errorf("failed to scan list preference: %w", e)
+		return
+	}
+
+	return
+}
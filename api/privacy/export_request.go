@@ -0,0 +1,84 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+// transactional template seeded alongside the email change templates
+const DataExportIssueID = "00000000-0000-0000-0000-000000000003"
+
+// export links hand out everything stored about the subscriber, they are
+// only valid for an hour
+const dataExportExpiry = "1 hour"
+
+var ErrDataExportNotFound = errors.New("data export request not found or expired")
+
+func IsDataExportIssue(issueID string) bool {
+	return issueID == DataExportIssueID
+}
+
+// InsertDataExport replaces any outstanding export request of the subscriber
+func InsertDataExport(c context.Context, tx pgx.Tx, token string, subscriberID string) (err error) {
+	query := "DELETE FROM data_export_requests WHERE subscriber_id = $1"
+	if _, e := tx.Exec(c, query, subscriberID); e != nil {
+		err = fmt.Errorf("failed to clear data export requests: %w", e)
+		return
+	}
+
+	query = `INSERT INTO data_export_requests (export_token, subscriber_id, created)
+			VALUES ($1, $2, now())`
+	if _, e := tx.Exec(c, query, token, subscriberID); e != nil {
+		err = fmt.Errorf("failed to insert data export request: %w", e)
+		return
+	}
+
+	return
+}
+
+// GetDataExport returns the subscription behind an unexpired export token,
+// its current email is exported even if it changed since the request
+func GetDataExport(c context.Context, db handlers.DatabaseInterface, token string) (subscriberID string, email models.SubscriberEmail, err error) {
+	query := `SELECT subscriptions.id, subscriptions.email
+			FROM data_export_requests
+			JOIN subscriptions ON subscriptions.id = data_export_requests.subscriber_id
+			WHERE data_export_requests.export_token = $1
+			AND data_export_requests.created > now() - $2::interval`
+	if e := db.QueryRow(c, query, token, dataExportExpiry).Scan(&subscriberID, &email); e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrDataExportNotFound
+			return
+		}
+
+		err = fmt.Errorf("failed to fetch data export request: %w", e)
+		return
+	}
+
+	return
+}
+
+// GetDataExportTemplateData links the latest export request of the recipient
+func GetDataExportTemplateData(c context.Context, tx pgx.Tx, recipient models.SubscriberEmail) (data models.TemplateData, err error) {
+	var token string
+	query := `SELECT data_export_requests.export_token
+			FROM data_export_requests
+			JOIN subscriptions ON subscriptions.id = data_export_requests.subscriber_id
+			WHERE subscriptions.email = $1
+			ORDER BY data_export_requests.created DESC
+			LIMIT 1`
+	if e := tx.QueryRow(c, query, recipient.String()).Scan(&token); e != nil {
+		err = fmt.Errorf("failed to fetch data export request: %w", e)
+		return
+	}
+
+	data = models.TemplateData{
+		"email": recipient.String(),
+		"link":  handlers.BaseURL + "/data-export/" + token,
+	}
+	return
+}
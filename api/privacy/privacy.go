@@ -0,0 +1,350 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/audit"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+)
+
+const erasedReason = "erased"
+
+// subjectAddresses lists the normalized forms of $1 and of every earlier
+// address of the subscriber, followed back through confirmed email changes
+const subjectAddresses = `WITH RECURSIVE addresses(normalized) AS (
+				SELECT normalize_email($1)
+				UNION
+				SELECT normalize_email(email_change_requests.subscriber_email)
+				FROM email_change_requests
+				JOIN addresses ON normalize_email(email_change_requests.new_email) = addresses.normalized
+				WHERE email_change_requests.confirmed IS NOT NULL
+			)
+			SELECT normalized FROM addresses`
+
+// ofSubject matches rows whose email column holds any address of the subject
+func ofSubject(column string) string {
+	return "normalize_email(" + column + ") IN (" + subjectAddresses + ")"
+}
+
+// subjectSubscriptions selects the ids of every subscription of the subject
+var subjectSubscriptions = "SELECT id FROM subscriptions WHERE " + ofSubject("email")
+
+// erasures run in order so rows are removed before the subscriptions their
+// subqueries depend on, email change requests go last as they link the
+// earlier addresses
+var erasures = []struct {
+	table string
+	query string
+}{
+	{"subscription tokens", "DELETE FROM subscription_tokens WHERE subscriber_id IN (" + subjectSubscriptions + ")"},
+	{"subscriber tags", "DELETE FROM subscriber_tags WHERE subscriber_id IN (" + subjectSubscriptions + ")"},
+	{"audit entries", "DELETE FROM audit_log WHERE subscriber_id IN (" + subjectSubscriptions + ")"},
+	{"delivery tasks", "DELETE FROM issue_delivery_queue WHERE " + ofSubject("subscriber_email")},
+	{"bounces", "DELETE FROM bounces WHERE " + ofSubject("subscriber_email")},
+	{"engagement events", "DELETE FROM engagement_events WHERE " + ofSubject("subscriber_email")},
+	{"delivery log", "DELETE FROM delivery_log WHERE " + ofSubject("subscriber_email")},
+	{"unsubscribe events", "DELETE FROM unsubscribe_events WHERE " + ofSubject("subscriber_email")},
+	{"status history", "DELETE FROM subscription_status_history WHERE " + ofSubject("subscriber_email") + " OR subscriber_id IN (" + subjectSubscriptions + ")"},
+	{"subscriptions", "DELETE FROM subscriptions WHERE " + ofSubject("email")},
+	// suppressions of earlier addresses keep blocking them by hash only
+	{"suppressed addresses", "UPDATE suppressions SET email = NULL WHERE " + ofSubject("email")},
+	{"email change requests", "DELETE FROM email_change_requests WHERE " + ofSubject("subscriber_email") + " OR " + ofSubject("new_email")},
+}
+
+// ExportData collects every row stored about email and its earlier
+// addresses across all lists
+func ExportData(c context.Context, db handlers.DatabaseInterface, email models.SubscriberEmail) (archive *models.DataArchive, err error) {
+	archive = &models.DataArchive{Email: email, Generated: time.Now().UTC()}
+
+	query := `SELECT subscriptions.id, subscriptions.list_id, lists.name, subscriptions.name, subscriptions.status,
+				subscriptions.created, subscriptions.consent_source, subscriptions.consented_at,
+				subscriptions.paused_until, subscriptions.timezone, subscriptions.attributes,
+				COALESCE(array_agg(subscriber_tags.tag ORDER BY subscriber_tags.tag) FILTER (WHERE subscriber_tags.tag IS NOT NULL), '{}')
+			FROM subscriptions
+			JOIN lists ON lists.list_id = subscriptions.list_id
+			LEFT JOIN subscriber_tags ON subscriber_tags.subscriber_id = subscriptions.id
+			WHERE ` + ofSubject("subscriptions.email") + `
+			GROUP BY subscriptions.id, lists.name
+			ORDER BY subscriptions.created`
+	rows, e := db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch subscriptions: %w", e)
+		return
+	}
+	if archive.Subscriptions, e = pgx.CollectRows(rows, buildSubscription); e != nil {
+		err = fmt.Errorf("failed to parse subscriptions: %w", e)
+		return
+	}
+
+	query = `SELECT subscription_token, subscriber_id
+			FROM subscription_tokens
+			WHERE subscriber_id IN (` + subjectSubscriptions + `)`
+	rows, e = db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch subscription tokens: %w", e)
+		return
+	}
+	if archive.Tokens, e = pgx.CollectRows(rows, buildToken); e != nil {
+		err = fmt.Errorf("failed to parse subscription tokens: %w", e)
+		return
+	}
+
+	query = `SELECT subscriber_email, new_email, created, confirmed
+			FROM email_change_requests
+			WHERE ` + ofSubject("subscriber_email") + ` OR ` + ofSubject("new_email") + `
+			ORDER BY created`
+	rows, e = db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch email change requests: %w", e)
+		return
+	}
+	if archive.EmailChanges, e = pgx.CollectRows(rows, buildEmailChange); e != nil {
+		err = fmt.Errorf("failed to parse email change requests: %w", e)
+		return
+	}
+
+	query = `SELECT delivery_log.newsletter_issue_id, delivery_log.list_id, newsletter_issues.title, 'sent', delivery_log.sent
+			FROM delivery_log
+			JOIN newsletter_issues ON newsletter_issues.newsletter_issue_id = delivery_log.newsletter_issue_id
+			WHERE ` + ofSubject("delivery_log.subscriber_email") + `
+			UNION ALL
+			SELECT issue_delivery_queue.newsletter_issue_id, issue_delivery_queue.list_id, newsletter_issues.title, 'queued', NULL
+			FROM issue_delivery_queue
+			JOIN newsletter_issues ON newsletter_issues.newsletter_issue_id = issue_delivery_queue.newsletter_issue_id
+			WHERE ` + ofSubject("issue_delivery_queue.subscriber_email") + `
+			ORDER BY 5 NULLS LAST`
+	rows, e = db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch deliveries: %w", e)
+		return
+	}
+	if archive.Deliveries, e = pgx.CollectRows(rows, buildDelivery); e != nil {
+		err = fmt.Errorf("failed to parse deliveries: %w", e)
+		return
+	}
+
+	query = `SELECT bounce_id, message_id, delivery_id, newsletter_issue_id, list_id, subscriber_email,
+				kind, status, diagnostic, received
+			FROM bounces
+			WHERE ` + ofSubject("subscriber_email") + `
+			ORDER BY received`
+	rows, e = db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch bounces: %w", e)
+		return
+	}
+	if archive.Bounces, e = pgx.CollectRows(rows, buildBounce); e != nil {
+		err = fmt.Errorf("failed to parse bounces: %w", e)
+		return
+	}
+
+	query = `SELECT event_id, delivery_id, newsletter_issue_id, list_id, subscriber_email, type, url, created
+			FROM engagement_events
+			WHERE ` + ofSubject("subscriber_email") + `
+			ORDER BY created`
+	rows, e = db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch engagement events: %w", e)
+		return
+	}
+	if archive.Engagement, e = pgx.CollectRows(rows, buildEngagementEvent); e != nil {
+		err = fmt.Errorf("failed to parse engagement events: %w", e)
+		return
+	}
+
+	query = `SELECT list_id, newsletter_issue_id, created
+			FROM unsubscribe_events
+			WHERE ` + ofSubject("subscriber_email") + `
+			ORDER BY created`
+	rows, e = db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch unsubscribe events: %w", e)
+		return
+	}
+	if archive.Unsubscribes, e = pgx.CollectRows(rows, buildUnsubscribe); e != nil {
+		err = fmt.Errorf("failed to parse unsubscribe events: %w", e)
+		return
+	}
+
+	query = `SELECT list_id, previous_status, status, changed
+			FROM subscription_status_history
+			WHERE ` + ofSubject("subscriber_email") + ` OR subscriber_id IN (` + subjectSubscriptions + `)
+			ORDER BY changed`
+	rows, e = db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch status history: %w", e)
+		return
+	}
+	if archive.StatusHistory, e = pgx.CollectRows(rows, buildStatusChange); e != nil {
+		err = fmt.Errorf("failed to parse status history: %w", e)
+		return
+	}
+
+	query = `SELECT audit_id, actor, action, subscriber_id, details, created
+			FROM audit_log
+			WHERE subscriber_id IN (` + subjectSubscriptions + `)
+			ORDER BY created`
+	rows, e = db.Query(c, query, email.String())
+	if e != nil {
+		err = fmt.Errorf("failed to fetch audit entries: %w", e)
+		return
+	}
+	if archive.Audit, e = pgx.CollectRows(rows, audit.BuildEntry); e != nil {
+		err = fmt.Errorf("failed to parse audit entries: %w", e)
+		return
+	}
+
+	query = "SELECT " + suppressions.Matches("$1::text")
+	if e := db.QueryRow(c, query, email.String()).Scan(&archive.Suppressed); e != nil {
+		err = fmt.Errorf("failed to fetch suppression: %w", e)
+		return
+	}
+
+	return
+}
+
+// EraseData hard deletes every row about email. Only a hashed tombstone is
+// kept in suppressions so the address is never imported or mailed again.
+func EraseData(c context.Context, tx pgx.Tx, email models.SubscriberEmail) (err error) {
+	for _, erasure := range erasures {
+		if _, e := tx.Exec(c, erasure.query, email.String()); e != nil {
+			err = fmt.Errorf("failed to erase %s: %w", erasure.table, e)
+			return
+		}
+	}
+
+	query := `INSERT INTO suppressions (suppression_id, email, email_hash, reason, source, created)
+			VALUES ($1, NULL, $2, $3, $4, now())
+			ON CONFLICT (email_hash) DO UPDATE
+			SET email = NULL, reason = EXCLUDED.reason, source = EXCLUDED.source`
+	_, e := tx.Exec(c, query, uuid.NewString(), models.HashEmail(email), erasedReason, models.SuppressionSourceErasure)
+	if e != nil {
+		err = fmt.Errorf("failed to insert tombstone: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildSubscription(row pgx.CollectableRow) (subscription *models.ArchivedSubscription, err error) {
+	subscription = &models.ArchivedSubscription{}
+	e := row.Scan(
+		&subscription.ID,
+		&subscription.ListID,
+		&subscription.ListName,
+		&subscription.Name,
+		&subscription.Status,
+		&subscription.Created,
+		&subscription.ConsentSource,
+		&subscription.ConsentedAt,
+		&subscription.PausedUntil,
+		&subscription.Timezone,
+		&subscription.Attributes,
+		&subscription.Tags,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to scan subscription: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildToken(row pgx.CollectableRow) (token *models.ArchivedToken, err error) {
+	token = &models.ArchivedToken{}
+	if e := row.Scan(&token.Token, &token.SubscriberID); e != nil {
+		err = fmt.Errorf("failed to scan subscription token: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildEmailChange(row pgx.CollectableRow) (change *models.ArchivedEmailChange, err error) {
+	change = &models.ArchivedEmailChange{}
+	if e := row.Scan(&change.Email, &change.NewEmail, &change.Created, &change.Confirmed); e != nil {
+		err = fmt.Errorf("failed to scan email change request: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildDelivery(row pgx.CollectableRow) (delivery *models.ArchivedDelivery, err error) {
+	delivery = &models.ArchivedDelivery{}
+	e := row.Scan(&delivery.IssueID, &delivery.ListID, &delivery.Title, &delivery.Status, &delivery.Sent)
+	if e != nil {
+		err = fmt.Errorf("failed to scan delivery: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildBounce(row pgx.CollectableRow) (bounce *models.Bounce, err error) {
+	bounce = &models.Bounce{}
+	e := row.Scan(
+		&bounce.ID,
+		&bounce.MessageID,
+		&bounce.DeliveryID,
+		&bounce.IssueID,
+		&bounce.ListID,
+		&bounce.Email,
+		&bounce.Kind,
+		&bounce.Status,
+		&bounce.Diagnostic,
+		&bounce.Received,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to scan bounce: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildEngagementEvent(row pgx.CollectableRow) (event *models.EngagementEvent, err error) {
+	event = &models.EngagementEvent{}
+	e := row.Scan(
+		&event.ID,
+		&event.DeliveryID,
+		&event.IssueID,
+		&event.ListID,
+		&event.Email,
+		&event.Type,
+		&event.URL,
+		&event.Created,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to scan engagement event: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildUnsubscribe(row pgx.CollectableRow) (unsubscribe *models.ArchivedUnsubscribe, err error) {
+	unsubscribe = &models.ArchivedUnsubscribe{}
+	if e := row.Scan(&unsubscribe.ListID, &unsubscribe.IssueID, &unsubscribe.Created); e != nil {
+		err = fmt.Errorf("failed to scan unsubscribe event: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildStatusChange(row pgx.CollectableRow) (change *models.ArchivedStatusChange, err error) {
+	change = &models.ArchivedStatusChange{}
+	if e := row.Scan(&change.ListID, &change.PreviousStatus, &change.Status, &change.Changed); e != nil {
+		err = fmt.Errorf("failed to scan status change: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,70 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+// GetGrowthReport builds the daily series of the last days UTC days from the
+// subscription status history, listID nil covers every list. Days without
+// changes are included as zeros.
+func GetGrowthReport(c context.Context, db handlers.DatabaseInterface, days int, listID *string) (report *models.GrowthReport, err error) {
+	report = &models.GrowthReport{Days: days, ListID: listID}
+
+	query := `SELECT day,
+				count(history.status) FILTER (WHERE history.previous_status IS NULL),
+				count(history.status) FILTER (WHERE history.status = 'confirmed'),
+				count(history.status) FILTER (WHERE history.status = 'unsubscribed'),
+				count(history.status) FILTER (WHERE history.status = 'bounced'),
+				count(history.status) FILTER (WHERE history.status = 'confirmed')
+					- count(history.status) FILTER (WHERE history.previous_status = 'confirmed')
+			FROM generate_series(
+				(now() AT TIME ZONE 'UTC')::date - ($1::int - 1),
+				(now() AT TIME ZONE 'UTC')::date,
+				interval '1 day'
+			) AS day
+			LEFT JOIN subscription_status_history AS history
+				ON history.changed AT TIME ZONE 'UTC' >= day
+				AND history.changed AT TIME ZONE 'UTC' < day + interval '1 day'
+				AND ($2::uuid IS NULL OR history.list_id = $2)
+			GROUP BY day
+			ORDER BY day`
+	rows, e := db.Query(c, query, days, listID)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch growth report: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	report.Series, e = pgx.CollectRows[*models.GrowthDay](rows, buildGrowthDay)
+	if e != nil {
+		err = fmt.Errorf("failed to parse growth report: %w", e)
+		return
+	}
+
+	report.Summarise()
+	return
+}
+
+func buildGrowthDay(row pgx.CollectableRow) (day *models.GrowthDay, err error) {
+	day = &models.GrowthDay{}
+	e := row.Scan(
+		&day.Day,
+		&day.Subscriptions,
+		&day.Confirmations,
+		&day.Unsubscribes,
+		&day.Bounces,
+		&day.NetGrowth,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to scan growth day: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solomonbaez/hyacinth/api/abtests"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const (
+	defaultSamplePercent = 20
+	defaultTestWindow    = 4
+)
+
+// ParseSubjectTestForm reads the subject test of the newsletter form, the
+// title is the first variant and each line of alternatives adds another.
+// No test is returned without alternatives.
+func ParseSubjectTestForm(c *gin.Context, title string, tracking models.Tracking) (test *models.SubjectTest, err error) {
+	var alternatives []string
+	for _, line := range strings.Split(c.PostForm("subject_variants"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			alternatives = append(alternatives, line)
+		}
+	}
+	if len(alternatives) == 0 {
+		return
+	}
+
+	test = &models.SubjectTest{
+		Variants:      []*models.SubjectVariant{{Subject: title}},
+		SamplePercent: defaultSamplePercent,
+		Metric:        c.DefaultPostForm("test_metric", models.SubjectMetricOpen),
+		WindowHours:   defaultTestWindow,
+	}
+	for _, alternative := range alternatives {
+		test.Variants = append(test.Variants, &models.SubjectVariant{Subject: alternative})
+	}
+
+	if raw := c.PostForm("test_sample"); raw != "" {
+		if test.SamplePercent, err = strconv.Atoi(raw); err != nil {
+			err = fmt.Errorf("invalid test sample: %s", raw)
+			return
+		}
+	}
+	if raw := c.PostForm("test_window"); raw != "" {
+		if test.WindowHours, err = strconv.Atoi(raw); err != nil {
+			err = fmt.Errorf("invalid test window: %s", raw)
+			return
+		}
+	}
+
+	err = models.ParseSubjectTest(test, tracking)
+	return
+}
+
+// GetSubjectTest returns the variants of an issue's subject test with their
+// results so far
+func GetSubjectTest(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	// the route shares its wildcard with /issues/:title, the value is the
+	// issue ID
+	test, e := abtests.GetSubjectTest(c, dh.DB, c.Param("title"))
+	if e != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(e, abtests.ErrTestNotFound) {
+			status = http.StatusNotFound
+		}
+
+		response := "Failed to fetch subject test"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "test": test})
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solomonbaez/hyacinth/api/analytics"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const (
+	AnalyticsFormatHTML = "html"
+	AnalyticsFormatJSON = "json"
+	AnalyticsFormatCSV  = "csv"
+)
+
+// GetIssueAnalytics renders the analytics of an issue as a page, JSON or a
+// CSV download, chosen by the format query parameter
+func GetIssueAnalytics(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	format := c.DefaultQuery("format", AnalyticsFormatHTML)
+	if format != AnalyticsFormatHTML && format != AnalyticsFormatJSON && format != AnalyticsFormatCSV {
+		response = "Invalid analytics format"
+		handlers.HandleError(c, requestID, fmt.Errorf("unsupported format: %s", format), response, http.StatusBadRequest)
+		return
+	}
+
+	// the route shares its wildcard with /issues/:title, the value is the
+	// issue ID
+	issueAnalytics, e := analytics.GetIssueAnalytics(c, dh.DB, c.Param("title"))
+	if e != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(e, analytics.ErrIssueNotFound) {
+			status = http.StatusNotFound
+		}
+
+		response = "Failed to fetch issue analytics"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	switch format {
+	case AnalyticsFormatJSON:
+		c.JSON(http.StatusOK, gin.H{"requestID": requestID, "analytics": issueAnalytics})
+	case AnalyticsFormatCSV:
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", issueAnalytics.Filename()))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+
+		writer := csv.NewWriter(c.Writer)
+		writer.Write(models.AnalyticsCSVHeader)
+		writer.WriteAll(issueAnalytics.CSVRecords())
+	default:
+		c.HTML(http.StatusOK, "analytics.html", gin.H{"analytics": issueAnalytics})
+	}
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/blocklist"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+)
+
+// PostBlocklistReload re-reads the blocklist file after it was edited, the
+// current entries are kept when the file is invalid
+func PostBlocklistReload(c *gin.Context, blocklist *blocklist.Blocklist) {
+	requestID := c.GetString("requestID")
+
+	if blocklist == nil {
+		response := "Blocklist is disabled"
+		handlers.HandleError(c, requestID, errors.New("no blocklist file configured"), response, http.StatusNotFound)
+		return
+	}
+	if e := blocklist.Reload(); e != nil {
+		response := "Failed to reload blocklist"
+		handlers.HandleError(c, requestID, e, response, http.StatusUnprocessableEntity)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Msg("Blocklist reloaded")
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID})
+}
@@ -1,17 +1,93 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/reports"
 )
 
-func GetAdminDashboard(c *gin.Context) {
+// charts drawn on the dashboard, Metric names a GrowthDay field
+var growthCharts = []struct {
+	Metric string
+	Label  string
+}{
+	{"subscriptions", "New Subscriptions"},
+	{"confirmations", "Confirmations"},
+	{"unsubscribes", "Unsubscribes"},
+	{"bounces", "Bounces"},
+	{"net_growth", "Net Growth"},
+}
+
+func GetAdminDashboard(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	report, status, e := loadGrowthReport(c, dh)
+	if e != nil {
+		response := "Failed to load growth report"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
 	session := sessions.Default(c)
 	user := session.Get("user")
 	flashes := session.Flashes()
 	session.Save()
 
-	c.HTML(http.StatusOK, "dashboard.html", gin.H{"flashes": flashes, "user": user})
+	c.HTML(http.StatusOK, "dashboard.html", gin.H{
+		"flashes": flashes,
+		"user":    user,
+		"report":  report,
+		"ranges":  models.ReportRanges,
+		"charts":  growthCharts,
+		"list":    c.Query("list"),
+	})
+}
+
+// GetGrowthReport serves the dashboard report as JSON
+func GetGrowthReport(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	report, status, e := loadGrowthReport(c, dh)
+	if e != nil {
+		response := "Failed to load growth report"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "report": report})
+}
+
+// loadGrowthReport reads the days and list query parameters
+func loadGrowthReport(c *gin.Context, dh *handlers.DatabaseHandler) (report *models.GrowthReport, status int, err error) {
+	status = http.StatusBadRequest
+	days, e := models.ParseReportRange(c.Query("days"))
+	if e != nil {
+		err = e
+		return
+	}
+
+	var listID *string
+	if identifier := c.Query("list"); identifier != "" {
+		list, e := lists.GetList(c, dh.DB, identifier)
+		if e != nil {
+			status = http.StatusInternalServerError
+			if errors.Is(e, lists.ErrListNotFound) {
+				status = http.StatusNotFound
+			}
+
+			err = e
+			return
+		}
+		listID = &list.ID
+	}
+
+	status = http.StatusInternalServerError
+	report, err = reports.GetGrowthReport(c, dh.DB, days, listID)
+	return
 }
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/sunset"
+)
+
+// GetSubscriberEngagement returns the subscriber's engagement score and
+// whether a re-engagement email is pending
+func GetSubscriberEngagement(c *gin.Context, dh *handlers.DatabaseHandler, settings *configs.SunsetSettings) {
+	requestID := c.GetString("requestID")
+
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+
+	score, e := sunset.GetEngagementScore(c, dh.DB, subscriber.ID, settings.ScoreWindow)
+	if e != nil {
+		response := "Failed to fetch engagement score"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "engagement": score})
+}
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/subscribers"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// flush the response every n rows so clients receive data as it is read
+const exportFlushInterval = 1000
+
+// GetSubscriberExport streams the filtered subscribers as CSV or NDJSON
+func GetSubscriberExport(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	if format != ExportFormatCSV && format != ExportFormatNDJSON {
+		response = "Invalid export format"
+		handlers.HandleError(c, requestID, fmt.Errorf("unsupported format: %s", format), response, http.StatusBadRequest)
+		return
+	}
+
+	filter, status, e := parseSubscriberFilter(c, dh)
+	if e != nil {
+		response = "Invalid subscriber filter"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	filename := fmt.Sprintf("subscribers-%s.%s", time.Now().Format(time.DateOnly), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var count int
+	var write func(*models.ExportedSubscriber) error
+	writer := csv.NewWriter(c.Writer)
+	switch format {
+	case ExportFormatCSV:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+
+		write = func(subscriber *models.ExportedSubscriber) error {
+			if count == 0 {
+				if e := writer.Write(models.ExportCSVHeader); e != nil {
+					return e
+				}
+			}
+
+			record, e := subscriber.CSVRecord()
+			if e != nil {
+				return e
+			}
+			if e := writer.Write(record); e != nil {
+				return e
+			}
+
+			count++
+			if count%exportFlushInterval == 0 {
+				writer.Flush()
+				c.Writer.Flush()
+			}
+			return writer.Error()
+		}
+	case ExportFormatNDJSON:
+		c.Header("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(c.Writer)
+
+		write = func(subscriber *models.ExportedSubscriber) error {
+			if e := encoder.Encode(subscriber); e != nil {
+				return e
+			}
+
+			count++
+			if count%exportFlushInterval == 0 {
+				c.Writer.Flush()
+			}
+			return nil
+		}
+	}
+
+	if e := subscribers.ExportSubscribers(c, tx, filter, write); e != nil {
+		// once rows are written the status can no longer change
+		if !c.Writer.Written() {
+			response = "Failed to export subscribers"
+			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+			return
+		}
+
+		log.Error().
+			Str("requestID", requestID).
+			Err(e).
+			Int("rows", count).
+			Msg("Subscriber export interrupted")
+
+		c.Abort()
+		return
+	}
+
+	if format == ExportFormatCSV {
+		if count == 0 {
+			writer.Write(models.ExportCSVHeader)
+		}
+		writer.Flush()
+	}
+	if !c.Writer.Written() {
+		c.Status(http.StatusOK)
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("format", format).
+		Int("rows", count).
+		Msg("Subscribers exported")
+}
+
+// parseSubscriberFilter reads the shared search, status, list, tag and date
+// range query parameters
+func parseSubscriberFilter(c *gin.Context, dh *handlers.DatabaseHandler) (filter *models.SubscriberFilter, status int, err error) {
+	status = http.StatusBadRequest
+	filter, err = models.ParseSubscriberFilter(
+		c.Query("q"),
+		c.Query("status"),
+		c.Query("tag"),
+		c.Query("created_after"),
+		c.Query("created_before"),
+	)
+	if err != nil {
+		return
+	}
+
+	if identifier := c.Query("list"); identifier != "" {
+		list, e := lists.GetList(c, dh.DB, identifier)
+		if e != nil {
+			status = http.StatusInternalServerError
+			if errors.Is(e, lists.ErrListNotFound) {
+				status = http.StatusNotFound
+			}
+
+			err = e
+			return
+		}
+		filter.ListID = list.ID
+	}
+
+	return
+}
@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/attributes"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+func GetFields(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	definitions, e := attributes.GetFieldDefinitions(c, dh.DB)
+	if e != nil {
+		response := "Failed to fetch subscriber fields"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "fields": definitions})
+}
+
+func PostField(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var definition models.FieldDefinition
+
+	requestID := c.GetString("requestID")
+
+	var response string
+	if e := c.ShouldBindJSON(&definition); e != nil {
+		response = "Could not create subscriber field"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if e := models.ParseFieldDefinition(&definition); e != nil {
+		response = "Could not create subscriber field"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	if e := attributes.InsertFieldDefinition(c, dh.DB, &definition); e != nil {
+		response = "Failed to insert subscriber field"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("field", definition.Key).
+		Msg("Subscriber field created")
+
+	c.JSON(http.StatusCreated, gin.H{"requestID": requestID, "field": &definition})
+}
+
+func DeleteField(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	key, e := models.ParseFieldKey(c.Param("key"))
+	if e != nil {
+		response = "Invalid field key"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	deleted, e := attributes.DeleteFieldDefinition(c, tx, key)
+	if e != nil {
+		response = "Failed to delete subscriber field"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		response = "Subscriber field not found"
+		handlers.HandleError(c, requestID, errors.New("no field with that key"), response, http.StatusNotFound)
+		return
+	}
+
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "field": "Subscriber field deleted"})
+}
+
+// PutSubscriberAttributes merges the given attributes into a subscriber's,
+// null values clear the attribute
+func PutSubscriberAttributes(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var raw map[string]interface{}
+
+	requestID := c.GetString("requestID")
+
+	var response string
+	id, e := uuid.Parse(c.Param("id"))
+	if e != nil {
+		response = "Invalid ID format"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	if e := c.ShouldBindJSON(&raw); e != nil {
+		response = "Could not update attributes"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	definitions, e := attributes.GetFieldDefinitions(c, dh.DB)
+	if e != nil {
+		response = "Failed to fetch subscriber fields"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	parsed, e := models.ParseAttributes(raw, definitions)
+	if e != nil {
+		response = "Could not update attributes"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	updated, e := attributes.UpdateAttributes(c, dh.DB, id.String(), parsed)
+	if e != nil {
+		response = "Failed to update attributes"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		response = "Subscriber not found"
+		handlers.HandleError(c, requestID, errors.New("no subscriber with that ID"), response, http.StatusNotFound)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", id.String()).
+		Msg("Subscriber attributes updated")
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "attributes": parsed})
+}
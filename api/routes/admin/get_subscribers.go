@@ -10,10 +10,12 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/solomonbaez/hyacinth/api/handlers"
 	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/subscribers"
 )
 
+// GetSubscribers returns a keyset paginated page of subscribers, see
+// parseSubscriberFilter for the supported filters
 func GetSubscribers(c *gin.Context, dh *handlers.DatabaseHandler) {
-	var subscribers []*models.Subscriber
 	requestID := c.GetString("requestID")
 
 	log.Info().
@@ -21,29 +23,40 @@ func GetSubscribers(c *gin.Context, dh *handlers.DatabaseHandler) {
 		Msg("Fetching subscribers...")
 
 	var response string
-	rows, e := dh.DB.Query(c, "SELECT * FROM subscriptions")
+	filter, status, e := parseSubscriberFilter(c, dh)
 	if e != nil {
-		response = "Failed to fetch subscribers"
-		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		response = "Invalid subscriber filter"
+		handlers.HandleError(c, requestID, e, response, status)
 		return
 	}
-	defer rows.Close()
 
-	subscribers, e = pgx.CollectRows[*models.Subscriber](rows, handlers.BuildSubscriber)
+	page, e := models.ParsePage(c.Query("sort"), c.Query("limit"), c.Query("cursor"))
+	if e != nil {
+		response = "Invalid page"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	subscriberArray, total, next, e := subscribers.GetSubscriberPage(c, dh.DB, filter, page)
 	if e != nil {
-		response = "Failed to parse subscribers"
+		response = "Failed to fetch subscribers"
 		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
 		return
 	}
 
-	if len(subscribers) > 0 {
+	if total == 0 {
 		response = "No subscribers"
 		log.Info().
 			Str("requestID", requestID).
 			Msg(response)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "subscribers": subscribers})
+	c.JSON(http.StatusOK, gin.H{
+		"requestID":   requestID,
+		"subscribers": subscriberArray,
+		"total":       total,
+		"next_cursor": next,
+	})
 }
 
 func GetSubscriberByID(c *gin.Context, dh *handlers.DatabaseHandler) {
@@ -113,7 +126,7 @@ func GetConfirmedSubscribers(c *gin.Context, dh *handlers.DatabaseHandler) (subs
 		return
 	}
 
-	if len(subscribers) > 0 {
+	if len(subscribers) == 0 {
 		log.Info().
 			Str("requestID", requestID).
 			Msg("No confirmed subscribers")
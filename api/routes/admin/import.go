@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/imports"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const maxImportSize = 20 << 20
+
+// PostSubscriberImport bulk loads subscribers from an uploaded CSV and
+// reports the rows that were skipped
+func PostSubscriberImport(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	header, e := c.FormFile("file")
+	if e != nil {
+		response = "Missing import file"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if header.Size > maxImportSize {
+		response = "Import file too large"
+		e = fmt.Errorf("file exceeds maximum size of: %d bytes", maxImportSize)
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	confirmed, _ := strconv.ParseBool(c.PostForm("confirmed"))
+	options := &models.ImportOptions{
+		EmailColumn:   c.PostForm("email_column"),
+		NameColumn:    c.PostForm("name_column"),
+		Confirmed:     confirmed,
+		ConsentSource: c.PostForm("consent_source"),
+	}
+	if e := models.ParseImportOptions(options); e != nil {
+		response = "Invalid import options"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	list, e := lists.GetList(c, dh.DB, c.PostForm("list"))
+	if e != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(e, lists.ErrListNotFound) {
+			status = http.StatusNotFound
+		}
+
+		response = "Could not import subscribers"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	file, e := header.Open()
+	if e != nil {
+		response = "Failed to read import file"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	rows, report, e := imports.ParseCSV(file, options)
+	if e != nil {
+		response = "Failed to parse import file"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	if e := imports.ImportSubscribers(c, tx, list, rows, options, report); e != nil {
+		response = "Failed to import subscribers"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("list", list.Slug).
+		Int("imported", report.Imported).
+		Int("duplicates", report.Duplicates).
+		Int("errors", len(report.Errors)).
+		Msg("Subscribers imported")
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "report": report})
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+type ListLoader struct {
+	models.List
+	// optional, lists without a template share the base confirmation email
+	Confirmation *models.Body `json:"confirmation"`
+}
+
+func GetLists(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	log.Info().
+		Str("requestID", requestID).
+		Msg("Fetching lists...")
+
+	listArray, e := lists.GetLists(c, dh.DB)
+	if e != nil {
+		response := "Failed to fetch lists"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "lists": listArray})
+}
+
+func PostList(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var loader ListLoader
+
+	requestID := c.GetString("requestID")
+
+	var response string
+	if e := c.ShouldBindJSON(&loader); e != nil {
+		response = "Could not create list"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if e := models.ParseList(&loader.List); e != nil {
+		response = "Could not create list"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if loader.Confirmation != nil {
+		if e := models.ParseConfirmationTemplate(loader.Confirmation); e != nil {
+			response = "Could not create list"
+			handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	if e := lists.InsertList(c, tx, &loader.List, loader.Confirmation); e != nil {
+		response = "Failed to insert list"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("list", loader.List.ID).
+		Msg("List created")
+
+	c.JSON(http.StatusCreated, gin.H{"requestID": requestID, "list": &loader.List})
+}
+
+// PutListRules sets whether the list allows, flags or rejects disposable and
+// role account addresses
+func PutListRules(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var rules models.ListRules
+
+	requestID := c.GetString("requestID")
+	listID := c.Param("id")
+
+	var response string
+	if e := c.ShouldBindJSON(&rules); e != nil {
+		response = "Could not update list rules"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if e := models.ParseListRules(&rules); e != nil {
+		response = "Could not update list rules"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	if e := lists.UpdateListRules(c, tx, listID, &rules); e != nil {
+		response = "Failed to update list rules"
+		status := http.StatusInternalServerError
+		if errors.Is(e, lists.ErrListNotFound) {
+			status = http.StatusNotFound
+		}
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("list", listID).
+		Msg("List rules updated")
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "rules": &rules})
+}
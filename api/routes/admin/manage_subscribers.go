@@ -0,0 +1,336 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/audit"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/sequences"
+	"github.com/solomonbaez/hyacinth/api/subscribers"
+	"github.com/solomonbaez/hyacinth/api/workers"
+)
+
+func GetManageSubscriber(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+
+	entries, e := audit.GetEntries(c, dh.DB, subscriber.ID)
+	if e != nil {
+		response := "Failed to fetch audit entries"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	session := sessions.Default(c)
+	flashes := session.Flashes()
+	session.Save()
+
+	c.HTML(http.StatusOK, "subscriber.html", gin.H{
+		"flashes":    flashes,
+		"subscriber": subscriber,
+		"statuses":   editableStatuses(),
+		"locked":     lockedStatus(subscriber.Status),
+		"entries":    entries,
+	})
+}
+
+func PostSubscriberEdit(c *gin.Context, dh *handlers.DatabaseHandler) {
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+
+	name, e := models.ParseName(c.PostForm("name"))
+	if e != nil {
+		flashRedirect(c, e.Error(), manageURL(subscriber))
+		return
+	}
+	status := subscriber.Status
+	if lockedStatus(subscriber.Status) {
+		// the status select is disabled, so only the name is submitted
+		if requested := c.PostForm("status"); requested != "" && requested != subscriber.Status {
+			flashRedirect(c, fmt.Sprintf("a %s subscriber keeps its status", subscriber.Status), manageURL(subscriber))
+			return
+		}
+	} else {
+		status, e = models.ParseStatus(c.PostForm("status"))
+		if e == nil && status == "suppressed" {
+			e = errors.New("use the suppress action to suppress a subscriber")
+		}
+		if e != nil {
+			flashRedirect(c, e.Error(), manageURL(subscriber))
+			return
+		}
+	}
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionUpdate,
+		SubscriberID: subscriber.ID,
+		Details: map[string]interface{}{
+			"name":   []string{subscriber.Name.String(), name.String()},
+			"status": []string{subscriber.Status, status},
+		},
+	}
+	confirmed := subscriber.Status != "confirmed" && status == "confirmed"
+	subscriber.Name, subscriber.Status = name, status
+
+	commitMutation(c, dh, entry, "Subscriber updated", manageURL(subscriber), func(tx pgx.Tx) error {
+		if e := subscribers.UpdateSubscriber(c, tx, subscriber); e != nil || !confirmed {
+			return e
+		}
+
+		return sequences.EnqueSequences(c, tx, subscriber.ID)
+	})
+}
+
+func PostSubscriberConfirm(c *gin.Context, dh *handlers.DatabaseHandler) {
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+	if subscriber.Status == "confirmed" || lockedStatus(subscriber.Status) {
+		flashRedirect(c, fmt.Sprintf("cannot confirm a %s subscriber", subscriber.Status), manageURL(subscriber))
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionConfirm,
+		SubscriberID: subscriber.ID,
+		Details:      map[string]interface{}{"status": []string{subscriber.Status, "confirmed"}},
+	}
+	subscriber.Status = "confirmed"
+
+	commitMutation(c, dh, entry, "Subscriber confirmed", manageURL(subscriber), func(tx pgx.Tx) error {
+		if e := subscribers.UpdateSubscriber(c, tx, subscriber); e != nil {
+			return e
+		}
+
+		return sequences.EnqueSequences(c, tx, subscriber.ID)
+	})
+}
+
+func PostSubscriberResendConfirmation(c *gin.Context, dh *handlers.DatabaseHandler) {
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+	if subscriber.Status != "pending" {
+		flashRedirect(c, "only pending subscribers can be sent a confirmation", manageURL(subscriber))
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionResendConfirmation,
+		SubscriberID: subscriber.ID,
+	}
+
+	commitMutation(c, dh, entry, "Confirmation email queued", manageURL(subscriber), func(tx pgx.Tx) error {
+		list, e := lists.GetList(c, tx, subscriber.ListID)
+		if e != nil {
+			return e
+		}
+
+		return workers.EnqueTransactionalTask(c, tx, list.ConfirmationIssueID, list.ID, subscriber.Email.String())
+	})
+}
+
+func PostSubscriberSuppress(c *gin.Context, dh *handlers.DatabaseHandler) {
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+
+	suppression, e := models.ParseSuppression(subscriber.Email.String(), c.PostForm("reason"), models.SuppressionSourceAdmin)
+	if e != nil {
+		flashRedirect(c, e.Error(), manageURL(subscriber))
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionSuppress,
+		SubscriberID: subscriber.ID,
+		Details: map[string]interface{}{
+			"reason": suppression.Reason,
+			"status": []string{subscriber.Status, "suppressed"},
+		},
+	}
+
+	commitMutation(c, dh, entry, "Subscriber suppressed", manageURL(subscriber), func(tx pgx.Tx) error {
+		return subscribers.Suppress(c, tx, subscriber.Email, suppression)
+	})
+}
+
+func PostSubscriberDelete(c *gin.Context, dh *handlers.DatabaseHandler) {
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionDelete,
+		SubscriberID: subscriber.ID,
+		Details:      map[string]interface{}{"list_id": subscriber.ListID, "status": subscriber.Status},
+	}
+
+	commitMutation(c, dh, entry, "Subscriber deleted", "/admin/dashboard", func(tx pgx.Tx) error {
+		return subscribers.DeleteSubscriber(c, tx, subscriber)
+	})
+}
+
+// loadSubscriber writes the error response itself when the subscriber
+// cannot be loaded
+func loadSubscriber(c *gin.Context, dh *handlers.DatabaseHandler) (subscriber *models.Subscriber, ok bool) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	id, e := uuid.Parse(c.Param("id"))
+	if e != nil {
+		response = "Invalid ID format"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	subscriber, e = subscribers.GetSubscriber(c, dh.DB, id.String())
+	if e != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(e, subscribers.ErrSubscriberNotFound) {
+			status = http.StatusNotFound
+		}
+
+		response = "Failed to fetch subscriber"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	// re-parse stored email to ensure data integrity
+	if subscriber.Email, e = models.ParseEmail(subscriber.Email.String()); e != nil {
+		response = "Invalid email"
+		handlers.HandleError(c, requestID, e, response, http.StatusConflict)
+		return
+	}
+
+	ok = true
+	return
+}
+
+// commitMutation applies mutate and records entry in a single transaction
+func commitMutation(c *gin.Context, dh *handlers.DatabaseHandler, entry *models.AuditEntry, message string, redirect string, mutate func(pgx.Tx) error) {
+	requestID := c.GetString("requestID")
+	entry.Actor = adminActor(c)
+
+	var response string
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	if e := mutate(tx); e != nil {
+		response = "Failed to update subscriber"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if e := audit.Record(c, tx, entry); e != nil {
+		response = "Failed to record audit entry"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("actor", entry.Actor).
+		Str("action", entry.Action).
+		Str("id", entry.SubscriberID).
+		Msg(message)
+
+	flashRedirect(c, message, redirect)
+}
+
+func flashRedirect(c *gin.Context, message string, redirect string) {
+	session := sessions.Default(c)
+	session.AddFlash(message)
+	session.Save()
+
+	c.Redirect(http.StatusSeeOther, redirect)
+}
+
+func adminActor(c *gin.Context) string {
+	user := sessions.Default(c).Get("user")
+	if user == nil {
+		return "unknown"
+	}
+
+	return fmt.Sprintf("%v", user)
+}
+
+func manageURL(subscriber *models.Subscriber) string {
+	return "/admin/subscribers/" + subscriber.ID + "/manage"
+}
+
+// suppression goes through its own action so it is always recorded
+func editableStatuses() (statuses []string) {
+	for _, status := range models.SubscriberStatuses {
+		if status != "suppressed" {
+			statuses = append(statuses, status)
+		}
+	}
+
+	return
+}
+
+// suppressed and bounced subscriptions are backed by a suppression entry, the
+// edit form must not move them back into the mailing pool
+func lockedStatus(status string) bool {
+	return status == "suppressed" || status == "bounced"
+}
+
+// GetEmailCollisions lists subscriptions that share a mailbox with an older
+// subscription on the same list
+func GetEmailCollisions(c *gin.Context, dh *handlers.DatabaseHandler, settings *configs.NormalizationSettings) {
+	requestID := c.GetString("requestID")
+
+	collisions, e := subscribers.GetEmailCollisions(c, dh.DB, settings.ProviderRules)
+	if e != nil {
+		response := "Failed to fetch email collisions"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "collisions": collisions})
+}
+
+// GetFlaggedSubscribers lists subscriptions flagged as disposable or role
+// account addresses for review
+func GetFlaggedSubscribers(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	flagged, e := subscribers.GetFlaggedSubscribers(c, dh.DB)
+	if e != nil {
+		response := "Failed to fetch flagged subscribers"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "subscribers": flagged})
+}
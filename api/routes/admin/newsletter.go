@@ -5,10 +5,16 @@ import (
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
 	"github.com/solomonbaez/hyacinth/api/idempotency"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/segments"
 )
 
-func GetNewsletter(c *gin.Context) {
+func GetNewsletter(c *gin.Context, dh *handlers.DatabaseHandler) {
 	session := sessions.Default(c)
 	flashes := session.Flashes()
 
@@ -18,5 +24,32 @@ func GetNewsletter(c *gin.Context) {
 		session.Save()
 	}
 
-	c.HTML(http.StatusOK, "newsletter.html", gin.H{"flashes": flashes, "idempotency_key": key})
+	var segmentList []*models.Segment
+	rows, e := dh.DB.Query(c, "SELECT segment_id, name, match, rules FROM segments ORDER BY name")
+	if e == nil {
+		segmentList, e = pgx.CollectRows[*models.Segment](rows, segments.BuildSegment)
+	}
+	if e != nil {
+		log.Error().
+			Err(e).
+			Msg("Failed to fetch segments")
+
+		flashes = append(flashes, "Failed to fetch segments, issues will target every confirmed subscriber")
+	}
+
+	listArray, e := lists.GetLists(c, dh.DB)
+	if e != nil {
+		log.Error().
+			Err(e).
+			Msg("Failed to fetch lists")
+
+		flashes = append(flashes, "Failed to fetch lists, issues will be sent to the default list")
+	}
+
+	c.HTML(http.StatusOK, "newsletter.html", gin.H{
+		"flashes":         flashes,
+		"idempotency_key": key,
+		"segments":        segmentList,
+		"lists":           listArray,
+	})
 }
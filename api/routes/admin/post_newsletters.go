@@ -2,23 +2,32 @@ package routes
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/abtests"
+	"github.com/solomonbaez/hyacinth/api/attachments"
 	"github.com/solomonbaez/hyacinth/api/clients"
+	"github.com/solomonbaez/hyacinth/api/configs"
 	"github.com/solomonbaez/hyacinth/api/handlers"
 	"github.com/solomonbaez/hyacinth/api/idempotency"
+	"github.com/solomonbaez/hyacinth/api/lists"
 	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/segments"
 	"github.com/solomonbaez/hyacinth/api/workers"
 )
 
-func InsertNewsletter(c *gin.Context, tx pgx.Tx, content *models.Body) (id *string, err error) {
+// InsertNewsletter stores the issue, schedule is nil for issues sent right
+// away
+func InsertNewsletter(c *gin.Context, tx pgx.Tx, listID string, content *models.Body, tracking models.Tracking, schedule *models.Schedule) (id *string, err error) {
 	defer func() {
 		if err != nil {
 			tx.Rollback(c)
@@ -31,10 +40,22 @@ func InsertNewsletter(c *gin.Context, tx pgx.Tx, content *models.Body) (id *stri
 				title, 
 				text_content,
 				html_content,
+				list_id,
+				track_opens,
+				track_clicks,
+				send_at,
+				send_timezone,
+				local_time,
 				published_at
 			)
-			VALUES ($1, $2, $3, $4, now())`
-	_, e := tx.Exec(c, query, issueID, content.Title, content.Text, content.Html)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now())`
+	var sendAt *time.Time
+	var sendTimezone *string
+	var localTime bool
+	if schedule != nil {
+		sendAt, sendTimezone, localTime = &schedule.SendAt, &schedule.Timezone, schedule.LocalTime
+	}
+	_, e := tx.Exec(c, query, issueID, content.Title, content.Text, content.Html, listID, tracking.Opens, tracking.Clicks, sendAt, sendTimezone, localTime)
 	if e != nil {
 		err = fmt.Errorf("failed to insert newsletter issue: %w", e)
 		return
@@ -44,7 +65,7 @@ func InsertNewsletter(c *gin.Context, tx pgx.Tx, content *models.Body) (id *stri
 	return
 }
 
-func PostNewsletter(c *gin.Context, dh *handlers.DatabaseHandler, client *clients.SMTPClient) {
+func PostNewsletter(c *gin.Context, dh *handlers.DatabaseHandler, client *clients.SMTPClient, cfg *configs.AttachmentSettings) {
 	var newsletter models.Newsletter
 	var body models.Body
 
@@ -65,8 +86,77 @@ func PostNewsletter(c *gin.Context, dh *handlers.DatabaseHandler, client *client
 		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
 		return
 	}
+	if e := models.ParseTemplates(&body); e != nil {
+		response = "Failed to parse newsletter templates"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
 	newsletter.Content = &body
 
+	// engagement is only tracked for issues opting in
+	tracking := models.Tracking{
+		Opens:  c.PostForm("track_opens") != "",
+		Clicks: c.PostForm("track_clicks") != "",
+	}
+
+	subjectTest, e := ParseSubjectTestForm(c, body.Title, tracking)
+	if e != nil {
+		response = "Failed to parse subject test"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	schedule, e := models.ParseSchedule(c.PostForm("send_at"), c.PostForm("send_timezone"), c.PostForm("local_time") != "", time.Now())
+	if e != nil {
+		response = "Failed to parse schedule"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	// held back recipients are released at once when the winner is picked,
+	// which would ignore their zones
+	if schedule != nil && schedule.LocalTime && subjectTest != nil {
+		response = "Failed to parse schedule"
+		e = errors.New("subject tests can not be sent in local time")
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	form, e := c.MultipartForm()
+	if e != nil && !errors.Is(e, http.ErrNotMultipart) {
+		response = "Failed to parse uploads"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	newsletter.Attachments, e = attachments.ParseUploads(form, cfg)
+	if e != nil {
+		response = "Failed to parse uploads"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	list, e := lists.GetList(c, dh.DB, c.PostForm("list"))
+	if e != nil {
+		response = "Failed to fetch list"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	var audience []*models.Segment
+	segmentIDs, e := ParseSegmentIDs(c.PostFormArray("segments"))
+	if e != nil {
+		response = "Failed to parse segments"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if len(segmentIDs) > 0 {
+		audience, e = segments.GetSegments(c, dh.DB, segmentIDs)
+		if e != nil {
+			response = "Failed to fetch segments"
+			handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+			return
+		}
+	}
+
 	transaction, e := idempotency.TryProcessing(c, dh, id, key)
 	if e != nil {
 		response = "Failed to process transaction"
@@ -79,14 +169,35 @@ func PostNewsletter(c *gin.Context, dh *handlers.DatabaseHandler, client *client
 			Str("id", id).
 			Msg("No saved response, processing request...")
 
-		issue_id, e := InsertNewsletter(c, transaction.StartProcessing, newsletter.Content)
+		issue_id, e := InsertNewsletter(c, transaction.StartProcessing, list.ID, newsletter.Content, tracking, schedule)
 		if e != nil {
 			response = "Failed to store newsletter"
 			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
 			return
 		}
 
-		if e := workers.EnqueDeliveryTasks(c, transaction.StartProcessing, *issue_id); e != nil {
+		if e := attachments.InsertAttachments(c, transaction.StartProcessing, *issue_id, newsletter.Attachments, cfg); e != nil {
+			response = "Failed to store attachments"
+			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+			return
+		}
+
+		if e := segments.InsertIssueSegments(c, transaction.StartProcessing, *issue_id, audience); e != nil {
+			response = "Failed to store issue segments"
+			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+			return
+		}
+
+		if subjectTest != nil {
+			subjectTest.IssueID = *issue_id
+			if e := abtests.InsertSubjectTest(c, transaction.StartProcessing, subjectTest, schedule); e != nil {
+				response = "Failed to store subject test"
+				handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if e := workers.EnqueDeliveryTasks(c, transaction.StartProcessing, *issue_id, list.ID, audience, subjectTest, schedule); e != nil {
 			response = "Failed to enqueue delivery tasks"
 			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
 			return
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/audit"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/privacy"
+)
+
+// GetSubscriberData downloads everything stored about the subscriber's
+// email address, across every list
+func GetSubscriberData(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+
+	var response string
+	archive, e := privacy.ExportData(c, dh.DB, subscriber.Email)
+	if e != nil {
+		response = "Failed to export subscriber data"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Actor:        adminActor(c),
+		Action:       models.AuditActionExport,
+		SubscriberID: subscriber.ID,
+	}
+	if e := audit.Record(c, dh.DB, entry); e != nil {
+		response = "Failed to record audit entry"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("actor", entry.Actor).
+		Str("id", subscriber.ID).
+		Msg("Subscriber data exported")
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archive.Filename()))
+	c.JSON(http.StatusOK, archive)
+}
+
+// PostSubscriberErase hard deletes the subscriber's email address from every
+// list, only the erasure itself remains in the audit log
+func PostSubscriberErase(c *gin.Context, dh *handlers.DatabaseHandler) {
+	subscriber, ok := loadSubscriber(c, dh)
+	if !ok {
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionErase,
+		SubscriberID: subscriber.ID,
+		Details:      map[string]interface{}{"email_hash": models.HashEmail(subscriber.Email)},
+	}
+
+	commitMutation(c, dh, entry, "Subscriber data erased", "/admin/dashboard", func(tx pgx.Tx) error {
+		return privacy.EraseData(c, tx, subscriber.Email)
+	})
+}
@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/segments"
+)
+
+func GetSegments(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	log.Info().
+		Str("requestID", requestID).
+		Msg("Fetching segments...")
+
+	var response string
+	rows, e := dh.DB.Query(c, "SELECT segment_id, name, match, rules FROM segments ORDER BY name")
+	if e != nil {
+		response = "Failed to fetch segments"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	segmentList, e := pgx.CollectRows[*models.Segment](rows, segments.BuildSegment)
+	if e != nil {
+		response = "Failed to parse segments"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "segments": segmentList})
+}
+
+func PostSegment(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var segment models.Segment
+
+	requestID := c.GetString("requestID")
+
+	var response string
+	if e := c.ShouldBindJSON(&segment); e != nil {
+		response = "Could not create segment"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if e := models.ParseSegment(&segment); e != nil {
+		response = "Could not create segment"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	if e := segments.InsertSegment(c, dh.DB, &segment); e != nil {
+		response = "Failed to insert segment"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("segment", segment.ID).
+		Msg("Segment created")
+
+	c.JSON(http.StatusCreated, gin.H{"requestID": requestID, "segment": &segment})
+}
+
+func DeleteSegment(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	id, e := uuid.Parse(c.Param("id"))
+	if e != nil {
+		response = "Invalid ID format"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	// segments targeted by an issue are kept for its history
+	tag, e := dh.DB.Exec(c, "DELETE FROM segments WHERE segment_id = $1", id)
+	if e != nil {
+		response = "Failed to delete segment"
+		handlers.HandleError(c, requestID, e, response, http.StatusConflict)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		response = "Segment not found"
+		handlers.HandleError(c, requestID, errors.New("no segment with that ID"), response, http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "segment": "Segment deleted"})
+}
+
+// GetSegmentPreview counts the recipients an issue to the given list
+// targeting the given segments would be delivered to
+func GetSegmentPreview(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	list, e := lists.GetList(c, dh.DB, c.Query("list"))
+	if e != nil {
+		response = "Failed to fetch list"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	segmentIDs, e := ParseSegmentIDs(c.QueryArray("segment"))
+	if e != nil {
+		response = "Failed to parse segments"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	var audience []*models.Segment
+	if len(segmentIDs) > 0 {
+		audience, e = segments.GetSegments(c, dh.DB, segmentIDs)
+		if e != nil {
+			response = "Failed to fetch segments"
+			handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+			return
+		}
+	}
+
+	count, e := segments.CountRecipients(c, dh.DB, list.ID, audience)
+	if e != nil {
+		response = "Failed to count recipients"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "recipients": count})
+}
+
+func ParseSegmentIDs(values []string) (ids []string, err error) {
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+
+		id, e := uuid.Parse(value)
+		if e != nil {
+			err = fmt.Errorf("invalid segment ID %s: %w", value, e)
+			return
+		}
+
+		if !seen[id.String()] {
+			seen[id.String()] = true
+			ids = append(ids, id.String())
+		}
+	}
+
+	return
+}
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/sequences"
+)
+
+type SequenceLoader struct {
+	models.Sequence
+	// list ID or slug, the default list when empty
+	List string `json:"list"`
+}
+
+func GetSequences(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	log.Info().
+		Str("requestID", requestID).
+		Msg("Fetching sequences...")
+
+	sequenceList, e := sequences.GetSequences(c, dh.DB)
+	if e != nil {
+		response := "Failed to fetch sequences"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "sequences": sequenceList})
+}
+
+func PostSequence(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var loader SequenceLoader
+
+	requestID := c.GetString("requestID")
+
+	var response string
+	if e := c.ShouldBindJSON(&loader); e != nil {
+		response = "Could not create sequence"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if e := models.ParseSequence(&loader.Sequence); e != nil {
+		response = "Could not create sequence"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	list, e := lists.GetList(c, tx, loader.List)
+	if e != nil {
+		response = "Could not create sequence"
+		status := http.StatusInternalServerError
+		if errors.Is(e, lists.ErrListNotFound) {
+			status = http.StatusNotFound
+		}
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+	loader.Sequence.ListID = list.ID
+
+	if e := sequences.InsertSequence(c, tx, &loader.Sequence); e != nil {
+		response = "Failed to insert sequence"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("sequence", loader.Sequence.ID).
+		Msg("Sequence created")
+
+	c.JSON(http.StatusCreated, gin.H{"requestID": requestID, "sequence": &loader.Sequence})
+}
+
+// DeleteSequence stops the sequence for every subscriber, including those
+// part way through it
+func DeleteSequence(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	id, e := uuid.Parse(c.Param("id"))
+	if e != nil {
+		response = "Invalid ID format"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	if e := sequences.DeleteSequence(c, dh.DB, id.String()); e != nil {
+		response = "Failed to delete sequence"
+		status := http.StatusInternalServerError
+		if errors.Is(e, sequences.ErrSequenceNotFound) {
+			response = "Sequence not found"
+			status = http.StatusNotFound
+		}
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "sequence": "Sequence deleted"})
+}
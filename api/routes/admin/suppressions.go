@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+)
+
+const defaultSuppressionReason = "bulk import"
+
+type SuppressionLoader struct {
+	// an email address or a domain
+	Entry  string `json:"entry" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+func GetSuppressions(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	suppressionArray, total, e := suppressions.GetSuppressions(c, dh.DB, c.Query("q"))
+	if e != nil {
+		response := "Failed to fetch suppressions"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "suppressions": suppressionArray, "total": total})
+}
+
+// PostSuppression blocks an address or domain and suppresses every matching
+// subscription
+func PostSuppression(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var loader SuppressionLoader
+
+	requestID := c.GetString("requestID")
+
+	var response string
+	if e := c.ShouldBindJSON(&loader); e != nil {
+		response = "Could not create suppression"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	suppression, e := models.ParseSuppression(loader.Entry, loader.Reason, models.SuppressionSourceAdmin)
+	if e != nil {
+		response = "Could not create suppression"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	inserted, e := suppressions.Insert(c, tx, suppression)
+	if e != nil {
+		response = "Failed to insert suppression"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if !inserted {
+		response = "Already suppressed"
+		handlers.HandleError(c, requestID, errors.New("suppression exists"), response, http.StatusConflict)
+		return
+	}
+	if e := suppressions.Apply(c, tx); e != nil {
+		response = "Failed to apply suppression"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", suppression.ID).
+		Str("kind", suppression.Kind).
+		Msg("Suppression created")
+
+	c.JSON(http.StatusCreated, gin.H{"requestID": requestID, "suppression": suppression})
+}
+
+func DeleteSuppression(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	id, e := uuid.Parse(c.Param("id"))
+	if e != nil {
+		response = "Invalid ID format"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	if e := suppressions.DeleteSuppression(c, dh.DB, id.String()); e != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(e, suppressions.ErrSuppressionNotFound):
+			status = http.StatusNotFound
+		case errors.Is(e, suppressions.ErrErasureTombstone):
+			status = http.StatusConflict
+		}
+
+		response = "Failed to delete suppression"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", id.String()).
+		Msg("Suppression deleted")
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "suppression": "Suppression deleted"})
+}
+
+// PostSuppressionImport bulk loads a CSV with an entry column and an
+// optional reason column
+func PostSuppressionImport(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	header, e := c.FormFile("file")
+	if e != nil {
+		response = "Missing import file"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	if header.Size > maxImportSize {
+		response = "Import file too large"
+		e = fmt.Errorf("file exceeds maximum size of: %d bytes", maxImportSize)
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	reason := c.DefaultPostForm("reason", defaultSuppressionReason)
+	if _, e := models.ParseReason(reason); e != nil {
+		response = "Invalid reason"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	file, e := header.Open()
+	if e != nil {
+		response = "Failed to read import file"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	entries, report, e := suppressions.ParseCSV(file, reason)
+	if e != nil {
+		response = "Failed to parse import file"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	if e := suppressions.ImportSuppressions(c, tx, entries, report); e != nil {
+		response = "Failed to import suppressions"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Int("added", report.Added).
+		Int("duplicates", report.Duplicates).
+		Int("errors", len(report.Errors)).
+		Msg("Suppressions imported")
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "report": report})
+}
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+type TagLoader struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+func PostSubscriberTags(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var loader TagLoader
+
+	requestID := c.GetString("requestID")
+
+	var response string
+	id, e := uuid.Parse(c.Param("id"))
+	if e != nil {
+		response = "Invalid ID format"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	if e := c.ShouldBindJSON(&loader); e != nil {
+		response = "Could not tag subscriber"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tags := make([]string, 0, len(loader.Tags))
+	for _, t := range loader.Tags {
+		tag, e := models.ParseTag(t)
+		if e != nil {
+			response = "Could not tag subscriber"
+			handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+			return
+		}
+		tags = append(tags, tag.String())
+	}
+
+	query := `INSERT INTO subscriber_tags (subscriber_id, tag, created)
+			SELECT id, tag, now()
+			FROM subscriptions, unnest($2::text[]) AS tag
+			WHERE id = $1
+			ON CONFLICT DO NOTHING`
+	if _, e := dh.DB.Exec(c, query, id, tags); e != nil {
+		response = "Failed to tag subscriber"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", id.String()).
+		Msg(fmt.Sprintf("Tagged subscriber with %v", tags))
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "tags": tags})
+}
+
+func DeleteSubscriberTag(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	id, e := uuid.Parse(c.Param("id"))
+	if e != nil {
+		response = "Invalid ID format"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tag, e := models.ParseTag(c.Param("tag"))
+	if e != nil {
+		response = "Invalid tag"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	result, e := dh.DB.Exec(c, "DELETE FROM subscriber_tags WHERE subscriber_id = $1 AND tag = $2", id, tag.String())
+	if e != nil {
+		response = "Failed to remove tag"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected() == 0 {
+		response = "Tag not found"
+		handlers.HandleError(c, requestID, errors.New("subscriber does not have that tag"), response, http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "tag": "Tag removed"})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/sequences"
 )
 
 func ConfirmSubscriber(c *gin.Context, dh *handlers.DatabaseHandler) {
@@ -23,14 +24,41 @@ func ConfirmSubscriber(c *gin.Context, dh *handlers.DatabaseHandler) {
 		return
 	}
 
-	query = "UPDATE subscriptions SET status = 'confirmed' WHERE id = $1"
-	_, e = dh.DB.Exec(c, query, id)
+	tx, e := dh.DB.Begin(c)
 	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	// the previous status tells a first confirmation from a repeated visit
+	var previous string
+	query = `UPDATE subscriptions SET status = 'confirmed'
+			FROM (SELECT status FROM subscriptions WHERE id = $1 FOR UPDATE) AS previous
+			WHERE subscriptions.id = $1
+			RETURNING previous.status`
+	if e = tx.QueryRow(c, query, id).Scan(&previous); e != nil {
 		response = "Failed to confirm subscription"
 		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
 		return
 	}
 
+	// sequences start once, revisiting the link does not restart them
+	if previous == "pending" {
+		if e = sequences.EnqueSequences(c, tx, id); e != nil {
+			response = "Failed to enque sequences"
+			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if e = tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
 	log.Info().
 		Str("id", id).
 		Msg("Subscription confirmed")
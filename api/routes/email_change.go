@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/preferences"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	"github.com/solomonbaez/hyacinth/api/workers"
+)
+
+// PostEmailChange sends a confirmation to the requested address, subscriptions
+// only move once it is confirmed. Account emails are sent as the default list.
+func PostEmailChange(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer) {
+	requestID := c.GetString("requestID")
+	session := sessions.Default(c)
+	redirect := "/preferences/" + c.Param("token")
+
+	subscriberPreferences, _, status, e := loadPreferences(c, dh, signer, false)
+	if e != nil {
+		response := "Failed to load preferences"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	newEmail, e := models.ParseEmail(c.PostForm("email"))
+	if e == nil && newEmail == subscriberPreferences.Email {
+		e = errors.New("new email matches the current email")
+	}
+	if e == nil {
+		var taken bool
+		if taken, e = preferences.EmailTaken(c, dh.DB, newEmail); e == nil && taken {
+			e = preferences.ErrEmailTaken
+		}
+	}
+	if e != nil {
+		log.Error().
+			Str("requestID", requestID).
+			Err(e).
+			Msg("Failed to parse email change")
+
+		session.AddFlash(e.Error())
+		session.Save()
+		c.Redirect(http.StatusSeeOther, redirect)
+		return
+	}
+
+	token, e := handlers.GenerateCSPRNG(tokenLength)
+	if e != nil {
+		response := "Failed to generate email change token"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	change := &preferences.EmailChange{
+		Token:    token,
+		Email:    subscriberPreferences.Email,
+		NewEmail: newEmail,
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response := "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	if e := preferences.InsertEmailChange(c, tx, change); e != nil {
+		response := "Failed to request email change"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	e = workers.EnqueTransactionalTask(c, tx, preferences.EmailChangeConfirmationIssueID, lists.DefaultListID, newEmail.String())
+	if e != nil {
+		response := "Failed to enqueue email change confirmation"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		response := "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", subscriberPreferences.SubscriberID).
+		Msg("Email change requested")
+
+	session.AddFlash("Please confirm the change from your new email address")
+	session.Save()
+	c.Redirect(http.StatusSeeOther, redirect)
+}
+
+func ConfirmEmailChange(c *gin.Context, dh *handlers.DatabaseHandler) {
+	var response string
+
+	requestID := c.GetString("requestID")
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	change, e := preferences.GetEmailChange(c, tx, c.Param("token"))
+	if e != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(e, preferences.ErrEmailChangeNotFound) {
+			status = http.StatusNotFound
+		}
+
+		response = "Failed to confirm email change"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	if e := preferences.ApplyEmailChange(c, tx, change); e != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(e, preferences.ErrEmailTaken) {
+			status = http.StatusConflict
+		}
+
+		response = "Failed to change email"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	// the old address is told about the change in case it was not requested
+	e = workers.EnqueTransactionalTask(c, tx, preferences.EmailChangeNotificationIssueID, lists.DefaultListID, change.Email.String())
+	if e != nil {
+		response = "Failed to enqueue email change notification"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Msg("Email change confirmed")
+
+	c.JSON(http.StatusAccepted, gin.H{"requestID": requestID, "subscriber": "Email address changed"})
+}
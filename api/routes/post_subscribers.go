@@ -2,21 +2,36 @@ package routes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/attributes"
+	"github.com/solomonbaez/hyacinth/api/blocklist"
 	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
 	"github.com/solomonbaez/hyacinth/api/models"
 	"github.com/solomonbaez/hyacinth/api/workers"
 )
 
-const tokenLength = 25
+const (
+	tokenLength               = 25
+	normalizedEmailConstraint = "subscriptions_list_id_normalized_email_key"
+)
+
+// raised when the list already has the address in any of its forms, e.g.
+// User@Example.com for user@example.com
+var ErrAlreadySubscribed = errors.New("email address is already subscribed to the list")
+
+// raised when the list rejects the blocklist match of the address
+var ErrBlocklisted = errors.New("email address is not accepted by the list")
 
-func Subscribe(c *gin.Context, dh *handlers.DatabaseHandler) {
+func Subscribe(c *gin.Context, dh *handlers.DatabaseHandler, blocklist *blocklist.Blocklist) {
 	var subscriber models.Subscriber
 	var loader *handlers.Loader
 
@@ -50,17 +65,80 @@ func Subscribe(c *gin.Context, dh *handlers.DatabaseHandler) {
 		return
 	}
 
+	var timezone *string
+	if loader.Timezone != "" {
+		parsed, e := models.ParseTimezone(loader.Timezone)
+		if e != nil {
+			response = "Could not subscribe"
+			handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+			return
+		}
+		timezone = &parsed
+	}
+
+	list, e := lists.GetList(c, tx, loader.List)
+	if e != nil {
+		response = "Could not subscribe"
+		status := http.StatusInternalServerError
+		if errors.Is(e, lists.ErrListNotFound) {
+			status = http.StatusNotFound
+		}
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	// lists decide whether disposable and role account addresses are welcome
+	var flag string
+	if reason := blocklist.Match(subscriberEmail); reason != "" {
+		switch list.Policy(reason) {
+		case models.BlockPolicyReject:
+			response = "Could not subscribe"
+			e = fmt.Errorf("%w: %s address", ErrBlocklisted, reason)
+			handlers.HandleError(c, requestID, e, response, http.StatusUnprocessableEntity)
+			return
+		case models.BlockPolicyFlag:
+			flag = reason
+		}
+	}
+
+	subscriberAttributes := models.Attributes{}
+	if len(loader.Attributes) > 0 {
+		definitions, e := attributes.GetFieldDefinitions(c, tx)
+		if e != nil {
+			response = "Failed to fetch subscriber fields"
+			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+			return
+		}
+
+		parsed, e := models.ParseAttributes(loader.Attributes, definitions)
+		if e != nil {
+			response = "Could not subscribe"
+			handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+			return
+		}
+		subscriberAttributes, _ = attributes.Split(parsed)
+	}
+
 	subscriber = models.Subscriber{
-		Email:  subscriberEmail,
-		Name:   subscriberName,
-		Status: "pending",
+		Email:      subscriberEmail,
+		Name:       subscriberName,
+		Status:     "pending",
+		ListID:     list.ID,
+		Attributes: subscriberAttributes,
+		Flag:       flag,
+		Timezone:   timezone,
 	}
 	if e := insertSubscriber(c, tx, &subscriber); e != nil {
 		response = "Failed to insert subscriber"
-		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(e, ErrAlreadySubscribed) {
+			response = "Already subscribed"
+			status = http.StatusConflict
+		}
+		handlers.HandleError(c, requestID, e, response, status)
 		return
 	}
-	if e := workers.EnqueConfirmationTasks(c, tx, subscriber.Email.String()); e != nil {
+	if e := workers.EnqueConfirmationTasks(c, tx, subscriber.Email.String(), list); e != nil {
 		response = "Failed to enque confirmation email"
 		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
 		return
@@ -81,9 +159,26 @@ func insertSubscriber(c context.Context, tx pgx.Tx, subscriber *models.Subscribe
 
 	email := subscriber.Email.String()
 	name := subscriber.Name.String()
-	query := "INSERT INTO subscriptions (id, email, name, status, created) VALUES ($1, $2, $3, $4, now())"
-	_, e := tx.Exec(c, query, newID, email, name, "pending")
+	var flag *string
+	if subscriber.Flag != "" {
+		flag = &subscriber.Flag
+	}
+	// without a timezone the zone of the address' other subscriptions is used
+	query := `INSERT INTO subscriptions (id, email, name, status, list_id, attributes, flag, timezone, created)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, (
+				SELECT timezone FROM subscriptions
+				WHERE normalized_email = normalize_email($2) AND timezone IS NOT NULL
+				ORDER BY created DESC
+				LIMIT 1
+			)), now())`
+	_, e := tx.Exec(c, query, newID, email, name, "pending", subscriber.ListID, subscriber.Attributes, flag, subscriber.Timezone)
 	if e != nil {
+		var pgError *pgconn.PgError
+		if errors.As(e, &pgError) && pgError.ConstraintName == normalizedEmailConstraint {
+			err = ErrAlreadySubscribed
+			return
+		}
+
 		err = fmt.Errorf("failed to insert new subscriber: %w", e)
 		return
 	}
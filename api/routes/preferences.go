@@ -0,0 +1,225 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/preferences"
+	"github.com/solomonbaez/hyacinth/api/signing"
+)
+
+var pauseOptions = []int{7, 30, 90}
+
+// GetPreferences renders the preference center, an expired link only offers
+// to unsubscribe
+func GetPreferences(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer) {
+	requestID := c.GetString("requestID")
+
+	subscriberPreferences, expired, status, e := loadPreferences(c, dh, signer, true)
+	if e != nil {
+		response := "Failed to load preferences"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	session := sessions.Default(c)
+	flashes := session.Flashes()
+	session.Save()
+
+	c.HTML(http.StatusOK, "preferences.html", gin.H{
+		"flashes":     flashes,
+		"token":       c.Param("token"),
+		"preferences": subscriberPreferences,
+		"pauses":      pauseOptions,
+		"expired":     expired,
+	})
+}
+
+// PostPreferences applies the preference form to every subscription held by
+// the subscriber's email address
+func PostPreferences(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer) {
+	requestID := c.GetString("requestID")
+	session := sessions.Default(c)
+	redirect := "/preferences/" + c.Param("token")
+
+	// unsubscribing keeps working from expired links
+	unsubscribe := c.PostForm("action") == "unsubscribe"
+	subscriberPreferences, _, status, e := loadPreferences(c, dh, signer, unsubscribe)
+	if e != nil {
+		response := "Failed to load preferences"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	if unsubscribe {
+		if e := preferences.Unsubscribe(c, dh.DB, subscriberPreferences.Email); e != nil {
+			response := "Failed to unsubscribe"
+			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+			return
+		}
+
+		log.Info().
+			Str("requestID", requestID).
+			Str("id", subscriberPreferences.SubscriberID).
+			Msg("Subscriber unsubscribed from all lists")
+
+		session.AddFlash("You have been unsubscribed from all lists")
+		session.Save()
+		c.Redirect(http.StatusSeeOther, redirect)
+		return
+	}
+
+	if e := parsePreferencesForm(c, subscriberPreferences); e != nil {
+		log.Error().
+			Str("requestID", requestID).
+			Err(e).
+			Msg("Failed to parse preferences")
+
+		session.AddFlash(e.Error())
+		session.Save()
+		c.Redirect(http.StatusSeeOther, redirect)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response := "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	if e := preferences.UpdatePreferences(c, tx, subscriberPreferences); e != nil {
+		response := "Failed to update preferences"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		response := "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", subscriberPreferences.SubscriberID).
+		Msg("Subscriber preferences updated")
+
+	session.AddFlash("Preferences saved")
+	session.Save()
+	c.Redirect(http.StatusSeeOther, redirect)
+}
+
+// PostOneClickUnsubscribe handles RFC 8058 List-Unsubscribe-Post requests
+// sent by mail clients, expired links are still honoured
+func PostOneClickUnsubscribe(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer) {
+	requestID := c.GetString("requestID")
+
+	subscriberPreferences, _, status, e := loadPreferences(c, dh, signer, true)
+	if e != nil {
+		response := "Failed to unsubscribe"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	if e := preferences.Unsubscribe(c, dh.DB, subscriberPreferences.Email); e != nil {
+		response := "Failed to unsubscribe"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", subscriberPreferences.SubscriberID).
+		Msg("Subscriber unsubscribed from all lists")
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "subscriber": "Unsubscribed"})
+}
+
+// loadPreferences verifies the preferences token, expired tokens are only
+// accepted with allowExpired and refused with 410 otherwise
+func loadPreferences(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer, allowExpired bool) (subscriberPreferences *models.Preferences, expired bool, status int, err error) {
+	subscriberID, e := signer.Verify(signing.PurposePreferences, c.Param("token"))
+	if expired = errors.Is(e, signing.ErrExpiredToken); expired && !allowExpired {
+		status = http.StatusGone
+		err = e
+		return
+	}
+	if e != nil && !expired {
+		status = http.StatusNotFound
+		err = e
+		return
+	}
+
+	subscriberPreferences, e = preferences.GetPreferences(c, dh.DB, subscriberID)
+	if e != nil {
+		status = http.StatusInternalServerError
+		if errors.Is(e, preferences.ErrSubscriberNotFound) {
+			status = http.StatusNotFound
+		}
+
+		err = e
+		return
+	}
+
+	// re-parse stored email to ensure data integrity
+	if subscriberPreferences.Email, e = models.ParseEmail(subscriberPreferences.Email.String()); e != nil {
+		status = http.StatusInternalServerError
+		err = e
+		return
+	}
+
+	return
+}
+
+func parsePreferencesForm(c *gin.Context, subscriberPreferences *models.Preferences) (err error) {
+	name, e := models.ParseName(c.PostForm("name"))
+	if e != nil {
+		err = e
+		return
+	}
+	subscriberPreferences.Name = name
+	subscriberPreferences.TrackingOptOut = c.PostForm("tracking_opt_out") != ""
+
+	// an empty timezone falls back to the zone of each issue
+	subscriberPreferences.Timezone = nil
+	if raw := c.PostForm("timezone"); raw != "" {
+		timezone, e := models.ParseTimezone(raw)
+		if e != nil {
+			err = e
+			return
+		}
+		subscriberPreferences.Timezone = &timezone
+	}
+
+	selected := make(map[string]bool)
+	for _, id := range c.PostFormArray("lists") {
+		selected[id] = true
+	}
+	for _, list := range subscriberPreferences.Lists {
+		list.Subscribed = selected[list.ID]
+		delete(selected, list.ID)
+	}
+	for id := range selected {
+		err = fmt.Errorf("unknown list: %s", id)
+		return
+	}
+
+	// an empty pause leaves any current pause untouched
+	if pause := c.PostForm("pause"); pause != "" {
+		days, e := models.ParsePauseDays(pause)
+		if e != nil {
+			err = e
+			return
+		}
+		subscriberPreferences.PausedUntil = preferences.PauseUntil(days)
+	}
+
+	return
+}
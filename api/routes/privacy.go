@@ -0,0 +1,120 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/audit"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/privacy"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	"github.com/solomonbaez/hyacinth/api/workers"
+)
+
+// PostDataExport emails the subscriber a short lived link to their data, the
+// preferences link alone never hands out the archive. Account emails are sent
+// as the default list.
+func PostDataExport(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer) {
+	requestID := c.GetString("requestID")
+	session := sessions.Default(c)
+
+	var response string
+	subscriberPreferences, _, status, e := loadPreferences(c, dh, signer, false)
+	if e != nil {
+		response = "Failed to load preferences"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	token, e := handlers.GenerateCSPRNG(tokenLength)
+	if e != nil {
+		response = "Failed to generate data export token"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	if e := privacy.InsertDataExport(c, tx, token, subscriberPreferences.SubscriberID); e != nil {
+		response = "Failed to request data export"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	e = workers.EnqueTransactionalTask(c, tx, privacy.DataExportIssueID, lists.DefaultListID, subscriberPreferences.Email.String())
+	if e != nil {
+		response = "Failed to enqueue data export link"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", subscriberPreferences.SubscriberID).
+		Msg("Data export requested")
+
+	session.AddFlash("We emailed you a link to download your data, it is valid for one hour")
+	session.Save()
+	c.Redirect(http.StatusSeeOther, "/preferences/"+c.Param("token"))
+}
+
+// GetDataExport lets subscribers download everything stored about their
+// email address through the link sent by PostDataExport
+func GetDataExport(c *gin.Context, dh *handlers.DatabaseHandler) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	subscriberID, email, e := privacy.GetDataExport(c, dh.DB, c.Param("token"))
+	if e != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(e, privacy.ErrDataExportNotFound) {
+			status = http.StatusNotFound
+		}
+
+		response = "Failed to export data"
+		handlers.HandleError(c, requestID, e, response, status)
+		return
+	}
+
+	archive, e := privacy.ExportData(c, dh.DB, email)
+	if e != nil {
+		response = "Failed to export data"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Actor:        models.AuditActorSubscriber,
+		Action:       models.AuditActionExport,
+		SubscriberID: subscriberID,
+	}
+	if e := audit.Record(c, dh.DB, entry); e != nil {
+		response = "Failed to record audit entry"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Str("requestID", requestID).
+		Str("id", subscriberID).
+		Msg("Subscriber data exported")
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archive.Filename()))
+	c.JSON(http.StatusOK, archive)
+}
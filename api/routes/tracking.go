@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	"github.com/solomonbaez/hyacinth/api/tracking"
+)
+
+// transparent 1x1 GIF
+var trackingPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// headers browsers and mail clients send with speculative requests
+var prefetchHeaders = []string{"Purpose", "Sec-Purpose", "X-Purpose", "X-Moz"}
+
+// GetOpenPixel records an open and serves the pixel, the pixel is served
+// even when nothing is recorded so mail clients never show a broken image
+func GetOpenPixel(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer) {
+	requestID := c.GetString("requestID")
+
+	deliveryID, e := tracking.ParseOpenToken(signer, c.Param("token"))
+	if e != nil {
+		response := "Invalid tracking link"
+		handlers.HandleError(c, requestID, e, response, http.StatusNotFound)
+		return
+	}
+
+	recordEngagement(c, dh, deliveryID, models.EngagementOpen, nil)
+
+	c.Header("Cache-Control", "no-store, max-age=0")
+	c.Data(http.StatusOK, "image/gif", trackingPixel)
+}
+
+// GetClickRedirect records a click and redirects to the signed target
+func GetClickRedirect(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer) {
+	requestID := c.GetString("requestID")
+
+	deliveryID, target, e := tracking.ParseClickToken(signer, c.Param("token"))
+	if e != nil {
+		response := "Invalid tracking link"
+		handlers.HandleError(c, requestID, e, response, http.StatusNotFound)
+		return
+	}
+
+	recordEngagement(c, dh, deliveryID, models.EngagementClick, &target)
+
+	c.Header("Cache-Control", "no-store, max-age=0")
+	c.Redirect(http.StatusFound, target)
+}
+
+// failures are logged rather than surfaced, the reader still gets the pixel
+// or the link they clicked
+func recordEngagement(c *gin.Context, dh *handlers.DatabaseHandler, deliveryID string, eventType string, target *string) {
+	requestID := c.GetString("requestID")
+
+	if isAutomatedRequest(c) {
+		log.Debug().
+			Str("requestID", requestID).
+			Str("userAgent", c.Request.UserAgent()).
+			Msg("Skipped automated engagement")
+
+		return
+	}
+
+	if _, e := tracking.RecordEvent(c, dh.DB, deliveryID, eventType, target); e != nil {
+		log.Error().
+			Str("requestID", requestID).
+			Err(e).
+			Msg("Failed to record engagement")
+	}
+}
+
+func isAutomatedRequest(c *gin.Context) bool {
+	for _, header := range prefetchHeaders {
+		if strings.Contains(strings.ToLower(c.GetHeader(header)), "prefetch") {
+			return true
+		}
+	}
+
+	return models.IsAutomatedAgent(c.Request.UserAgent())
+}
@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/bounces"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	"github.com/solomonbaez/hyacinth/api/webhooks"
+)
+
+const (
+	SignatureHeader  = "X-Hyacinth-Signature"
+	maxMailEventBody = 1 << 20
+)
+
+// PostMailEvents applies bounces and complaints reported by a mail provider,
+// the body must be signed with the shared webhook secret. JSON bodies carry
+// one or more events, message/rfc822 bodies an ARF abuse report.
+func PostMailEvents(c *gin.Context, dh *handlers.DatabaseHandler, signer *signing.Signer, settings *configs.BounceSettings, verp *bounces.VERP) {
+	requestID := c.GetString("requestID")
+
+	var response string
+	body, e := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxMailEventBody))
+	if e != nil {
+		response = "Failed to read events"
+		handlers.HandleError(c, requestID, e, response, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	signature := strings.TrimPrefix(c.GetHeader(SignatureHeader), "sha256=")
+	if !signer.VerifyDigest(body, signature) {
+		response = "Invalid signature"
+		handlers.HandleError(c, requestID, signing.ErrInvalidToken, response, http.StatusUnauthorized)
+		return
+	}
+
+	var events []*models.MailEvent
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	switch mediaType {
+	case "application/json":
+		events, e = webhooks.ParseEvents(body)
+	case "message/rfc822":
+		var event *models.MailEvent
+		event, e = webhooks.ParseARF(bytes.NewReader(body), verp)
+		if errors.Is(e, webhooks.ErrNotComplaint) {
+			c.JSON(http.StatusOK, gin.H{"requestID": requestID, "processed": 0, "skipped": 1})
+			return
+		}
+		events = []*models.MailEvent{event}
+	default:
+		response = "Unsupported content type"
+		handlers.HandleError(c, requestID, errors.New(c.ContentType()), response, http.StatusUnsupportedMediaType)
+		return
+	}
+	if e != nil {
+		response = "Invalid events"
+		handlers.HandleError(c, requestID, e, response, http.StatusBadRequest)
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		response = "Failed to begin transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	// replayed and unattributable events are skipped, a failure rolls back the
+	// whole batch so the provider's retry applies it again
+	var processed, skipped int
+	for _, event := range events {
+		ok, e := webhooks.ProcessEvent(c, tx, event, settings)
+		if e != nil && !errors.Is(e, bounces.ErrUnattributed) {
+			response = "Failed to process events"
+			handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			skipped++
+			continue
+		}
+
+		processed++
+		log.Info().
+			Str("requestID", requestID).
+			Str("event", event.ID).
+			Str("type", event.Type).
+			Msg("Mail event processed")
+	}
+
+	if e := tx.Commit(c); e != nil {
+		response = "Failed to commit transaction"
+		handlers.HandleError(c, requestID, e, response, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requestID": requestID, "processed": processed, "skipped": skipped})
+}
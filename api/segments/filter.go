@@ -0,0 +1,178 @@
+package segments
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+)
+
+// RecipientFilter builds the WHERE clause selecting the confirmed, unpaused
+// and unsuppressed subscribers of a list matched by any of the given
+// segments, appending its query arguments to args. An issue without segments
+// targets every confirmed subscriber of its list.
+func RecipientFilter(listID string, segments []*models.Segment, args []interface{}) (filter string, arguments []interface{}, err error) {
+	arguments = append(args, listID)
+	filter = fmt.Sprintf("subscriptions.list_id = $%d AND subscriptions.status = 'confirmed'", len(arguments))
+	filter += " AND (subscriptions.paused_until IS NULL OR subscriptions.paused_until <= now())"
+	filter += " AND NOT " + suppressions.Matches("subscriptions.email")
+
+	var segmentFilters []string
+	for _, segment := range segments {
+		var segmentFilter string
+		segmentFilter, arguments, err = BuildFilter(segment, arguments)
+		if err != nil {
+			return
+		}
+
+		segmentFilters = append(segmentFilters, segmentFilter)
+	}
+
+	if len(segmentFilters) > 0 {
+		filter += " AND (" + strings.Join(segmentFilters, " OR ") + ")"
+	}
+
+	return
+}
+
+func BuildFilter(segment *models.Segment, args []interface{}) (filter string, arguments []interface{}, err error) {
+	arguments = args
+
+	var ruleFilters []string
+	for _, rule := range segment.Rules {
+		var ruleFilter string
+		ruleFilter, arguments, err = buildRuleFilter(rule, arguments)
+		if err != nil {
+			err = fmt.Errorf("invalid segment %s: %w", segment.Name, err)
+			return
+		}
+
+		ruleFilters = append(ruleFilters, ruleFilter)
+	}
+
+	if len(ruleFilters) == 0 {
+		err = fmt.Errorf("segment %s has no rules", segment.Name)
+		return
+	}
+
+	join := " AND "
+	if segment.Match == models.SegmentMatchAny {
+		join = " OR "
+	}
+
+	filter = "(" + strings.Join(ruleFilters, join) + ")"
+	return
+}
+
+// values are always bound as arguments, never interpolated
+func buildRuleFilter(rule *models.SegmentRule, args []interface{}) (filter string, arguments []interface{}, err error) {
+	if e := models.ParseSegmentRule(rule); e != nil {
+		err = e
+		return
+	}
+
+	switch rule.Field {
+	case models.SegmentFieldAttribute:
+		return buildAttributeFilter(rule, args)
+	case models.SegmentFieldEngagement:
+		return buildEngagementFilter(rule, args)
+	}
+
+	arguments = append(args, rule.Value)
+	placeholder := fmt.Sprintf("$%d", len(arguments))
+
+	switch rule.Field {
+	case models.SegmentFieldTag:
+		filter = `EXISTS (SELECT 1 FROM subscriber_tags
+				WHERE subscriber_tags.subscriber_id = subscriptions.id
+				AND subscriber_tags.tag = ` + placeholder + `)`
+		if rule.Operator == models.SegmentOperatorNotHas {
+			filter = "NOT " + filter
+		}
+	case models.SegmentFieldCreated:
+		if rule.Operator == models.SegmentOperatorBefore {
+			filter = "subscriptions.created < " + placeholder + "::timestamptz"
+		} else {
+			filter = "subscriptions.created >= " + placeholder + "::timestamptz"
+		}
+	}
+
+	return
+}
+
+// engagement is an open or click by any form of the address on the
+// subscription's list, issues count only while they track opens or clicks
+func buildEngagementFilter(rule *models.SegmentRule, args []interface{}) (filter string, arguments []interface{}, err error) {
+	arguments = append(args, rule.Value)
+	placeholder := fmt.Sprintf("$%d::int", len(arguments))
+
+	filter = `EXISTS (SELECT 1 FROM engagement_events
+				WHERE engagement_events.list_id = subscriptions.list_id
+				AND normalize_email(engagement_events.subscriber_email) = normalize_email(subscriptions.email)
+				AND `
+	switch rule.Operator {
+	case models.SegmentOperatorWithinDays, models.SegmentOperatorNotWithinDays:
+		filter += "engagement_events.created > now() - make_interval(days => " + placeholder + "))"
+	case models.SegmentOperatorInLastIssues, models.SegmentOperatorNotInLastIssues:
+		filter += `engagement_events.delivery_id IN (
+					SELECT delivery_log.delivery_id
+					FROM delivery_log
+					JOIN newsletter_issues ON newsletter_issues.newsletter_issue_id = delivery_log.newsletter_issue_id
+					WHERE delivery_log.list_id = subscriptions.list_id
+					AND normalize_email(delivery_log.subscriber_email) = normalize_email(subscriptions.email)
+					AND (newsletter_issues.track_opens OR newsletter_issues.track_clicks)
+					ORDER BY delivery_log.sent DESC
+					LIMIT ` + placeholder + `
+				))`
+	default:
+		err = fmt.Errorf("invalid operator for %s: %s", rule.Field, rule.Operator)
+		return
+	}
+
+	if rule.Operator == models.SegmentOperatorNotWithinDays || rule.Operator == models.SegmentOperatorNotInLastIssues {
+		filter = "NOT " + filter
+	}
+
+	return
+}
+
+// attribute values are only cast when stored with a matching type, so a
+// comparison never fails on another subscriber's value
+func buildAttributeFilter(rule *models.SegmentRule, args []interface{}) (filter string, arguments []interface{}, err error) {
+	arguments = append(args, rule.Key)
+	key := fmt.Sprintf("$%d::text", len(arguments))
+	value := "subscriptions.attributes->>" + key
+	typeOf := "jsonb_typeof(subscriptions.attributes->" + key + ")"
+
+	switch rule.Operator {
+	case models.SegmentOperatorIsSet:
+		filter = "subscriptions.attributes ? " + key
+		return
+	case models.SegmentOperatorIsNotSet:
+		filter = "NOT subscriptions.attributes ? " + key
+		return
+	}
+
+	arguments = append(arguments, rule.Value)
+	placeholder := fmt.Sprintf("$%d", len(arguments))
+
+	switch rule.Operator {
+	case models.SegmentOperatorEquals:
+		filter = value + " = " + placeholder
+	case models.SegmentOperatorNotEquals:
+		filter = value + " IS DISTINCT FROM " + placeholder
+	case models.SegmentOperatorGreaterThan:
+		filter = "CASE WHEN " + typeOf + " = 'number' THEN (" + value + ")::numeric > " + placeholder + "::numeric ELSE false END"
+	case models.SegmentOperatorLessThan:
+		filter = "CASE WHEN " + typeOf + " = 'number' THEN (" + value + ")::numeric < " + placeholder + "::numeric ELSE false END"
+	case models.SegmentOperatorBefore:
+		filter = "CASE WHEN " + value + ` ~ '^\d{4}-\d{2}-\d{2}$' THEN (` + value + ")::date < " + placeholder + "::date ELSE false END"
+	case models.SegmentOperatorAfter:
+		filter = "CASE WHEN " + value + ` ~ '^\d{4}-\d{2}-\d{2}$' THEN (` + value + ")::date >= " + placeholder + "::date ELSE false END"
+	default:
+		err = fmt.Errorf("invalid operator for %s: %s", rule.Field, rule.Operator)
+	}
+
+	return
+}
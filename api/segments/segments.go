@@ -0,0 +1,89 @@
+package segments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+func GetSegments(c context.Context, db handlers.DatabaseInterface, ids []string) (segments []*models.Segment, err error) {
+	query := `SELECT segment_id, name, match, rules
+			FROM segments
+			WHERE segment_id = ANY($1)
+			ORDER BY name`
+	rows, e := db.Query(c, query, ids)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch segments: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	segments, e = pgx.CollectRows[*models.Segment](rows, BuildSegment)
+	if e != nil {
+		err = fmt.Errorf("failed to parse segments: %w", e)
+		return
+	}
+
+	if len(segments) != len(ids) {
+		err = errors.New("segment not found")
+		return
+	}
+
+	return
+}
+
+func InsertSegment(c context.Context, db handlers.DatabaseInterface, segment *models.Segment) (err error) {
+	segment.ID = uuid.NewString()
+
+	query := "INSERT INTO segments (segment_id, name, match, rules, created) VALUES ($1, $2, $3, $4, now())"
+	_, e := db.Exec(c, query, segment.ID, segment.Name, segment.Match, segment.Rules)
+	if e != nil {
+		err = fmt.Errorf("failed to insert segment: %w", e)
+		return
+	}
+
+	return
+}
+
+func InsertIssueSegments(c context.Context, tx pgx.Tx, issueID string, segments []*models.Segment) (err error) {
+	query := "INSERT INTO newsletter_issue_segments (newsletter_issue_id, segment_id) VALUES ($1, $2)"
+	for _, segment := range segments {
+		if _, e := tx.Exec(c, query, issueID, segment.ID); e != nil {
+			err = fmt.Errorf("failed to insert issue segment %s: %w", segment.Name, e)
+			return
+		}
+	}
+
+	return
+}
+
+func CountRecipients(c context.Context, db handlers.DatabaseInterface, listID string, segments []*models.Segment) (count int, err error) {
+	filter, args, e := RecipientFilter(listID, segments, nil)
+	if e != nil {
+		err = fmt.Errorf("failed to build recipient filter: %w", e)
+		return
+	}
+
+	query := "SELECT count(*) FROM subscriptions WHERE " + filter
+	if e := db.QueryRow(c, query, args...).Scan(&count); e != nil {
+		err = fmt.Errorf("failed to count recipients: %w", e)
+		return
+	}
+
+	return
+}
+
+func BuildSegment(row pgx.CollectableRow) (segment *models.Segment, err error) {
+	segment = &models.Segment{}
+	if e := row.Scan(&segment.ID, &segment.Name, &segment.Match, &segment.Rules); e != nil {
+		err = fmt.Errorf("database error: %w", e)
+		return
+	}
+
+	return
+}
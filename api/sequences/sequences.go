@@ -0,0 +1,153 @@
+package sequences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+)
+
+var ErrSequenceNotFound = errors.New("sequence not found")
+
+// InsertSequence stores the sequence with an issue per step, subscribers
+// confirming from now on receive it
+func InsertSequence(c context.Context, tx pgx.Tx, sequence *models.Sequence) (err error) {
+	sequence.ID = uuid.NewString()
+
+	query := "INSERT INTO sequences (sequence_id, list_id, name, created) VALUES ($1, $2, $3, now())"
+	if _, e := tx.Exec(c, query, sequence.ID, sequence.ListID, sequence.Name); e != nil {
+		err = fmt.Errorf("failed to insert sequence: %w", e)
+		return
+	}
+
+	for _, step := range sequence.Steps {
+		step.IssueID = uuid.NewString()
+		query = `INSERT INTO newsletter_issues (
+					newsletter_issue_id,
+					title,
+					text_content,
+					html_content,
+					published_at
+				)
+				VALUES ($1, $2, $3, $4, now())`
+		_, e := tx.Exec(c, query, step.IssueID, step.Content.Title, step.Content.Text, step.Content.Html)
+		if e != nil {
+			err = fmt.Errorf("failed to insert step %d issue: %w", step.Position, e)
+			return
+		}
+
+		query = "INSERT INTO sequence_steps (sequence_id, position, newsletter_issue_id, delay_hours) VALUES ($1, $2, $3, $4)"
+		if _, e := tx.Exec(c, query, sequence.ID, step.Position, step.IssueID, step.DelayHours); e != nil {
+			err = fmt.Errorf("failed to insert step %d: %w", step.Position, e)
+			return
+		}
+	}
+
+	return
+}
+
+// GetSequences returns every sequence with its steps, step content is left
+// out
+func GetSequences(c context.Context, db handlers.DatabaseInterface) (sequences []*models.Sequence, err error) {
+	query := `SELECT sequences.sequence_id, sequences.list_id, sequences.name, sequences.created,
+				sequence_steps.position, sequence_steps.newsletter_issue_id, sequence_steps.delay_hours
+			FROM sequences
+			JOIN sequence_steps ON sequence_steps.sequence_id = sequences.sequence_id
+			ORDER BY sequences.name, sequences.sequence_id, sequence_steps.position`
+	rows, e := db.Query(c, query)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch sequences: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	sequences = []*models.Sequence{}
+	var current *models.Sequence
+	for rows.Next() {
+		var sequence models.Sequence
+		var created time.Time
+		step := &models.SequenceStep{}
+		e := rows.Scan(&sequence.ID, &sequence.ListID, &sequence.Name, &created, &step.Position, &step.IssueID, &step.DelayHours)
+		if e != nil {
+			err = fmt.Errorf("failed to scan sequence: %w", e)
+			return
+		}
+
+		if current == nil || current.ID != sequence.ID {
+			sequence.Created = &created
+			current = &sequence
+			sequences = append(sequences, current)
+		}
+		current.Steps = append(current.Steps, step)
+	}
+	if e := rows.Err(); e != nil {
+		err = fmt.Errorf("failed to parse sequences: %w", e)
+		return
+	}
+
+	return
+}
+
+// DeleteSequence stops the sequence, steps already queued are dropped by
+// cascade while delivered step issues are kept for the delivery log
+func DeleteSequence(c context.Context, db handlers.DatabaseInterface, id string) (err error) {
+	tag, e := db.Exec(c, "DELETE FROM sequences WHERE sequence_id = $1", id)
+	if e != nil {
+		err = fmt.Errorf("failed to delete sequence: %w", e)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		err = ErrSequenceNotFound
+		return
+	}
+
+	return
+}
+
+// EnqueSequences schedules every step of the list's sequences for newly
+// confirmed subscribers, relative to now. It is called wherever a
+// subscription becomes confirmed. Suppressed addresses are skipped.
+func EnqueSequences(c context.Context, tx pgx.Tx, subscriberIDs ...string) (err error) {
+	if len(subscriberIDs) == 0 {
+		return
+	}
+
+	query := `INSERT INTO issue_delivery_queue (
+				newsletter_issue_id,
+				list_id,
+				subscriber_email,
+				execute_after,
+				sequence_id
+			)
+			SELECT sequence_steps.newsletter_issue_id, subscriptions.list_id, subscriptions.email,
+				now() + make_interval(hours => sequence_steps.delay_hours), sequences.sequence_id
+			FROM subscriptions
+			JOIN sequences ON sequences.list_id = subscriptions.list_id
+			JOIN sequence_steps ON sequence_steps.sequence_id = sequences.sequence_id
+			WHERE subscriptions.id = ANY($1) AND NOT ` + suppressions.Matches("subscriptions.email") + `
+			ON CONFLICT DO NOTHING`
+	if _, e := tx.Exec(c, query, subscriberIDs); e != nil {
+		err = fmt.Errorf("failed to enque sequence steps: %w", e)
+		return
+	}
+
+	return
+}
+
+// IsSubscribed reports whether the address is still confirmed on the list,
+// checked before each step is delivered
+func IsSubscribed(c context.Context, tx pgx.Tx, listID string, email models.SubscriberEmail) (subscribed bool, err error) {
+	query := "SELECT EXISTS (SELECT 1 FROM subscriptions WHERE list_id = $1 AND normalized_email = normalize_email($2) AND status = 'confirmed')"
+	if e := tx.QueryRow(c, query, listID, email.String()).Scan(&subscribed); e != nil {
+		err = fmt.Errorf("failed to check subscription: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,140 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tagLength = 8
+
+// purposes are bound into every signature so a token issued for one use can
+// never be replayed against another
+const (
+	PurposePreferences = "prefs"
+	PurposeOpen        = "open"
+	PurposeClick       = "click"
+	PurposeVERP        = "verp"
+)
+
+// tokens for these purposes are signed with SignExpiring, once an expiry is
+// configured their tokens without one count as expired
+var expiringPurposes = map[string]bool{
+	PurposePreferences: true,
+}
+
+var (
+	ErrInvalidToken = errors.New("invalid or tampered token")
+	ErrExpiredToken = errors.New("expired token")
+)
+
+// Signer issues tamper-proof tokens for links sent to subscribers, the
+// payload is readable so it must never carry secrets
+type Signer struct {
+	key []byte
+	// lifetime of tokens from SignExpiring, zero keeps them valid forever
+	expiry time.Duration
+}
+
+func NewSigner(secret string, expiry time.Duration) *Signer {
+	return &Signer{key: []byte(secret), expiry: expiry}
+}
+
+func (signer *Signer) Sign(purpose string, payload string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signer.mac(purpose+":"+encoded))
+}
+
+// SignExpiring signs tokens for powerful uses, they stop verifying once the
+// signer's expiry has passed
+func (signer *Signer) SignExpiring(purpose string, payload string) string {
+	if signer.expiry <= 0 {
+		return signer.Sign(purpose, payload)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	signed := encoded + "." + strconv.FormatInt(time.Now().Add(signer.expiry).Unix(), 10)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signer.mac(purpose+":"+signed))
+}
+
+// Verify checks token was signed for purpose. An expired token still returns
+// its payload along with ErrExpiredToken, so callers may allow limited uses.
+// Tokens issued before an expiry was configured are expired as well.
+func (signer *Signer) Verify(purpose string, token string) (payload string, err error) {
+	cut := strings.LastIndex(token, ".")
+	if cut < 0 {
+		err = ErrInvalidToken
+		return
+	}
+	signed, signature := token[:cut], token[cut+1:]
+
+	mac, e := base64.RawURLEncoding.DecodeString(signature)
+	if e != nil || !hmac.Equal(mac, signer.mac(purpose+":"+signed)) {
+		err = ErrInvalidToken
+		return
+	}
+
+	encoded, expiry, expiring := strings.Cut(signed, ".")
+	decoded, e := base64.RawURLEncoding.DecodeString(encoded)
+	if e != nil {
+		err = ErrInvalidToken
+		return
+	}
+	payload = string(decoded)
+
+	if !expiring {
+		if signer.expiry > 0 && expiringPurposes[purpose] {
+			err = ErrExpiredToken
+		}
+		return
+	}
+
+	expires, e := strconv.ParseInt(expiry, 10, 64)
+	if e != nil {
+		err = ErrInvalidToken
+		return
+	}
+	if time.Now().Unix() >= expires {
+		err = ErrExpiredToken
+		return
+	}
+
+	return
+}
+
+// Tag is a short hex signature for payloads that must survive case folding,
+// e.g. email local parts
+func (signer *Signer) Tag(purpose string, payload string) string {
+	return hex.EncodeToString(signer.mac(purpose + ":" + payload)[:tagLength])
+}
+
+func (signer *Signer) VerifyTag(purpose string, payload string, tag string) bool {
+	mac, e := hex.DecodeString(strings.ToLower(tag))
+
+	return e == nil && hmac.Equal(mac, signer.mac(purpose + ":" + payload)[:tagLength])
+}
+
+// Digest is the full hex signature of a raw payload, e.g. a webhook body
+func (signer *Signer) Digest(payload []byte) string {
+	return hex.EncodeToString(signer.mac(string(payload)))
+}
+
+func (signer *Signer) VerifyDigest(payload []byte, digest string) bool {
+	mac, e := hex.DecodeString(strings.ToLower(digest))
+
+	return e == nil && hmac.Equal(mac, signer.mac(string(payload)))
+}
+
+func (signer *Signer) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, signer.key)
+	h.Write([]byte(encoded))
+
+	return h.Sum(nil)
+}
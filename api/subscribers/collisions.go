@@ -0,0 +1,67 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+// GetEmailCollisions returns the subscriptions sharing a normalized email
+// with an older subscription, recorded when normalization changed. Deleting
+// either side resolves a collision. With providerRules subscriptions only
+// reaching the same mailbox under provider rules are reported as well.
+func GetEmailCollisions(c context.Context, db handlers.DatabaseInterface, providerRules bool) (collisions []*models.EmailCollision, err error) {
+	query := `SELECT 'normalized', duplicate.id, duplicate.email, original.id, original.email, duplicate.list_id, email_collisions.detected
+			FROM email_collisions
+			JOIN subscriptions AS duplicate ON duplicate.id = email_collisions.subscriber_id
+			JOIN subscriptions AS original ON original.id = email_collisions.duplicate_of
+			UNION ALL
+			SELECT 'provider', id, email, first_id, first_email, list_id, created
+			FROM (
+				SELECT id, email, list_id, created, normalized_email,
+					first_value(id) OVER mailbox AS first_id,
+					first_value(email) OVER mailbox AS first_email,
+					first_value(normalized_email) OVER mailbox AS first_normalized
+				FROM subscriptions
+				WHERE $1::boolean AND normalized_email IS NOT NULL
+				WINDOW mailbox AS (PARTITION BY list_id, provider_email(email) ORDER BY created, id)
+			) AS mailboxes
+			WHERE id <> first_id AND normalized_email <> first_normalized
+			ORDER BY 5, 3`
+	rows, e := db.Query(c, query, providerRules)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch email collisions: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	collisions, e = pgx.CollectRows[*models.EmailCollision](rows, buildEmailCollision)
+	if e != nil {
+		err = fmt.Errorf("failed to parse email collisions: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildEmailCollision(row pgx.CollectableRow) (collision *models.EmailCollision, err error) {
+	collision = &models.EmailCollision{}
+	e := row.Scan(
+		&collision.Rule,
+		&collision.SubscriberID,
+		&collision.Email,
+		&collision.DuplicateOfID,
+		&collision.DuplicateOfEmail,
+		&collision.ListID,
+		&collision.Detected,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to scan email collision: %w", e)
+		return
+	}
+
+	return
+}
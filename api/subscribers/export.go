@@ -0,0 +1,91 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const exportBatchSize = 1000
+
+// ExportSubscribers streams matching subscribers to write through a server
+// side cursor so only a single batch is held in memory. The cursor lives
+// for the duration of tx.
+func ExportSubscribers(c context.Context, tx pgx.Tx, filter *models.SubscriberFilter, write func(*models.ExportedSubscriber) error) (err error) {
+	clause, args := BuildFilter(filter, []interface{}{})
+	query := `DECLARE subscriber_export NO SCROLL CURSOR FOR
+			SELECT
+				subscriptions.id,
+				subscriptions.email,
+				subscriptions.name,
+				subscriptions.status,
+				lists.slug,
+				subscriptions.created,
+				ARRAY(SELECT tag FROM subscriber_tags WHERE subscriber_tags.subscriber_id = subscriptions.id ORDER BY tag),
+				subscriptions.attributes,
+				subscriptions.consent_source
+			FROM subscriptions
+			JOIN lists ON lists.list_id = subscriptions.list_id
+			WHERE ` + clause + `
+			ORDER BY subscriptions.created, subscriptions.id`
+	if _, e := tx.Exec(c, query, args...); e != nil {
+		err = fmt.Errorf("failed to declare export cursor: %w", e)
+		return
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM subscriber_export", exportBatchSize)
+	for {
+		rows, e := tx.Query(c, fetch)
+		if e != nil {
+			err = fmt.Errorf("failed to fetch subscribers: %w", e)
+			return
+		}
+
+		batch, e := pgx.CollectRows[*models.ExportedSubscriber](rows, BuildExportedSubscriber)
+		if e != nil {
+			err = fmt.Errorf("failed to parse subscribers: %w", e)
+			return
+		}
+
+		for _, subscriber := range batch {
+			if e := write(subscriber); e != nil {
+				err = fmt.Errorf("failed to write subscriber: %w", e)
+				return
+			}
+		}
+
+		if len(batch) < exportBatchSize {
+			break
+		}
+	}
+
+	if _, e := tx.Exec(c, "CLOSE subscriber_export"); e != nil {
+		err = fmt.Errorf("failed to close export cursor: %w", e)
+		return
+	}
+
+	return
+}
+
+func BuildExportedSubscriber(row pgx.CollectableRow) (subscriber *models.ExportedSubscriber, err error) {
+	subscriber = &models.ExportedSubscriber{}
+	e := row.Scan(
+		&subscriber.ID,
+		&subscriber.Email,
+		&subscriber.Name,
+		&subscriber.Status,
+		&subscriber.List,
+		&subscriber.Created,
+		&subscriber.Tags,
+		&subscriber.Attributes,
+		&subscriber.ConsentSource,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to scan subscriber: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,44 @@
+package subscribers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// BuildFilter renders the WHERE clause for filter, appending its query
+// arguments to args
+func BuildFilter(filter *models.SubscriberFilter, args []interface{}) (clause string, arguments []interface{}) {
+	arguments = args
+	conditions := []string{"true"}
+
+	add := func(condition string, value interface{}) {
+		arguments = append(arguments, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(arguments)))
+	}
+
+	if filter.Search != "" {
+		add("(subscriptions.email ILIKE $%[1]d OR subscriptions.name ILIKE $%[1]d)", "%"+likeEscaper.Replace(filter.Search)+"%")
+	}
+	if filter.Status != "" {
+		add("subscriptions.status = $%d", filter.Status)
+	}
+	if filter.ListID != "" {
+		add("subscriptions.list_id = $%d", filter.ListID)
+	}
+	if filter.Tag != "" {
+		add("EXISTS (SELECT 1 FROM subscriber_tags WHERE subscriber_tags.subscriber_id = subscriptions.id AND subscriber_tags.tag = $%d)", filter.Tag.String())
+	}
+	if filter.CreatedAfter != nil {
+		add("subscriptions.created >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		add("subscriptions.created < $%d", *filter.CreatedBefore)
+	}
+
+	clause = strings.Join(conditions, " AND ")
+	return
+}
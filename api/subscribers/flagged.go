@@ -0,0 +1,52 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+// GetFlaggedSubscribers returns subscriptions accepted under a list's flag
+// rule for disposable or role account addresses, newest first
+func GetFlaggedSubscribers(c context.Context, db handlers.DatabaseInterface) (flagged []*models.FlaggedSubscriber, err error) {
+	query := `SELECT id, email, name, status, list_id, flag, created
+			FROM subscriptions
+			WHERE flag IS NOT NULL
+			ORDER BY created DESC`
+	rows, e := db.Query(c, query)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch flagged subscribers: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	flagged, e = pgx.CollectRows[*models.FlaggedSubscriber](rows, buildFlaggedSubscriber)
+	if e != nil {
+		err = fmt.Errorf("failed to parse flagged subscribers: %w", e)
+		return
+	}
+
+	return
+}
+
+func buildFlaggedSubscriber(row pgx.CollectableRow) (subscriber *models.FlaggedSubscriber, err error) {
+	subscriber = &models.FlaggedSubscriber{}
+	e := row.Scan(
+		&subscriber.ID,
+		&subscriber.Email,
+		&subscriber.Name,
+		&subscriber.Status,
+		&subscriber.ListID,
+		&subscriber.Flag,
+		&subscriber.Created,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to scan flagged subscriber: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,89 @@
+package subscribers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+)
+
+var ErrSubscriberNotFound = errors.New("subscriber not found")
+
+func GetSubscriber(c context.Context, db handlers.DatabaseInterface, id string) (subscriber *models.Subscriber, err error) {
+	var created time.Time
+	subscriber = &models.Subscriber{}
+	query := "SELECT id, email, name, status, list_id, created FROM subscriptions WHERE id = $1"
+	e := db.QueryRow(c, query, id).
+		Scan(&subscriber.ID, &subscriber.Email, &subscriber.Name, &subscriber.Status, &subscriber.ListID, &created)
+	if e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrSubscriberNotFound
+			return
+		}
+
+		err = fmt.Errorf("failed to fetch subscriber: %w", e)
+		return
+	}
+	subscriber.Created = &created
+
+	return
+}
+
+func UpdateSubscriber(c context.Context, tx pgx.Tx, subscriber *models.Subscriber) (err error) {
+	query := "UPDATE subscriptions SET name = $2, status = $3 WHERE id = $1"
+	if _, e := tx.Exec(c, query, subscriber.ID, subscriber.Name.String(), subscriber.Status); e != nil {
+		err = fmt.Errorf("failed to update subscriber: %w", e)
+		return
+	}
+
+	return
+}
+
+// DeleteSubscriber removes the subscription along with its token and any
+// pending deliveries, tags are removed by cascade
+func DeleteSubscriber(c context.Context, tx pgx.Tx, subscriber *models.Subscriber) (err error) {
+	if _, e := tx.Exec(c, "DELETE FROM subscription_tokens WHERE subscriber_id = $1", subscriber.ID); e != nil {
+		err = fmt.Errorf("failed to delete subscription token: %w", e)
+		return
+	}
+
+	query := "DELETE FROM issue_delivery_queue WHERE list_id = $1 AND subscriber_email = $2"
+	if _, e := tx.Exec(c, query, subscriber.ListID, subscriber.Email.String()); e != nil {
+		err = fmt.Errorf("failed to delete delivery tasks: %w", e)
+		return
+	}
+
+	if _, e := tx.Exec(c, "DELETE FROM subscriptions WHERE id = $1", subscriber.ID); e != nil {
+		err = fmt.Errorf("failed to delete subscriber: %w", e)
+		return
+	}
+
+	return
+}
+
+// Suppress blocks the address on every list and drops its pending deliveries
+func Suppress(c context.Context, tx pgx.Tx, email models.SubscriberEmail, suppression *models.Suppression) (err error) {
+	if _, e := suppressions.Insert(c, tx, suppression); e != nil {
+		err = e
+		return
+	}
+
+	query := "UPDATE subscriptions SET status = 'suppressed' WHERE normalized_email = normalize_email($1)"
+	if _, e := tx.Exec(c, query, email.String()); e != nil {
+		err = fmt.Errorf("failed to suppress subscriptions: %w", e)
+		return
+	}
+
+	query = "DELETE FROM issue_delivery_queue WHERE normalize_email(subscriber_email) = normalize_email($1)"
+	if _, e := tx.Exec(c, query, email.String()); e != nil {
+		err = fmt.Errorf("failed to delete delivery tasks: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,100 @@
+package subscribers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+var sortColumns = map[string]string{
+	models.SortCreated: "subscriptions.created",
+	models.SortEmail:   "subscriptions.email",
+	models.SortName:    "subscriptions.name",
+}
+
+var sortTypes = map[string]string{
+	models.SortCreated: "timestamptz",
+	models.SortEmail:   "text",
+	models.SortName:    "text",
+}
+
+// GetSubscriberPage returns one page of filtered subscribers along with the
+// total number of matches, next is empty on the last page
+func GetSubscriberPage(c context.Context, db handlers.DatabaseInterface, filter *models.SubscriberFilter, page *models.Page) (subscriberArray []*models.Subscriber, total int, next string, err error) {
+	clause, args := BuildFilter(filter, []interface{}{})
+
+	query := "SELECT count(*) FROM subscriptions WHERE " + clause
+	if e := db.QueryRow(c, query, args...).Scan(&total); e != nil {
+		err = fmt.Errorf("failed to count subscribers: %w", e)
+		return
+	}
+
+	column, direction, comparison := sortColumns[page.Sort], "ASC", ">"
+	if page.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if page.After != nil {
+		args = append(args, page.After.Value, page.After.ID)
+		clause += fmt.Sprintf(" AND (%s, subscriptions.id) %s ($%d::%s, $%d::uuid)", column, comparison, len(args)-1, sortTypes[page.Sort], len(args))
+	}
+
+	// one extra row tells whether another page follows
+	args = append(args, page.Limit+1)
+	query = fmt.Sprintf(`SELECT id, email, name, created, status
+			FROM subscriptions
+			WHERE %s
+			ORDER BY %s %s, subscriptions.id %s
+			LIMIT $%d`, clause, column, direction, direction, len(args))
+	rows, e := db.Query(c, query, args...)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch subscribers: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	subscriberArray = []*models.Subscriber{}
+	for rows.Next() {
+		var created time.Time
+		subscriber := &models.Subscriber{}
+		if e := rows.Scan(&subscriber.ID, &subscriber.Email, &subscriber.Name, &created, &subscriber.Status); e != nil {
+			err = fmt.Errorf("failed to parse subscribers: %w", e)
+			return
+		}
+		subscriber.Created = &created
+
+		subscriberArray = append(subscriberArray, subscriber)
+	}
+	if e := rows.Err(); e != nil {
+		err = fmt.Errorf("failed to fetch subscribers: %w", e)
+		return
+	}
+
+	if len(subscriberArray) > page.Limit {
+		subscriberArray = subscriberArray[:page.Limit]
+		next = nextCursor(subscriberArray[len(subscriberArray)-1], page).Encode()
+	}
+
+	return
+}
+
+func nextCursor(last *models.Subscriber, page *models.Page) *models.PageCursor {
+	cursor := &models.PageCursor{Sort: page.Sort, ID: last.ID}
+	if page.Descending {
+		cursor.Sort = "-" + page.Sort
+	}
+
+	switch page.Sort {
+	case models.SortEmail:
+		cursor.Value = last.Email.String()
+	case models.SortName:
+		cursor.Value = last.Name.String()
+	default:
+		cursor.Value = last.Created.Format(time.RFC3339Nano)
+	}
+
+	return cursor
+}
@@ -0,0 +1,269 @@
+package sunset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/solomonbaez/hyacinth/api/audit"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+)
+
+const sunsetReason = "inactive"
+
+var ErrSubscriberNotFound = errors.New("subscriber not found")
+
+// recentDeliveries selects the subscription's last tracked deliveries, only
+// issues recording opens or clicks can show engagement
+func recentDeliveries(limit string) string {
+	return `SELECT delivery_log.delivery_id
+			FROM delivery_log
+			JOIN newsletter_issues ON newsletter_issues.newsletter_issue_id = delivery_log.newsletter_issue_id
+			WHERE delivery_log.list_id = subscriptions.list_id
+			AND normalize_email(delivery_log.subscriber_email) = normalize_email(subscriptions.email)
+			AND (newsletter_issues.track_opens OR newsletter_issues.track_clicks)
+			ORDER BY delivery_log.sent DESC
+			LIMIT ` + limit
+}
+
+// engagedSince matches subscriptions with engagement on their list after
+// the re-engagement email was queued
+const engagedSince = `EXISTS (
+				SELECT 1 FROM engagement_events
+				WHERE engagement_events.list_id = subscriptions.list_id
+				AND normalize_email(engagement_events.subscriber_email) = normalize_email(subscriptions.email)
+				AND engagement_events.created >= subscriptions.reengagement_sent
+			)`
+
+// reengagementDelivered selects when the re-engagement email actually reached
+// the subscription, a queue backed up by the frequency cap or quiet hours
+// must not eat into the grace period
+const reengagementDelivered = `SELECT delivery_log.sent
+				FROM delivery_log
+				WHERE delivery_log.newsletter_issue_id = subscriptions.reengagement_issue_id
+				AND delivery_log.list_id = subscriptions.list_id
+				AND normalize_email(delivery_log.subscriber_email) = normalize_email(subscriptions.email)`
+
+// GetEngagementScore scores the subscription over its last window tracked
+// deliveries
+func GetEngagementScore(c context.Context, db handlers.DatabaseInterface, subscriberID string, window int) (score *models.EngagementScore, err error) {
+	score = &models.EngagementScore{SubscriberID: subscriberID}
+
+	query := `SELECT (` + reengagementDelivered + `),
+				count(recent.delivery_id),
+				count(recent.delivery_id) FILTER (WHERE EXISTS (
+					SELECT 1 FROM engagement_events WHERE engagement_events.delivery_id = recent.delivery_id
+				)),
+				count(recent.delivery_id) FILTER (WHERE EXISTS (
+					SELECT 1 FROM engagement_events WHERE engagement_events.delivery_id = recent.delivery_id AND type = 'click'
+				)),
+				(SELECT max(created) FROM engagement_events
+					WHERE engagement_events.list_id = subscriptions.list_id
+					AND normalize_email(engagement_events.subscriber_email) = normalize_email(subscriptions.email))
+			FROM subscriptions
+			LEFT JOIN LATERAL (` + recentDeliveries("$2") + `) AS recent ON true
+			WHERE subscriptions.id = $1
+			GROUP BY subscriptions.id`
+	e := db.QueryRow(c, query, subscriberID, window).Scan(
+		&score.ReengagementSent,
+		&score.Deliveries,
+		&score.Opened,
+		&score.Clicked,
+		&score.LastEngaged,
+	)
+	if e != nil {
+		if errors.Is(e, pgx.ErrNoRows) {
+			err = ErrSubscriberNotFound
+			return
+		}
+
+		err = fmt.Errorf("failed to fetch engagement score: %w", e)
+		return
+	}
+
+	score.Compute()
+	return
+}
+
+// Run applies the sunset policy once. Subscribers who engaged since their
+// re-engagement email are kept, those who did not within the grace period
+// are unsubscribed, and newly inactive subscribers are sent the
+// re-engagement email.
+func Run(c context.Context, tx pgx.Tx, settings *configs.SunsetSettings) (run *models.SunsetRun, err error) {
+	run = &models.SunsetRun{}
+
+	query := `UPDATE subscriptions SET reengagement_sent = NULL, reengagement_issue_id = NULL
+			WHERE reengagement_sent IS NOT NULL AND ` + engagedSince
+	tag, e := tx.Exec(c, query)
+	if e != nil {
+		err = fmt.Errorf("failed to clear re-engaged subscribers: %w", e)
+		return
+	}
+	run.Reengaged = int(tag.RowsAffected())
+
+	if run.Sunset, err = unsubscribeInactive(c, tx, settings); err != nil {
+		return
+	}
+
+	run.Contacted, err = sendReengagement(c, tx, settings)
+	return
+}
+
+// the re-engagement timestamp is cleared so a later resubscription starts
+// over, the address is suppressed so no other list or import mails it again
+func unsubscribeInactive(c context.Context, tx pgx.Tx, settings *configs.SunsetSettings) (count int, err error) {
+	query := `UPDATE subscriptions SET status = 'unsubscribed', reengagement_sent = NULL, reengagement_issue_id = NULL
+			WHERE status = 'confirmed'
+			AND reengagement_sent IS NOT NULL
+			AND (` + reengagementDelivered + `) <= now() - make_interval(days => $1)
+			AND NOT ` + engagedSince + `
+			RETURNING id, email`
+	rows, e := tx.Query(c, query, settings.GracePeriod)
+	if e != nil {
+		err = fmt.Errorf("failed to unsubscribe inactive subscribers: %w", e)
+		return
+	}
+
+	var ids []string
+	var batch []*models.Suppression
+	for rows.Next() {
+		var id, email string
+		if e := rows.Scan(&id, &email); e != nil {
+			rows.Close()
+			err = fmt.Errorf("failed to scan inactive subscriber: %w", e)
+			return
+		}
+
+		suppression, e := models.ParseSuppression(email, sunsetReason, models.SuppressionSourceSunset)
+		if e != nil {
+			rows.Close()
+			err = fmt.Errorf("failed to parse suppression: %w", e)
+			return
+		}
+
+		ids = append(ids, id)
+		batch = append(batch, suppression)
+	}
+	rows.Close()
+	if e := rows.Err(); e != nil {
+		err = fmt.Errorf("failed to parse inactive subscribers: %w", e)
+		return
+	}
+
+	if len(batch) > 0 {
+		if _, e := suppressions.InsertBatch(c, tx, batch); e != nil {
+			err = e
+			return
+		}
+	}
+
+	for _, id := range ids {
+		entry := &models.AuditEntry{
+			Actor:        models.AuditActorSunset,
+			Action:       models.AuditActionSunset,
+			SubscriberID: id,
+			Details:      map[string]interface{}{"inactive_issues": settings.InactiveIssues, "grace_period": settings.GracePeriod},
+		}
+		if e := audit.Record(c, tx, entry); e != nil {
+			err = e
+			return
+		}
+	}
+
+	count = len(ids)
+	return
+}
+
+// a re-engagement issue is stored per list so each run can be analysed like
+// any other issue
+func sendReengagement(c context.Context, tx pgx.Tx, settings *configs.SunsetSettings) (count int, err error) {
+	query := `SELECT subscriptions.id, subscriptions.list_id
+			FROM subscriptions
+			JOIN LATERAL (` + recentDeliveries("$1") + `) AS recent ON true
+			WHERE subscriptions.status = 'confirmed'
+			AND subscriptions.reengagement_sent IS NULL
+			AND NOT subscriptions.tracking_opt_out
+			AND (subscriptions.paused_until IS NULL OR subscriptions.paused_until <= now())
+			AND NOT ` + suppressions.Matches("subscriptions.email") + `
+			GROUP BY subscriptions.id
+			HAVING count(recent.delivery_id) = $1
+			AND count(recent.delivery_id) FILTER (WHERE EXISTS (
+				SELECT 1 FROM engagement_events WHERE engagement_events.delivery_id = recent.delivery_id
+			)) = 0`
+	rows, e := tx.Query(c, query, settings.InactiveIssues)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch inactive subscribers: %w", e)
+		return
+	}
+
+	inactive := map[string][]string{}
+	var listIDs []string
+	for rows.Next() {
+		var id, listID string
+		if e := rows.Scan(&id, &listID); e != nil {
+			rows.Close()
+			err = fmt.Errorf("failed to scan inactive subscriber: %w", e)
+			return
+		}
+
+		if _, ok := inactive[listID]; !ok {
+			listIDs = append(listIDs, listID)
+		}
+		inactive[listID] = append(inactive[listID], id)
+	}
+	rows.Close()
+	if e := rows.Err(); e != nil {
+		err = fmt.Errorf("failed to parse inactive subscribers: %w", e)
+		return
+	}
+
+	for _, listID := range listIDs {
+		ids := inactive[listID]
+
+		issueID := uuid.NewString()
+		query = `INSERT INTO newsletter_issues (
+					newsletter_issue_id,
+					title,
+					text_content,
+					html_content,
+					list_id,
+					track_opens,
+					track_clicks,
+					published_at
+				)
+				VALUES ($1, $2, $3, $4, $5, true, true, now())`
+		if _, e := tx.Exec(c, query, issueID, settings.Title, settings.Text, settings.Html, listID); e != nil {
+			err = fmt.Errorf("failed to insert re-engagement issue: %w", e)
+			return
+		}
+
+		query = `INSERT INTO issue_delivery_queue (
+					newsletter_issue_id,
+					list_id,
+					subscriber_email
+				)
+				SELECT $1, list_id, email
+				FROM subscriptions
+				WHERE id = ANY($2)`
+		if _, e := tx.Exec(c, query, issueID, ids); e != nil {
+			err = fmt.Errorf("failed to enque re-engagement email: %w", e)
+			return
+		}
+
+		query = "UPDATE subscriptions SET reengagement_sent = now(), reengagement_issue_id = $2 WHERE id = ANY($1)"
+		if _, e := tx.Exec(c, query, ids, issueID); e != nil {
+			err = fmt.Errorf("failed to mark re-engagement email: %w", e)
+			return
+		}
+
+		count += len(ids)
+	}
+
+	return
+}
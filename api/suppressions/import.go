@@ -0,0 +1,131 @@
+package suppressions
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const (
+	importBatchSize = 1000
+	maxImportRows   = 100000
+	entryColumn     = "entry"
+	reasonColumn    = "reason"
+)
+
+// ParseCSV reads one address or domain per row from the entry column, rows
+// without a reason column value fall back to reason. Invalid rows are
+// reported rather than aborting the load.
+func ParseCSV(r io.Reader, reason string) (entries []*models.Suppression, report *models.SuppressionImportReport, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, e := reader.Read()
+	if e != nil {
+		err = fmt.Errorf("failed to read header: %w", e)
+		return
+	}
+	entryIndex, reasonIndex := -1, -1
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		switch {
+		case strings.EqualFold(column, entryColumn):
+			entryIndex = i
+		case strings.EqualFold(column, reasonColumn):
+			reasonIndex = i
+		}
+	}
+	if entryIndex < 0 {
+		err = fmt.Errorf("missing entry column: %s", entryColumn)
+		return
+	}
+
+	report = &models.SuppressionImportReport{Errors: []*models.ImportRowError{}}
+	seen := make(map[string]bool)
+	for {
+		record, e := reader.Read()
+		if errors.Is(e, io.EOF) {
+			break
+		}
+
+		var parseError *csv.ParseError
+		if errors.As(e, &parseError) {
+			report.Errors = append(report.Errors, &models.ImportRowError{Row: parseError.Line, Error: parseError.Err.Error()})
+			continue
+		}
+		if e != nil {
+			err = fmt.Errorf("failed to read csv: %w", e)
+			return
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(entries)+len(report.Errors)+report.Duplicates >= maxImportRows {
+			err = fmt.Errorf("import exceeds maximum of: %d rows", maxImportRows)
+			return
+		}
+		if entryIndex >= len(record) {
+			report.Errors = append(report.Errors, &models.ImportRowError{Row: line, Error: "missing columns"})
+			continue
+		}
+
+		rowReason := reason
+		if reasonIndex >= 0 && reasonIndex < len(record) && strings.TrimSpace(record[reasonIndex]) != "" {
+			rowReason = record[reasonIndex]
+		}
+
+		entry, e := models.ParseSuppression(record[entryIndex], rowReason, models.SuppressionSourceImport)
+		if e != nil {
+			report.Errors = append(report.Errors, &models.ImportRowError{Row: line, Email: record[entryIndex], Error: e.Error()})
+			continue
+		}
+
+		key := suppressionKey(entry)
+		if seen[key] {
+			report.Duplicates++
+			continue
+		}
+		seen[key] = true
+
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// ImportSuppressions stores entries in batches and applies them to existing
+// subscriptions once every batch is in
+func ImportSuppressions(c context.Context, tx pgx.Tx, entries []*models.Suppression, report *models.SuppressionImportReport) (err error) {
+	for start := 0; start < len(entries); start += importBatchSize {
+		end := min(start+importBatchSize, len(entries))
+
+		inserted, e := InsertBatch(c, tx, entries[start:end])
+		if e != nil {
+			err = e
+			return
+		}
+
+		report.Added += inserted
+		report.Duplicates += end - start - inserted
+	}
+
+	if report.Added == 0 {
+		return
+	}
+
+	return Apply(c, tx)
+}
+
+func suppressionKey(suppression *models.Suppression) string {
+	if suppression.Domain != nil {
+		return *suppression.Domain
+	}
+
+	return *suppression.EmailHash
+}
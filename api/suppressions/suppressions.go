@@ -0,0 +1,200 @@
+package suppressions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+const maxSuppressions = 1000
+
+var (
+	ErrSuppressionNotFound = errors.New("suppression not found")
+	ErrErasureTombstone    = errors.New("erasure tombstones can not be removed")
+)
+
+// Matches is a SQL condition that holds when the email expression is
+// suppressed, either as an address or by its domain. The hash must match
+// models.HashEmail.
+func Matches(email string) string {
+	return `EXISTS (SELECT 1 FROM suppressions
+			WHERE suppressions.email_hash = encode(sha256(convert_to(normalize_email(` + email + `), 'UTF8')), 'hex')
+			OR suppressions.domain = substring(normalize_email(` + email + `) FROM '@([^@]*)$'))`
+}
+
+func IsSuppressed(c context.Context, db handlers.DatabaseInterface, email models.SubscriberEmail) (suppressed bool, err error) {
+	query := "SELECT " + Matches("$1::text")
+	if e := db.QueryRow(c, query, email.String()).Scan(&suppressed); e != nil {
+		err = fmt.Errorf("failed to check suppression: %w", e)
+		return
+	}
+
+	return
+}
+
+// Insert stores suppression unless its address or domain is already
+// suppressed
+func Insert(c context.Context, db handlers.DatabaseInterface, suppression *models.Suppression) (inserted bool, err error) {
+	suppression.ID = uuid.NewString()
+	query := `INSERT INTO suppressions (suppression_id, email, email_hash, domain, reason, source, created)
+			VALUES ($1, $2, $3, $4, $5, $6, now())
+			ON CONFLICT DO NOTHING`
+	tag, e := db.Exec(c, query, suppression.ID, emailValue(suppression), suppression.EmailHash, suppression.Domain, suppression.Reason, suppression.Source)
+	if e != nil {
+		err = fmt.Errorf("failed to insert suppression: %w", e)
+		return
+	}
+
+	inserted = tag.RowsAffected() > 0
+	return
+}
+
+// InsertBatch stores every suppression in a single statement, entries already
+// present are skipped
+func InsertBatch(c context.Context, tx pgx.Tx, batch []*models.Suppression) (inserted int, err error) {
+	ids := make([]string, len(batch))
+	emails := make([]*string, len(batch))
+	hashes := make([]*string, len(batch))
+	domains := make([]*string, len(batch))
+	reasons := make([]string, len(batch))
+	sources := make([]string, len(batch))
+	for i, suppression := range batch {
+		suppression.ID = uuid.NewString()
+		ids[i], emails[i], hashes[i], domains[i] = suppression.ID, emailValue(suppression), suppression.EmailHash, suppression.Domain
+		reasons[i], sources[i] = suppression.Reason, suppression.Source
+	}
+
+	query := `INSERT INTO suppressions (suppression_id, email, email_hash, domain, reason, source, created)
+			SELECT *, now() FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[])
+			ON CONFLICT DO NOTHING`
+	tag, e := tx.Exec(c, query, ids, emails, hashes, domains, reasons, sources)
+	if e != nil {
+		err = fmt.Errorf("failed to insert suppressions: %w", e)
+		return
+	}
+
+	inserted = int(tag.RowsAffected())
+	return
+}
+
+// Apply marks every matching subscription as suppressed and drops their
+// queued deliveries
+func Apply(c context.Context, tx pgx.Tx) (err error) {
+	query := "UPDATE subscriptions SET status = 'suppressed' WHERE status <> 'suppressed' AND " + Matches("subscriptions.email")
+	if _, e := tx.Exec(c, query); e != nil {
+		err = fmt.Errorf("failed to suppress subscriptions: %w", e)
+		return
+	}
+
+	query = "DELETE FROM issue_delivery_queue WHERE " + Matches("issue_delivery_queue.subscriber_email")
+	if _, e := tx.Exec(c, query); e != nil {
+		err = fmt.Errorf("failed to delete delivery tasks: %w", e)
+		return
+	}
+
+	return
+}
+
+// GetSuppressions returns the most recent suppressions whose address or
+// domain contains search, along with the total number of matches
+func GetSuppressions(c context.Context, db handlers.DatabaseInterface, search string) (suppressions []*models.Suppression, total int, err error) {
+	filter := "$1 = '' OR strpos(email, lower($1)) > 0 OR strpos(domain, lower($1)) > 0"
+
+	query := "SELECT COUNT(*) FROM suppressions WHERE " + filter
+	if e := db.QueryRow(c, query, search).Scan(&total); e != nil {
+		err = fmt.Errorf("failed to count suppressions: %w", e)
+		return
+	}
+
+	query = `SELECT suppression_id, email, email_hash, domain, reason, source, created
+			FROM suppressions
+			WHERE ` + filter + `
+			ORDER BY created DESC
+			LIMIT $2`
+	rows, e := db.Query(c, query, search, maxSuppressions)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch suppressions: %w", e)
+		return
+	}
+	defer rows.Close()
+
+	suppressions, e = pgx.CollectRows[*models.Suppression](rows, BuildSuppression)
+	if e != nil {
+		err = fmt.Errorf("failed to parse suppressions: %w", e)
+		return
+	}
+
+	return
+}
+
+// DeleteSuppression lifts a suppression, subscriptions it marked stay
+// suppressed until edited
+func DeleteSuppression(c context.Context, db handlers.DatabaseInterface, id string) (err error) {
+	query := "DELETE FROM suppressions WHERE suppression_id = $1 AND source <> $2"
+	tag, e := db.Exec(c, query, id, models.SuppressionSourceErasure)
+	if e != nil {
+		err = fmt.Errorf("failed to delete suppression: %w", e)
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		return
+	}
+
+	var exists bool
+	query = "SELECT EXISTS (SELECT 1 FROM suppressions WHERE suppression_id = $1)"
+	if e := db.QueryRow(c, query, id).Scan(&exists); e != nil {
+		err = fmt.Errorf("failed to fetch suppression: %w", e)
+		return
+	}
+
+	err = ErrSuppressionNotFound
+	if exists {
+		err = ErrErasureTombstone
+	}
+
+	return
+}
+
+func BuildSuppression(row pgx.CollectableRow) (suppression *models.Suppression, err error) {
+	var email *string
+	suppression = &models.Suppression{}
+	e := row.Scan(
+		&suppression.ID,
+		&email,
+		&suppression.EmailHash,
+		&suppression.Domain,
+		&suppression.Reason,
+		&suppression.Source,
+		&suppression.Created,
+	)
+	if e != nil {
+		err = fmt.Errorf("failed to scan suppression: %w", e)
+		return
+	}
+
+	if email != nil {
+		parsed := models.SubscriberEmail(*email)
+		suppression.Email = &parsed
+	}
+
+	suppression.Kind = models.SuppressionKindEmail
+	if suppression.Domain != nil {
+		suppression.Kind = models.SuppressionKindDomain
+	}
+
+	return
+}
+
+func emailValue(suppression *models.Suppression) *string {
+	if suppression.Email == nil {
+		return nil
+	}
+
+	email := suppression.Email.String()
+	return &email
+}
@@ -0,0 +1,199 @@
+package tracking
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/signing"
+)
+
+// ErrInvalidLink is returned for tracking tokens that verify but do not
+// carry a delivery, or a click target that is not a web link
+var ErrInvalidLink = errors.New("invalid tracking link")
+
+// OpenLink is the pixel recording an open of the delivery
+func OpenLink(signer *signing.Signer, deliveryID string) string {
+	return handlers.BaseURL + "/t/o/" + signer.Sign(signing.PurposeOpen, deliveryID)
+}
+
+// ClickLink redirects to target, the target is signed with the delivery so
+// the redirect can not be pointed elsewhere
+func ClickLink(signer *signing.Signer, deliveryID string, target string) string {
+	return handlers.BaseURL + "/t/c/" + signer.Sign(signing.PurposeClick, deliveryID+"\n"+target)
+}
+
+func ParseOpenToken(signer *signing.Signer, token string) (deliveryID string, err error) {
+	payload, e := signer.Verify(signing.PurposeOpen, token)
+	if e != nil {
+		err = e
+		return
+	}
+	if _, e := uuid.Parse(payload); e != nil {
+		err = ErrInvalidLink
+		return
+	}
+
+	deliveryID = payload
+	return
+}
+
+func ParseClickToken(signer *signing.Signer, token string) (deliveryID string, target string, err error) {
+	payload, e := signer.Verify(signing.PurposeClick, token)
+	if e != nil {
+		err = e
+		return
+	}
+
+	deliveryID, target, _ = strings.Cut(payload, "\n")
+	if _, e := uuid.Parse(deliveryID); e != nil || !isTrackable(target) {
+		err = ErrInvalidLink
+		return
+	}
+
+	return
+}
+
+// only web links are tracked, links into the preference center are left
+// alone so unsubscribing never depends on the tracker
+func isTrackable(target string) bool {
+	parsed, e := url.Parse(target)
+	if e != nil || parsed.Host == "" {
+		return false
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return false
+	}
+
+	return !strings.HasPrefix(target, handlers.BaseURL+"/preferences/")
+}
+
+// Instrument rewrites the links of the HTML body into click redirects and
+// appends the open pixel, as enabled by tracking. The text body is left
+// untouched.
+func Instrument(body *models.Body, signer *signing.Signer, deliveryID string, tracking models.Tracking) (err error) {
+	if !tracking.Opens && !tracking.Clicks {
+		return
+	}
+
+	document, e := html.Parse(strings.NewReader(body.Html))
+	if e != nil {
+		err = fmt.Errorf("failed to parse html body: %w", e)
+		return
+	}
+
+	var bodyNode *html.Node
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.DataAtom {
+			case atom.Body:
+				bodyNode = node
+			case atom.A:
+				if tracking.Clicks {
+					rewriteLink(node, signer, deliveryID)
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+
+	if tracking.Opens && bodyNode != nil {
+		bodyNode.AppendChild(&html.Node{
+			Type:     html.ElementNode,
+			Data:     "img",
+			DataAtom: atom.Img,
+			Attr: []html.Attribute{
+				{Key: "src", Val: OpenLink(signer, deliveryID)},
+				{Key: "width", Val: "1"},
+				{Key: "height", Val: "1"},
+				{Key: "alt", Val: ""},
+				{Key: "style", Val: "display:none"},
+			},
+		})
+	}
+
+	var rendered bytes.Buffer
+	if e := html.Render(&rendered, document); e != nil {
+		err = fmt.Errorf("failed to render html body: %w", e)
+		return
+	}
+
+	body.Html = rendered.String()
+	return
+}
+
+func rewriteLink(node *html.Node, signer *signing.Signer, deliveryID string) {
+	for i, attribute := range node.Attr {
+		if attribute.Namespace != "" || attribute.Key != "href" {
+			continue
+		}
+
+		target := strings.TrimSpace(attribute.Val)
+		if isTrackable(target) {
+			node.Attr[i].Val = ClickLink(signer, deliveryID, target)
+		}
+		return
+	}
+}
+
+// GetTracking returns the tracking enabled for the issue, none when the
+// recipient opted out
+func GetTracking(c context.Context, tx pgx.Tx, issueID string, listID string, email models.SubscriberEmail) (tracking models.Tracking, err error) {
+	var optOut bool
+	query := `SELECT newsletter_issues.track_opens, newsletter_issues.track_clicks,
+				COALESCE(subscriptions.tracking_opt_out, false)
+			FROM newsletter_issues
+			LEFT JOIN subscriptions ON subscriptions.list_id = $2 AND subscriptions.email = $3
+			WHERE newsletter_issues.newsletter_issue_id = $1`
+	e := tx.QueryRow(c, query, issueID, listID, email.String()).Scan(&tracking.Opens, &tracking.Clicks, &optOut)
+	if e != nil {
+		err = fmt.Errorf("failed to fetch issue tracking: %w", e)
+		return
+	}
+
+	if optOut {
+		tracking = models.Tracking{}
+	}
+
+	return
+}
+
+// RecordEvent stores an engagement event against the delivery, nothing is
+// recorded for deliveries that were erased
+func RecordEvent(c context.Context, db handlers.DatabaseInterface, deliveryID string, eventType string, target *string) (recorded bool, err error) {
+	query := `INSERT INTO engagement_events (
+				event_id,
+				delivery_id,
+				newsletter_issue_id,
+				list_id,
+				subscriber_email,
+				type,
+				url,
+				created
+			)
+			SELECT $1, delivery_id, newsletter_issue_id, list_id, subscriber_email, $3, $4, now()
+			FROM delivery_log
+			WHERE delivery_id = $2`
+	tag, e := db.Exec(c, query, uuid.NewString(), deliveryID, eventType, target)
+	if e != nil {
+		err = fmt.Errorf("failed to record %s: %w", eventType, e)
+		return
+	}
+
+	recorded = tag.RowsAffected() > 0
+	return
+}
@@ -0,0 +1,113 @@
+package webhooks
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/solomonbaez/hyacinth/api/bounces"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+var (
+	ErrNotARF       = errors.New("message is not an abuse feedback report")
+	ErrNotComplaint = errors.New("feedback report is not a complaint")
+)
+
+// feedback types reporting the recipient did not want the message, not-spam
+// and auth-failure reports are informational
+var complaintTypes = map[string]bool{"abuse": true, "fraud": true, "virus": true, "other": true}
+
+// ParseARF reads an RFC 5965 abuse report into a complaint. Providers often
+// redact the recipient, so the complaint is also attributed through the VERP
+// return path of the reported message when verp is set.
+func ParseARF(r io.Reader, verp *bounces.VERP) (event *models.MailEvent, err error) {
+	raw, e := io.ReadAll(r)
+	if e != nil {
+		err = fmt.Errorf("failed to read report: %w", e)
+		return
+	}
+
+	message, e := mail.ReadMessage(bytes.NewReader(raw))
+	if e != nil {
+		err = fmt.Errorf("failed to parse report: %w", e)
+		return
+	}
+
+	mediaType, params, e := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if e != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "feedback-report") {
+		err = ErrNotARF
+		return
+	}
+
+	event = &models.MailEvent{ID: strings.TrimSpace(message.Header.Get("Message-Id")), Type: models.MailEventComplaint}
+	if event.ID == "" {
+		sum := sha256.Sum256(raw)
+		event.ID = hex.EncodeToString(sum[:])
+	}
+
+	var feedback textproto.MIMEHeader
+	var original *mail.Header
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, e := parts.NextPart()
+		if errors.Is(e, io.EOF) {
+			break
+		}
+		if e != nil {
+			err = fmt.Errorf("failed to read report: %w", e)
+			return
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/feedback-report":
+			if feedback, e = textproto.NewReader(bufio.NewReader(part)).ReadMIMEHeader(); e != nil && len(feedback) == 0 {
+				err = ErrNotARF
+				return
+			}
+		case "message/rfc822", "text/rfc822-headers":
+			if headers, e := textproto.NewReader(bufio.NewReader(part)).ReadMIMEHeader(); len(headers) > 0 || e == nil {
+				header := mail.Header(headers)
+				original = &header
+			}
+		}
+	}
+	if feedback == nil {
+		err = ErrNotARF
+		return
+	}
+	if !complaintTypes[strings.ToLower(strings.TrimSpace(feedback.Get("Feedback-Type")))] {
+		err = ErrNotComplaint
+		return
+	}
+
+	recipient := strings.Trim(strings.TrimSpace(feedback.Get("Original-Rcpt-To")), "<>")
+	if recipient == "" && original != nil {
+		if addresses, e := original.AddressList("To"); e == nil && len(addresses) == 1 {
+			recipient = addresses[0].Address
+		}
+	}
+	// redacted recipients are dropped in favour of the delivery
+	if email, e := models.ParseEmail(recipient); e == nil {
+		event.Email = email
+	}
+
+	if verp != nil && original != nil {
+		if deliveryID, e := verp.Parse(strings.Trim(strings.TrimSpace(original.Get("Return-Path")), "<>")); e == nil {
+			event.DeliveryID = deliveryID
+		}
+	}
+
+	err = models.ParseMailEvent(event)
+	return
+}
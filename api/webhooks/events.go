@@ -0,0 +1,105 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solomonbaez/hyacinth/api/bounces"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/subscribers"
+)
+
+const complaintReason = "spam complaint"
+
+// ParseEvents accepts a single event or an array of events
+func ParseEvents(body []byte) (events []*models.MailEvent, err error) {
+	body = bytes.TrimSpace(body)
+	if bytes.HasPrefix(body, []byte("[")) {
+		err = json.Unmarshal(body, &events)
+	} else {
+		event := &models.MailEvent{}
+		err = json.Unmarshal(body, event)
+		events = []*models.MailEvent{event}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to decode events: %w", err)
+		return
+	}
+
+	for i, event := range events {
+		if event == nil {
+			err = fmt.Errorf("event %d is empty", i)
+			return
+		}
+		if e := models.ParseMailEvent(event); e != nil {
+			err = fmt.Errorf("invalid event %d: %w", i, e)
+			return
+		}
+	}
+
+	return
+}
+
+// ProcessEvent applies event once, replays of an event ID already seen are
+// skipped. Complaints suppress the address, bounces count towards the bounce
+// thresholds.
+func ProcessEvent(c context.Context, tx pgx.Tx, event *models.MailEvent, settings *configs.BounceSettings) (processed bool, err error) {
+	query := `INSERT INTO mail_events (event_id, type, received)
+			VALUES ($1, $2, now())
+			ON CONFLICT (event_id) DO NOTHING`
+	tag, e := tx.Exec(c, query, event.ID, event.Type)
+	if e != nil {
+		err = fmt.Errorf("failed to record event: %w", e)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		return
+	}
+
+	bounce := &models.Bounce{
+		MessageID:  event.ID,
+		Email:      event.Email,
+		Kind:       event.Bounce,
+		Status:     event.Status,
+		Diagnostic: event.Diagnostic,
+	}
+	if event.DeliveryID != "" {
+		bounce.DeliveryID = &event.DeliveryID
+		if e := bounces.LookupDelivery(c, tx, bounce); e != nil {
+			if !errors.Is(e, bounces.ErrUnattributed) || event.Email == "" {
+				err = e
+				return
+			}
+
+			bounce.DeliveryID = nil
+		}
+	}
+
+	if event.Type == models.MailEventComplaint {
+		suppression, e := models.ParseSuppression(bounce.Email.String(), complaintReason, models.SuppressionSourceWebhook)
+		if e != nil {
+			err = fmt.Errorf("failed to parse suppression: %w", e)
+			return
+		}
+		if e := subscribers.Suppress(c, tx, bounce.Email, suppression); e != nil {
+			err = e
+			return
+		}
+
+		processed = true
+		return
+	}
+
+	if _, _, e := bounces.RecordBounce(c, tx, bounce, settings, models.SuppressionSourceWebhook); e != nil {
+		err = e
+		return
+	}
+
+	processed = true
+	return
+}
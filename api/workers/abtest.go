@@ -0,0 +1,76 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/abtests"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+)
+
+const subjectTestInterval = 1
+
+func SubjectTestWorker(c context.Context, dh *handlers.DatabaseHandler) {
+	ticker := time.NewTicker(subjectTestInterval * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+			if e := DecideSubjectTests(c, dh); e != nil {
+				log.Error().
+					Err(e).
+					Msg("Failed to decide subject tests")
+			}
+		}
+	}
+}
+
+// DecideSubjectTests picks the winner of every test whose window has passed
+// and sends it to the held back recipients
+func DecideSubjectTests(c context.Context, dh *handlers.DatabaseHandler) (err error) {
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", e)
+		return
+	}
+	defer tx.Rollback(c)
+
+	issueIDs, e := abtests.DueTests(c, tx)
+	if e != nil {
+		err = e
+		return
+	}
+
+	for _, issueID := range issueIDs {
+		test, e := abtests.GetSubjectTest(c, tx, issueID)
+		if e != nil {
+			err = e
+			return
+		}
+
+		winner := test.PickWinner()
+		released, e := abtests.Decide(c, tx, issueID, winner)
+		if e != nil {
+			err = e
+			return
+		}
+
+		log.Info().
+			Str("issue", issueID).
+			Int("winner", winner).
+			Int("released", released).
+			Msg("Subject test decided")
+	}
+
+	if e := tx.Commit(c); e != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", e)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,102 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/bounces"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+)
+
+func BounceWorker(c context.Context, dh *handlers.DatabaseHandler, settings *configs.BounceSettings, verp *bounces.VERP) {
+	ticker := time.NewTicker(time.Duration(settings.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+			if e := ProcessBounces(c, dh, settings, verp); e != nil {
+				log.Error().
+					Err(e).
+					Msg("Failed to process bounces")
+			}
+		}
+	}
+}
+
+// ProcessBounces reads every new message from the configured source
+func ProcessBounces(c context.Context, dh *handlers.DatabaseHandler, settings *configs.BounceSettings, verp *bounces.VERP) (err error) {
+	handle := func(r io.Reader) error {
+		return ProcessBounce(c, dh, settings, verp, r)
+	}
+
+	if settings.Format == configs.BounceFormatMbox {
+		return bounces.ReadMbox(settings.Source, handle)
+	}
+
+	return bounces.ReadMaildir(settings.Source, handle)
+}
+
+// ProcessBounce records a single message, messages that are not bounces we
+// can attribute are logged and consumed while database errors leave the
+// message for the next read
+func ProcessBounce(c context.Context, dh *handlers.DatabaseHandler, settings *configs.BounceSettings, verp *bounces.VERP, r io.Reader) (err error) {
+	dsn, e := bounces.ParseDSN(r)
+	if e != nil {
+		log.Info().
+			Err(e).
+			Msg("Skipping message")
+
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", e)
+		return
+	}
+	defer tx.Rollback(c)
+
+	bounce, e := bounces.Attribute(c, tx, verp, dsn)
+	if errors.Is(e, bounces.ErrNotBounce) || errors.Is(e, bounces.ErrUnattributed) {
+		log.Info().
+			Err(e).
+			Str("message", dsn.MessageID).
+			Msg("Skipping message")
+
+		return
+	}
+	if e != nil {
+		err = e
+		return
+	}
+
+	recorded, bounced, e := bounces.RecordBounce(c, tx, bounce, settings, models.SuppressionSourceBounce)
+	if e != nil {
+		err = e
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", e)
+		return
+	}
+
+	if recorded {
+		log.Info().
+			Str("subscriber", bounce.Email.String()).
+			Str("kind", bounce.Kind).
+			Str("status", bounce.Status).
+			Bool("bounced", bounced).
+			Msg("Bounce recorded")
+	}
+
+	return
+}
@@ -0,0 +1,63 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/sunset"
+)
+
+func SunsetWorker(c context.Context, dh *handlers.DatabaseHandler, settings *configs.SunsetSettings) {
+	ticker := time.NewTicker(time.Duration(settings.Interval) * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+			if e := RunSunset(c, dh, settings); e != nil {
+				log.Error().
+					Err(e).
+					Msg("Failed to apply sunset policy")
+			}
+		}
+	}
+}
+
+// RunSunset applies the sunset policy in a single transaction, nothing is
+// done when the policy is disabled
+func RunSunset(c context.Context, dh *handlers.DatabaseHandler, settings *configs.SunsetSettings) (err error) {
+	if settings.InactiveIssues == 0 {
+		return
+	}
+
+	tx, e := dh.DB.Begin(c)
+	if e != nil {
+		err = fmt.Errorf("failed to begin transaction: %w", e)
+		return
+	}
+	defer tx.Rollback(c)
+
+	run, e := sunset.Run(c, tx, settings)
+	if e != nil {
+		err = e
+		return
+	}
+	if e := tx.Commit(c); e != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", e)
+		return
+	}
+
+	log.Info().
+		Int("reengaged", run.Reengaged).
+		Int("contacted", run.Contacted).
+		Int("sunset", run.Sunset).
+		Msg("Sunset policy applied")
+
+	return
+}
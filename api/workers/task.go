@@ -2,25 +2,48 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/abtests"
+	"github.com/solomonbaez/hyacinth/api/attachments"
+	"github.com/solomonbaez/hyacinth/api/bounces"
 	"github.com/solomonbaez/hyacinth/api/clients"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/frequency"
 	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/lists"
 	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/preferences"
+	"github.com/solomonbaez/hyacinth/api/privacy"
+	"github.com/solomonbaez/hyacinth/api/segments"
+	"github.com/solomonbaez/hyacinth/api/sequences"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+	"github.com/solomonbaez/hyacinth/api/tracking"
 )
 
 type Task struct {
 	NewsletterIssueID string
+	ListID            string
 	SubscriberEmail   models.SubscriberEmail
+	// set for sequence steps
+	SequenceID *string
+	// set for issues testing subject lines
+	Variant *int
 }
 
 // TODO implement n_retries + execute_after columns to issue_delivery_queue to attempt retries
 
 // TODO fix error handling, err is the idiomatic syntax per my codebase
-func TryExecuteTask(c context.Context, dh *handlers.DatabaseHandler, client *clients.SMTPClient) ExecutionOutcome {
+// TryExecuteTask sends the next due task, non-transactional mail over the
+// frequency cap is deferred instead. A nil frequencyCap disables the cap, a
+// nil cache loads the issue's attachments for every task.
+func TryExecuteTask(c context.Context, dh *handlers.DatabaseHandler, client *clients.SMTPClient, signer *signing.Signer, verp *bounces.VERP, frequencyCap *configs.FrequencyCapSettings, cache *attachments.Cache) ExecutionOutcome {
 	task, tx, e := DequeTask(c, dh)
 	defer func() {
 		if e != nil {
@@ -41,23 +64,162 @@ func TryExecuteTask(c context.Context, dh *handlers.DatabaseHandler, client *cli
 		return ExecutionOutcomeError
 	}
 
-	// TODO add confirmation email logic
+	// suppressions added after the task was queued still apply
+	var suppressed bool
+	suppressed, e = suppressions.IsSuppressed(c, tx, newsletter.Recipient)
+	if e != nil {
+		// tryChan <- ExecutionOutcomeError
+		return ExecutionOutcomeError
+	}
+	if suppressed {
+		if e = DeleteTask(c, tx, task); e != nil {
+			// tryChan <- ExecutionOutcomeError
+			return ExecutionOutcomeError
+		}
+
+		log.Info().
+			Str("subscriber", task.SubscriberEmail.String()).
+			Msg("Delivery suppressed")
+
+		return ExecutionOutcomeTaskCompleted
+	}
+
+	// sequences stop once the subscriber leaves the list
+	if task.SequenceID != nil {
+		var active bool
+		active, e = sequences.IsSubscribed(c, tx, task.ListID, task.SubscriberEmail)
+		if e != nil {
+			// tryChan <- ExecutionOutcomeError
+			return ExecutionOutcomeError
+		}
+		if !active {
+			if e = DeleteTask(c, tx, task); e != nil {
+				// tryChan <- ExecutionOutcomeError
+				return ExecutionOutcomeError
+			}
+
+			log.Info().
+				Str("subscriber", task.SubscriberEmail.String()).
+				Str("sequence", *task.SequenceID).
+				Msg("Sequence step dropped, subscriber left the list")
+
+			return ExecutionOutcomeTaskCompleted
+		}
+	}
+
+	list, e := lists.GetList(c, tx, task.ListID)
+	if e != nil {
+		// tryChan <- ExecutionOutcomeError
+		return ExecutionOutcomeError
+	}
+	newsletter.Sender = list.Sender()
+
+	if frequencyCap != nil && !frequency.IsTransactional(task.NewsletterIssueID, list) {
+		var next *time.Time
+		next, e = frequency.NextSlot(c, tx, task.SubscriberEmail, frequencyCap)
+		if e != nil {
+			// tryChan <- ExecutionOutcomeError
+			return ExecutionOutcomeError
+		}
+		if next != nil {
+			if e = DeferTask(c, tx, task, *next); e != nil {
+				// tryChan <- ExecutionOutcomeError
+				return ExecutionOutcomeError
+			}
+
+			log.Info().
+				Str("subscriber", task.SubscriberEmail.String()).
+				Time("until", *next).
+				Msg("Delivery deferred, frequency cap reached")
+
+			return ExecutionOutcomeTaskCompleted
+		}
+	}
+
 	newsletter.Content, e = GetIssue(c, tx, task.NewsletterIssueID)
 	if e != nil {
 		// tryChan <- ExecutionOutcomeError
 		return ExecutionOutcomeError
 	}
-	// base confirmation email == 0 -> it may be obtuse for this to be hardcoded
-	if task.NewsletterIssueID == "00000000-0000-0000-0000-000000000000" {
-		link, e := handlers.GenerateConfirmationLink(c, tx, &newsletter.Recipient)
+	if task.Variant != nil {
+		newsletter.Content.Title, e = abtests.GetSubject(c, tx, task.NewsletterIssueID, *task.Variant)
+		if e != nil {
+			// tryChan <- ExecutionOutcomeError
+			return ExecutionOutcomeError
+		}
+	}
+
+	// email change tasks are addressed to emails without a subscription,
+	// data export links are account emails without a preferences footer
+	var subscriberID, preferencesLink string
+	var data models.TemplateData
+	switch {
+	case preferences.IsEmailChangeIssue(task.NewsletterIssueID):
+		data, e = preferences.GetEmailChangeTemplateData(c, tx, task.NewsletterIssueID, task.SubscriberEmail)
+	case privacy.IsDataExportIssue(task.NewsletterIssueID):
+		data, e = privacy.GetDataExportTemplateData(c, tx, task.SubscriberEmail)
+	default:
+		subscriberID, data, e = GetTemplateData(c, tx, task)
+	}
+	if e != nil {
+		// tryChan <- ExecutionOutcomeError
+		return ExecutionOutcomeError
+	}
+	if subscriberID != "" {
+		preferencesLink = handlers.GeneratePreferencesLink(signer, subscriberID)
+		data["preferences"] = preferencesLink
+	}
+	// each list names its own confirmation template
+	if task.NewsletterIssueID == list.ConfirmationIssueID {
+		var link string
+		link, e = handlers.GenerateConfirmationLink(c, tx, list.ID, &newsletter.Recipient)
 		if e != nil {
 			// tryChan <- ExecutionOutcomeError
 			return ExecutionOutcomeError
 		}
+		data["link"] = link
+	}
+
+	newsletter.Content, e = models.RenderBody(newsletter.Content, data)
+	if e != nil {
+		log.Error().
+			Err(e).
+			Str("issue", task.NewsletterIssueID).
+			Msg("Failed to render newsletter")
+
+		// tryChan <- ExecutionOutcomeError
+		return ExecutionOutcomeError
+	}
+	// opens, clicks and bounces are attributed to the delivery
+	deliveryID := uuid.NewString()
+	if subscriberID != "" {
+		models.AppendPreferencesLink(newsletter.Content, preferencesLink)
+		newsletter.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + handlers.GenerateUnsubscribeLink(signer, subscriberID) + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
 
-		// replace placeholders with new link
-		newsletter.Content.Text = strings.Replace(newsletter.Content.Text, "{{.link}}", link, 1)
-		newsletter.Content.Html = strings.Replace(newsletter.Content.Html, "{{.link}}", link, 1)
+		var issueTracking models.Tracking
+		issueTracking, e = tracking.GetTracking(c, tx, task.NewsletterIssueID, task.ListID, task.SubscriberEmail)
+		if e != nil {
+			// tryChan <- ExecutionOutcomeError
+			return ExecutionOutcomeError
+		}
+		if e = tracking.Instrument(newsletter.Content, signer, deliveryID, issueTracking); e != nil {
+			// tryChan <- ExecutionOutcomeError
+			return ExecutionOutcomeError
+		}
+	}
+
+	newsletter.Attachments, e = cache.Get(c, tx, task.NewsletterIssueID)
+	if e != nil {
+		// tryChan <- ExecutionOutcomeError
+		return ExecutionOutcomeError
+	}
+
+	// bounces are attributed to the delivery through the return path
+	if verp != nil {
+		newsletter.ReturnPath = verp.Address(deliveryID)
 	}
 
 	if e = models.ParseNewsletter(&newsletter); e != nil {
@@ -65,10 +227,27 @@ func TryExecuteTask(c context.Context, dh *handlers.DatabaseHandler, client *cli
 		return ExecutionOutcomeError
 	}
 	if e = client.SendEmail(&newsletter); e != nil {
+		if !errors.Is(e, clients.ErrSMTPUTF8Unsupported) {
+			// tryChan <- ExecutionOutcomeError
+			return ExecutionOutcomeError
+		}
+
+		// the address can never be relayed by this server
+		if e = DeleteTask(c, tx, task); e != nil {
+			return ExecutionOutcomeError
+		}
+
+		log.Warn().
+			Str("subscriber", task.SubscriberEmail.String()).
+			Msg("Delivery dropped, server does not support SMTPUTF8")
+
+		return ExecutionOutcomeTaskCompleted
+	}
+
+	if e = LogDelivery(c, tx, task, deliveryID); e != nil {
 		// tryChan <- ExecutionOutcomeError
 		return ExecutionOutcomeError
 	}
-
 	if e = DeleteTask(c, tx, task); e != nil {
 		// tryChan <- ExecutionOutcomeError
 		return ExecutionOutcomeError
@@ -90,12 +269,13 @@ func DequeTask(c context.Context, dh *handlers.DatabaseHandler) (task *Task, tx
 	}
 
 	task = &Task{}
-	query := `SELECT newsletter_issue_id, subscriber_email
+	query := `SELECT newsletter_issue_id, list_id, subscriber_email, sequence_id, variant
 			FROM issue_delivery_queue
+			WHERE execute_after <= now()
 			FOR UPDATE
 			SKIP LOCKED
 			LIMIT 1`
-	e = tx.QueryRow(c, query).Scan(&task.NewsletterIssueID, &task.SubscriberEmail)
+	e = tx.QueryRow(c, query).Scan(&task.NewsletterIssueID, &task.ListID, &task.SubscriberEmail, &task.SequenceID, &task.Variant)
 	if e != nil {
 		err = fmt.Errorf("failed to deque delivery task: %w", e)
 		return
@@ -117,12 +297,43 @@ func GetIssue(c context.Context, tx pgx.Tx, issueID string) (content *models.Bod
 	return
 }
 
+// GetTemplateData loads the recipient's profile for per-recipient rendering
+func GetTemplateData(c context.Context, tx pgx.Tx, task *Task) (subscriberID string, data models.TemplateData, err error) {
+	var name models.SubscriberName
+	var attributes models.Attributes
+	query := `SELECT id, name, attributes
+			FROM subscriptions
+			WHERE list_id = $1 AND email = $2`
+	e := tx.QueryRow(c, query, task.ListID, task.SubscriberEmail.String()).Scan(&subscriberID, &name, &attributes)
+	if e != nil {
+		err = fmt.Errorf("failed to retrieve recipient: %w", e)
+		return
+	}
+
+	data = models.NewTemplateData(task.SubscriberEmail, name, attributes)
+	return
+}
+
+// LogDelivery keeps a record of sent emails for data subject access requests
+func LogDelivery(c context.Context, tx pgx.Tx, task *Task, deliveryID string) (err error) {
+	query := `INSERT INTO delivery_log (delivery_id, newsletter_issue_id, list_id, subscriber_email, variant, sent)
+			VALUES ($1, $2, $3, $4, $5, now())`
+	_, e := tx.Exec(c, query, deliveryID, task.NewsletterIssueID, task.ListID, task.SubscriberEmail.String(), task.Variant)
+	if e != nil {
+		err = fmt.Errorf("failed to log delivery: %w", e)
+		return
+	}
+
+	return
+}
+
 func DeleteTask(c context.Context, tx pgx.Tx, task *Task) (err error) {
 	query := `DELETE FROM issue_delivery_queue
 			WHERE 
 			newsletter_issue_id = $1 AND
-			subscriber_email = $2`
-	_, e := tx.Exec(c, query, task.NewsletterIssueID, task.SubscriberEmail.String())
+			list_id = $2 AND
+			subscriber_email = $3`
+	_, e := tx.Exec(c, query, task.NewsletterIssueID, task.ListID, task.SubscriberEmail.String())
 	if e != nil {
 		err = fmt.Errorf("failed to delete delivery task")
 		return
@@ -136,15 +347,74 @@ func DeleteTask(c context.Context, tx pgx.Tx, task *Task) (err error) {
 	return
 }
 
-func EnqueDeliveryTasks(c context.Context, tx pgx.Tx, newsletterIssueId string) (err error) {
+// DeferTask moves the task back in the queue until the given time
+func DeferTask(c context.Context, tx pgx.Tx, task *Task, until time.Time) (err error) {
+	query := `UPDATE issue_delivery_queue
+			SET execute_after = $4
+			WHERE
+				newsletter_issue_id = $1 AND
+				list_id = $2 AND
+				subscriber_email = $3`
+	_, e := tx.Exec(c, query, task.NewsletterIssueID, task.ListID, task.SubscriberEmail.String(), until)
+	if e != nil {
+		err = fmt.Errorf("failed to defer delivery task: %w", e)
+		return
+	}
+
+	if e := tx.Commit(c); e != nil {
+		err = fmt.Errorf("failed to commit deferred task: %w", e)
+		return
+	}
+
+	return
+}
+
+// EnqueDeliveryTasks queues the issue for its audience. With a subject test
+// only a random sample is queued, split evenly across the variants, while
+// the rest of the audience is held back until the winner is picked.
+// Scheduled issues are held until their send time, in local time mode it
+// is computed from each recipient's zone.
+func EnqueDeliveryTasks(c context.Context, tx pgx.Tx, newsletterIssueId string, listID string, audience []*models.Segment, test *models.SubjectTest, schedule *models.Schedule) (err error) {
+	filter, args, e := segments.RecipientFilter(listID, audience, []interface{}{newsletterIssueId})
+	if e != nil {
+		err = fmt.Errorf("failed to build recipient filter: %w", e)
+		return
+	}
+
+	var sendAfter string
+	sendAfter, args = sendAfterExpression(schedule, args)
+
 	query := `INSERT INTO issue_delivery_queue (
 				newsletter_issue_id,
-				subscriber_email
+				list_id,
+				subscriber_email,
+				execute_after
 			)
-			SELECT $1, email
+			SELECT $1, list_id, email, ` + sendAfter + `
 			FROM subscriptions
-			WHERE status = 'confirmed'`
-	_, e := tx.Exec(c, query, newsletterIssueId)
+			WHERE ` + filter
+	if test != nil {
+		args = append(args, len(test.Variants), test.SamplePercent)
+		variants, percent := len(args)-1, len(args)
+		query = fmt.Sprintf(`INSERT INTO issue_delivery_queue (
+					newsletter_issue_id,
+					list_id,
+					subscriber_email,
+					variant,
+					execute_after
+				)
+				SELECT $1, list_id, email,
+					CASE WHEN position <= sample THEN (position - 1) %% $%d + 1 END,
+					CASE WHEN position <= sample THEN send_after ELSE 'infinity' END
+				FROM (
+					SELECT list_id, email, %s AS send_after,
+						row_number() OVER (ORDER BY random()) AS position,
+						ceil(count(*) OVER () * $%d / 100.0) AS sample
+					FROM subscriptions
+					WHERE %s
+				) AS recipients`, variants, sendAfter, percent, filter)
+	}
+	_, e = tx.Exec(c, query, args...)
 	if e != nil {
 		err = fmt.Errorf("failed to enque delivery task")
 		return
@@ -158,15 +428,35 @@ func EnqueDeliveryTasks(c context.Context, tx pgx.Tx, newsletterIssueId string)
 	return
 }
 
+// sendAfterExpression returns the execute_after of each recipient,
+// recipients without a zone are sent the issue in the zone of the schedule
+func sendAfterExpression(schedule *models.Schedule, args []interface{}) (expression string, extended []interface{}) {
+	extended = args
+	switch {
+	case schedule == nil:
+		expression = "now()"
+	case schedule.LocalTime:
+		extended = append(extended, schedule.SendAt, schedule.Timezone)
+		expression = fmt.Sprintf("($%d::timestamp AT TIME ZONE COALESCE(subscriptions.timezone, $%d))", len(extended)-1, len(extended))
+	default:
+		extended = append(extended, schedule.Instant())
+		expression = fmt.Sprintf("$%d::timestamptz", len(extended))
+	}
+
+	return
+}
+
 // TODO expand confirmation task logic -> new worker pool or mixed concerns?
-func EnqueConfirmationTasks(c context.Context, tx pgx.Tx, subscriberEmail string) (err error) {
-	// base confirmation email == 0
+// suppressed addresses are silently skipped
+func EnqueConfirmationTasks(c context.Context, tx pgx.Tx, subscriberEmail string, list *models.List) (err error) {
 	query := `INSERT INTO issue_delivery_queue (
 				newsletter_issue_id,
+				list_id,
 				subscriber_email
 			)
-			VALUES ($1, $2)`
-	_, e := tx.Exec(c, query, "00000000-0000-0000-0000-000000000000", subscriberEmail)
+			SELECT $1, $2, $3
+			WHERE NOT ` + suppressions.Matches("$3::text")
+	_, e := tx.Exec(c, query, list.ConfirmationIssueID, list.ID, subscriberEmail)
 	if e != nil {
 		err = fmt.Errorf("failed to enque confirmation task: %w", e)
 		return
@@ -174,3 +464,24 @@ func EnqueConfirmationTasks(c context.Context, tx pgx.Tx, subscriberEmail string
 
 	return
 }
+
+// EnqueTransactionalTask queues a one-off email, repeated requests for the
+// same recipient collapse into a single task. Like every other task it is
+// skipped for suppressed addresses.
+func EnqueTransactionalTask(c context.Context, tx pgx.Tx, issueID string, listID string, subscriberEmail string) (err error) {
+	query := `INSERT INTO issue_delivery_queue (
+				newsletter_issue_id,
+				list_id,
+				subscriber_email
+			)
+			SELECT $1, $2, $3
+			WHERE NOT ` + suppressions.Matches("$3::text") + `
+			ON CONFLICT DO NOTHING`
+	_, e := tx.Exec(c, query, issueID, listID, subscriberEmail)
+	if e != nil {
+		err = fmt.Errorf("failed to enque transactional task: %w", e)
+		return
+	}
+
+	return
+}
@@ -6,9 +6,13 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/solomonbaez/hyacinth/api/attachments"
+	"github.com/solomonbaez/hyacinth/api/bounces"
 	"github.com/solomonbaez/hyacinth/api/clients"
+	"github.com/solomonbaez/hyacinth/api/configs"
 	"github.com/solomonbaez/hyacinth/api/handlers"
 	"github.com/solomonbaez/hyacinth/api/idempotency"
+	"github.com/solomonbaez/hyacinth/api/signing"
 )
 
 type ExecutionOutcome int
@@ -19,8 +23,9 @@ const (
 	ExecutionOutcomeTaskCompleted
 )
 
-func DeliveryWorker(c context.Context, dh *handlers.DatabaseHandler, client *clients.SMTPClient) {
+func DeliveryWorker(c context.Context, dh *handlers.DatabaseHandler, client *clients.SMTPClient, signer *signing.Signer, verp *bounces.VERP, frequencyCap *configs.FrequencyCapSettings) {
 	resultChan := make(chan ExecutionOutcome)
+	cache := attachments.NewCache()
 
 	go func() {
 		ticker := time.NewTicker(1 * time.Second)
@@ -33,7 +38,7 @@ func DeliveryWorker(c context.Context, dh *handlers.DatabaseHandler, client *cli
 					Msg("worker exit")
 				return
 			case <-ticker.C:
-				resultChan <- TryExecuteTask(c, dh, client)
+				resultChan <- TryExecuteTask(c, dh, client, signer, verp, frequencyCap, cache)
 			}
 		}
 	}()
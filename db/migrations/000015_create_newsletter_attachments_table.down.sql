@@ -0,0 +1 @@
+DROP TABLE newsletter_attachments;
\ No newline at end of file
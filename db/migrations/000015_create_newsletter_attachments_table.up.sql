@@ -0,0 +1,16 @@
+CREATE TABLE newsletter_attachments(
+    attachment_id uuid NOT NULL,
+    newsletter_issue_id uuid NOT NULL
+        REFERENCES newsletter_issues (newsletter_issue_id),
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    content_id TEXT NULL,
+    inline BOOLEAN NOT NULL,
+    size BIGINT NOT NULL,
+    -- exactly one of content (postgres storage) or path (local storage) is set
+    content BYTEA NULL,
+    path TEXT NULL,
+    created timestamptz NOT NULL,
+    PRIMARY KEY (attachment_id),
+    UNIQUE (newsletter_issue_id, content_id)
+);
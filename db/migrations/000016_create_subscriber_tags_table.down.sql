@@ -0,0 +1 @@
+DROP TABLE subscriber_tags;
\ No newline at end of file
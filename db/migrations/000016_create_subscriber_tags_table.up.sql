@@ -0,0 +1,9 @@
+CREATE TABLE subscriber_tags(
+    subscriber_id uuid NOT NULL
+        REFERENCES subscriptions (id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    created timestamptz NOT NULL,
+    PRIMARY KEY (subscriber_id, tag)
+);
+
+CREATE INDEX subscriber_tags_tag_idx ON subscriber_tags (tag);
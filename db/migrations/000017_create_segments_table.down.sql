@@ -0,0 +1 @@
+DROP TABLE segments;
\ No newline at end of file
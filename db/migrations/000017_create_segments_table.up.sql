@@ -0,0 +1,8 @@
+CREATE TABLE segments(
+    segment_id uuid NOT NULL,
+    name TEXT NOT NULL UNIQUE,
+    match TEXT NOT NULL,
+    rules JSONB NOT NULL,
+    created timestamptz NOT NULL,
+    PRIMARY KEY (segment_id)
+);
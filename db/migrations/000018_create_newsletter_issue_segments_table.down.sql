@@ -0,0 +1 @@
+DROP TABLE newsletter_issue_segments;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE newsletter_issue_segments(
+    newsletter_issue_id uuid NOT NULL
+        REFERENCES newsletter_issues (newsletter_issue_id),
+    segment_id uuid NOT NULL
+        REFERENCES segments (segment_id),
+    PRIMARY KEY (newsletter_issue_id, segment_id)
+);
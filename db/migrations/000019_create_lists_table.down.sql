@@ -0,0 +1 @@
+DROP TABLE lists;
\ No newline at end of file
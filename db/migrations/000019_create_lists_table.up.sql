@@ -0,0 +1,12 @@
+CREATE TABLE lists(
+    list_id uuid NOT NULL,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    -- NULL sender fields fall back to the configured email client sender
+    sender_name TEXT NULL,
+    sender_email TEXT NULL,
+    confirmation_issue_id uuid NOT NULL
+        REFERENCES newsletter_issues (newsletter_issue_id),
+    created timestamptz NOT NULL,
+    PRIMARY KEY (list_id)
+);
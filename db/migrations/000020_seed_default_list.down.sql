@@ -0,0 +1,2 @@
+DELETE FROM lists
+WHERE list_id = '00000000-0000-0000-0000-000000000000'::uuid;
\ No newline at end of file
@@ -0,0 +1,17 @@
+INSERT INTO lists(
+    list_id,
+    slug,
+    name,
+    sender_name,
+    sender_email,
+    confirmation_issue_id,
+    created
+) VALUES (
+    '00000000-0000-0000-0000-000000000000'::uuid,
+    'default',
+    'Newsletter',
+    NULL,
+    NULL,
+    '00000000-0000-0000-0000-000000000000'::uuid,
+    NOW()
+);
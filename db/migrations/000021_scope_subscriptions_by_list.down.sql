@@ -0,0 +1,5 @@
+BEGIN;
+    ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_list_id_email_key;
+    ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_email_key UNIQUE (email);
+    ALTER TABLE subscriptions DROP COLUMN list_id;
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+    ALTER TABLE subscriptions ADD COLUMN list_id uuid NULL
+        REFERENCES lists (list_id);
+    -- Backfill `list_id` for historical entries
+    UPDATE subscriptions
+        SET list_id = '00000000-0000-0000-0000-000000000000'::uuid
+        WHERE list_id IS NULL;
+    ALTER TABLE subscriptions ALTER COLUMN list_id SET NOT NULL;
+    -- an address may subscribe to several lists
+    ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_email_key;
+    ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_list_id_email_key UNIQUE (list_id, email);
+COMMIT;
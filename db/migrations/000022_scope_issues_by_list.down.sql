@@ -0,0 +1,6 @@
+BEGIN;
+    ALTER TABLE issue_delivery_queue DROP CONSTRAINT issue_delivery_queue_pkey;
+    ALTER TABLE issue_delivery_queue ADD PRIMARY KEY (newsletter_issue_id, subscriber_email);
+    ALTER TABLE issue_delivery_queue DROP COLUMN list_id;
+    ALTER TABLE newsletter_issues DROP COLUMN list_id;
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+    -- NULL for templates shared between lists, such as the base confirmation email
+    ALTER TABLE newsletter_issues ADD COLUMN list_id uuid NULL
+        REFERENCES lists (list_id);
+    -- Backfill `list_id` for historical entries
+    UPDATE newsletter_issues
+        SET list_id = '00000000-0000-0000-0000-000000000000'::uuid
+        WHERE newsletter_issue_id <> '00000000-0000-0000-0000-000000000000'::uuid;
+
+    ALTER TABLE issue_delivery_queue ADD COLUMN list_id uuid NULL
+        REFERENCES lists (list_id);
+    -- Backfill `list_id` for historical entries
+    UPDATE issue_delivery_queue
+        SET list_id = '00000000-0000-0000-0000-000000000000'::uuid
+        WHERE list_id IS NULL;
+    ALTER TABLE issue_delivery_queue ALTER COLUMN list_id SET NOT NULL;
+    ALTER TABLE issue_delivery_queue DROP CONSTRAINT issue_delivery_queue_pkey;
+    ALTER TABLE issue_delivery_queue ADD PRIMARY KEY (newsletter_issue_id, list_id, subscriber_email);
+COMMIT;
@@ -0,0 +1 @@
+DROP TABLE subscriber_fields;
\ No newline at end of file
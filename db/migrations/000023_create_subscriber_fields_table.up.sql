@@ -0,0 +1,8 @@
+CREATE TABLE subscriber_fields(
+    field_key TEXT NOT NULL,
+    label TEXT NOT NULL,
+    field_type TEXT NOT NULL
+        CHECK (field_type IN ('string', 'number', 'date', 'boolean')),
+    created timestamptz NOT NULL,
+    PRIMARY KEY (field_key)
+);
@@ -0,0 +1 @@
+ALTER TABLE subscriptions DROP COLUMN attributes;
\ No newline at end of file
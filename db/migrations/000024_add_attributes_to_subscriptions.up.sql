@@ -0,0 +1 @@
+ALTER TABLE subscriptions ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}'::jsonb;
\ No newline at end of file
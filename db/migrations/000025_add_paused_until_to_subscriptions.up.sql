@@ -0,0 +1 @@
+ALTER TABLE subscriptions ADD COLUMN paused_until TIMESTAMPTZ NULL;
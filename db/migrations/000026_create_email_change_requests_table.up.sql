@@ -0,0 +1,11 @@
+CREATE TABLE email_change_requests(
+    change_token TEXT NOT NULL,
+    subscriber_email TEXT NOT NULL,
+    new_email TEXT NOT NULL,
+    created timestamptz NOT NULL,
+    confirmed timestamptz NULL,
+    PRIMARY KEY (change_token)
+);
+
+CREATE INDEX email_change_requests_subscriber_email_idx ON email_change_requests (subscriber_email);
+CREATE INDEX email_change_requests_new_email_idx ON email_change_requests (new_email);
@@ -0,0 +1,5 @@
+DELETE FROM newsletter_issues
+WHERE newsletter_issue_id IN (
+    '00000000-0000-0000-0000-000000000001'::uuid,
+    '00000000-0000-0000-0000-000000000002'::uuid
+);
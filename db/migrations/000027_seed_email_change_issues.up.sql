@@ -0,0 +1,19 @@
+INSERT INTO newsletter_issues(
+    newsletter_issue_id,
+    title,
+    text_content,
+    html_content,
+    published_at
+) VALUES (
+    '00000000-0000-0000-0000-000000000001'::uuid,
+    'Please confirm your new email address',
+    'We received a request to move the subscriptions of {{.email}} to this address. Please confirm the change at: {{.link}}',
+    '<p>We received a request to move the subscriptions of {{.email}} to this address. Please confirm the change at: {{.link}}</p>',
+    NOW()
+), (
+    '00000000-0000-0000-0000-000000000002'::uuid,
+    'Your email address has been changed',
+    'Your subscriptions have been moved to {{.new_email}}. If you did not request this change, please contact us.',
+    '<p>Your subscriptions have been moved to {{.new_email}}. If you did not request this change, please contact us.</p>',
+    NOW()
+);
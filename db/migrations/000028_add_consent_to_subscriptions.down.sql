@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions DROP COLUMN consented_at;
+ALTER TABLE subscriptions DROP COLUMN consent_source;
@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions ADD COLUMN consent_source TEXT NULL;
+ALTER TABLE subscriptions ADD COLUMN consented_at timestamptz NULL;
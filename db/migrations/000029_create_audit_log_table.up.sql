@@ -0,0 +1,11 @@
+CREATE TABLE audit_log(
+    audit_id uuid NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    subscriber_id uuid NULL,
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created timestamptz NOT NULL,
+    PRIMARY KEY (audit_id)
+);
+
+CREATE INDEX audit_log_subscriber_id_idx ON audit_log (subscriber_id, created);
@@ -0,0 +1,8 @@
+CREATE TABLE suppressions(
+    suppression_id uuid NOT NULL,
+    email TEXT NOT NULL UNIQUE,
+    reason TEXT NOT NULL,
+    source TEXT NOT NULL,
+    created timestamptz NOT NULL,
+    PRIMARY KEY (suppression_id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE delivery_log(
+    delivery_id uuid NOT NULL,
+    newsletter_issue_id uuid NOT NULL
+        REFERENCES newsletter_issues (newsletter_issue_id),
+    list_id uuid NOT NULL,
+    subscriber_email TEXT NOT NULL,
+    sent timestamptz NOT NULL,
+    PRIMARY KEY (delivery_id)
+);
+
+CREATE INDEX delivery_log_subscriber_email_idx ON delivery_log (subscriber_email);
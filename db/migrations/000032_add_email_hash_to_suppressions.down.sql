@@ -0,0 +1,6 @@
+BEGIN;
+    DELETE FROM suppressions WHERE email IS NULL;
+    ALTER TABLE suppressions ALTER COLUMN email SET NOT NULL;
+    ALTER TABLE suppressions DROP CONSTRAINT suppressions_email_hash_key;
+    ALTER TABLE suppressions DROP COLUMN email_hash;
+COMMIT;
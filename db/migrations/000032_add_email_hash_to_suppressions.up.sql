@@ -0,0 +1,11 @@
+BEGIN;
+    ALTER TABLE suppressions ADD COLUMN email_hash TEXT NULL;
+    -- Backfill `email_hash` for historical entries, must match models.HashEmail
+    UPDATE suppressions
+        SET email_hash = encode(sha256(convert_to(lower(trim(email)), 'UTF8')), 'hex')
+        WHERE email_hash IS NULL;
+    ALTER TABLE suppressions ALTER COLUMN email_hash SET NOT NULL;
+    ALTER TABLE suppressions ADD CONSTRAINT suppressions_email_hash_key UNIQUE (email_hash);
+    -- erased subscribers leave only their hash behind
+    ALTER TABLE suppressions ALTER COLUMN email DROP NOT NULL;
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+    DROP INDEX IF EXISTS suppressions_created_idx;
+    DELETE FROM suppressions WHERE domain IS NOT NULL;
+    ALTER TABLE suppressions DROP CONSTRAINT suppressions_key_check;
+    ALTER TABLE suppressions ALTER COLUMN email_hash SET NOT NULL;
+    ALTER TABLE suppressions DROP COLUMN domain;
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+    -- suppressions block either a single address or a whole domain
+    ALTER TABLE suppressions ADD COLUMN domain TEXT NULL UNIQUE;
+    ALTER TABLE suppressions ALTER COLUMN email_hash DROP NOT NULL;
+    ALTER TABLE suppressions ADD CONSTRAINT suppressions_key_check
+        CHECK ((email_hash IS NULL) <> (domain IS NULL));
+    UPDATE suppressions SET email = lower(trim(email)) WHERE email IS NOT NULL;
+    CREATE INDEX suppressions_created_idx ON suppressions (created);
+COMMIT;
@@ -0,0 +1,16 @@
+CREATE TABLE bounces(
+    bounce_id uuid NOT NULL,
+    -- Message-ID of the DSN, or a digest when missing, so redelivered DSNs are only counted once
+    message_id TEXT NOT NULL UNIQUE,
+    delivery_id uuid NOT NULL,
+    newsletter_issue_id uuid NOT NULL,
+    list_id uuid NOT NULL,
+    subscriber_email TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    diagnostic TEXT NOT NULL,
+    received timestamptz NOT NULL,
+    PRIMARY KEY (bounce_id)
+);
+
+CREATE INDEX bounces_subscriber_email_idx ON bounces (subscriber_email, received);
@@ -0,0 +1,7 @@
+BEGIN;
+    DELETE FROM bounces WHERE delivery_id IS NULL;
+    ALTER TABLE bounces ALTER COLUMN delivery_id SET NOT NULL;
+    ALTER TABLE bounces ALTER COLUMN newsletter_issue_id SET NOT NULL;
+    ALTER TABLE bounces ALTER COLUMN list_id SET NOT NULL;
+    DROP TABLE IF EXISTS mail_events;
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+    -- provider callbacks are retried, the event ID makes replays harmless
+    CREATE TABLE mail_events(
+        event_id TEXT NOT NULL,
+        type TEXT NOT NULL,
+        received timestamptz NOT NULL,
+        PRIMARY KEY (event_id)
+    );
+
+    -- webhook bounces can not always be attributed to a delivery
+    ALTER TABLE bounces ALTER COLUMN delivery_id DROP NOT NULL;
+    ALTER TABLE bounces ALTER COLUMN newsletter_issue_id DROP NOT NULL;
+    ALTER TABLE bounces ALTER COLUMN list_id DROP NOT NULL;
+COMMIT;
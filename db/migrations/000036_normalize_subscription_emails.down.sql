@@ -0,0 +1,21 @@
+BEGIN;
+    DROP INDEX IF EXISTS issue_delivery_queue_normalized_email_idx;
+    DROP INDEX IF EXISTS bounces_normalized_email_idx;
+    DROP INDEX IF EXISTS delivery_log_normalized_email_idx;
+
+    UPDATE suppressions
+        SET email_hash = encode(sha256(convert_to(lower(trim(email)), 'UTF8')), 'hex')
+        WHERE email IS NOT NULL;
+
+    ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_list_id_normalized_email_key;
+    ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_list_id_email_key UNIQUE (list_id, email);
+    DROP TRIGGER IF EXISTS subscriptions_promote_email_collision ON subscriptions;
+    DROP FUNCTION IF EXISTS promote_email_collision();
+    DROP TRIGGER IF EXISTS subscriptions_normalized_email ON subscriptions;
+    DROP FUNCTION IF EXISTS set_normalized_email();
+    DROP TABLE IF EXISTS email_collisions;
+    ALTER TABLE subscriptions DROP COLUMN normalized_email;
+    DROP FUNCTION IF EXISTS provider_email(TEXT);
+    DROP FUNCTION IF EXISTS normalize_email(TEXT);
+    DROP FUNCTION IF EXISTS ascii_lower(TEXT);
+COMMIT;
@@ -0,0 +1,133 @@
+BEGIN;
+    -- lower() depends on the database collation while Go lowercases by
+    -- Unicode rules, only ASCII letters are folded so both always agree.
+    -- models.LowerASCII must stay in step.
+    CREATE FUNCTION ascii_lower(value TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE AS $$
+        SELECT translate(value, 'ABCDEFGHIJKLMNOPQRSTUVWXYZ', 'abcdefghijklmnopqrstuvwxyz')
+    $$;
+
+    -- the local part is compared by the receiving server, only the domain is
+    -- case insensitive. Provider rules are not part of the key, see
+    -- provider_email. models.NormalizeEmail must stay in step.
+    CREATE FUNCTION normalize_email(email TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE AS $$
+        SELECT COALESCE(substring(trimmed FROM '^(.*)@'), trimmed)
+            || '@' || ascii_lower(COALESCE(substring(trimmed FROM '@([^@]*)$'), ''))
+        FROM (SELECT trim(email) AS trimmed) AS address
+    $$;
+
+    -- mailbox of the address under the rules of providers ignoring dots or
+    -- +tags, only used to report likely duplicates
+    CREATE FUNCTION provider_email(email TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE AS $$
+        SELECT CASE
+            WHEN domain IN ('gmail.com', 'googlemail.com')
+                THEN replace(split_part(ascii_lower(local), '+', 1), '.', '') || '@gmail.com'
+            WHEN domain IN ('outlook.com', 'hotmail.com', 'live.com', 'icloud.com', 'me.com', 'fastmail.com', 'protonmail.com', 'proton.me')
+                THEN split_part(ascii_lower(local), '+', 1) || '@' || domain
+            ELSE normalized
+        END
+        FROM (
+            SELECT normalized,
+                substring(normalized FROM '^(.*)@') AS local,
+                substring(normalized FROM '@([^@]*)$') AS domain
+            FROM (SELECT normalize_email(email) AS normalized) AS address
+        ) AS parts
+    $$;
+
+    ALTER TABLE subscriptions ADD COLUMN normalized_email TEXT NULL;
+    UPDATE subscriptions SET normalized_email = normalize_email(email);
+
+    -- existing duplicates keep their rows, the oldest subscription of each
+    -- address owns the normalized value and the rest are reported here.
+    -- Collisions of a deleted original are handed over by
+    -- promote_email_collision instead of being cascaded.
+    CREATE TABLE email_collisions(
+        subscriber_id uuid NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+        duplicate_of uuid NOT NULL,
+        detected timestamptz NOT NULL,
+        PRIMARY KEY (subscriber_id)
+    );
+    INSERT INTO email_collisions (subscriber_id, duplicate_of, detected)
+        SELECT id, first_value(id) OVER (PARTITION BY list_id, normalized_email ORDER BY created, id), now()
+        FROM subscriptions;
+    DELETE FROM email_collisions WHERE subscriber_id = duplicate_of;
+    UPDATE subscriptions SET normalized_email = NULL
+        WHERE id IN (SELECT subscriber_id FROM email_collisions);
+
+    -- a duplicate moving to another address is no longer a collision
+    CREATE FUNCTION set_normalized_email() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+    BEGIN
+        IF TG_OP = 'UPDATE' AND EXISTS (SELECT 1 FROM email_collisions WHERE subscriber_id = NEW.id) THEN
+            IF normalize_email(NEW.email) = normalize_email(OLD.email) THEN
+                RETURN NEW;
+            END IF;
+            DELETE FROM email_collisions WHERE subscriber_id = NEW.id;
+        END IF;
+
+        NEW.normalized_email := normalize_email(NEW.email);
+        RETURN NEW;
+    END
+    $$;
+    CREATE TRIGGER subscriptions_normalized_email
+        BEFORE INSERT OR UPDATE OF email ON subscriptions
+        FOR EACH ROW EXECUTE FUNCTION set_normalized_email();
+
+    -- the oldest remaining duplicate takes over the normalized value of an
+    -- original that was deleted or moved to another address, so the key
+    -- keeps covering the address
+    CREATE FUNCTION promote_email_collision() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+    DECLARE
+        promoted uuid;
+    BEGIN
+        IF TG_OP = 'UPDATE' AND normalize_email(NEW.email) = normalize_email(OLD.email) THEN
+            RETURN NULL;
+        END IF;
+
+        SELECT email_collisions.subscriber_id INTO promoted
+            FROM email_collisions
+            JOIN subscriptions ON subscriptions.id = email_collisions.subscriber_id
+            WHERE email_collisions.duplicate_of = OLD.id
+            ORDER BY subscriptions.created, subscriptions.id
+            LIMIT 1;
+        IF promoted IS NOT NULL THEN
+            DELETE FROM email_collisions WHERE subscriber_id = promoted;
+            UPDATE email_collisions SET duplicate_of = promoted WHERE duplicate_of = OLD.id;
+            UPDATE subscriptions SET normalized_email = normalize_email(email) WHERE id = promoted;
+        END IF;
+        RETURN NULL;
+    END
+    $$;
+    CREATE TRIGGER subscriptions_promote_email_collision
+        AFTER DELETE OR UPDATE OF email ON subscriptions
+        FOR EACH ROW EXECUTE FUNCTION promote_email_collision();
+
+    DO $$
+    DECLARE
+        collisions INTEGER;
+    BEGIN
+        SELECT COUNT(*) INTO collisions FROM email_collisions;
+        IF collisions > 0 THEN
+            RAISE NOTICE '% subscriptions collide with an existing address, see email_collisions', collisions;
+        END IF;
+    END
+    $$;
+
+    ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_list_id_email_key;
+    ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_list_id_normalized_email_key UNIQUE (list_id, normalized_email);
+
+    -- suppressed addresses are keyed by their normalized email as well, must
+    -- match models.HashEmail
+    UPDATE suppressions
+        SET email_hash = encode(sha256(convert_to(normalize_email(email), 'UTF8')), 'hex')
+        WHERE email IS NOT NULL;
+
+    -- history is looked up by the normalized form of the address it was
+    -- recorded under, so every form of a subscriber's address matches
+    CREATE INDEX delivery_log_normalized_email_idx ON delivery_log (normalize_email(subscriber_email));
+    CREATE INDEX bounces_normalized_email_idx ON bounces (normalize_email(subscriber_email), received);
+    CREATE INDEX issue_delivery_queue_normalized_email_idx ON issue_delivery_queue (normalize_email(subscriber_email));
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+    ALTER TABLE subscriptions DROP COLUMN flag;
+    ALTER TABLE lists DROP COLUMN role_policy;
+    ALTER TABLE lists DROP COLUMN disposable_policy;
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+    -- rules applied to disposable and role account addresses on subscribe
+    ALTER TABLE lists ADD COLUMN disposable_policy TEXT NOT NULL DEFAULT 'allow'
+        CHECK (disposable_policy IN ('allow', 'flag', 'reject'));
+    ALTER TABLE lists ADD COLUMN role_policy TEXT NOT NULL DEFAULT 'allow'
+        CHECK (role_policy IN ('allow', 'flag', 'reject'));
+
+    -- flagged subscriptions are kept for review in the admin
+    ALTER TABLE subscriptions ADD COLUMN flag TEXT
+        CHECK (flag IN ('disposable', 'role'));
+COMMIT;
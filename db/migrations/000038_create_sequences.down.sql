@@ -0,0 +1,7 @@
+BEGIN;
+    DROP INDEX issue_delivery_queue_execute_after_idx;
+    ALTER TABLE issue_delivery_queue DROP COLUMN sequence_id;
+    ALTER TABLE issue_delivery_queue DROP COLUMN execute_after;
+    DROP TABLE sequence_steps;
+    DROP TABLE sequences;
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+    -- automation sequences are delivered to every subscriber confirming to the list
+    CREATE TABLE sequences(
+        sequence_id uuid NOT NULL,
+        list_id uuid NOT NULL
+            REFERENCES lists (list_id) ON DELETE CASCADE,
+        name TEXT NOT NULL,
+        created timestamptz NOT NULL,
+        PRIMARY KEY (sequence_id)
+    );
+
+    -- each step is an issue delivered delay_hours after confirmation
+    CREATE TABLE sequence_steps(
+        sequence_id uuid NOT NULL
+            REFERENCES sequences (sequence_id) ON DELETE CASCADE,
+        position INT NOT NULL,
+        newsletter_issue_id uuid NOT NULL
+            REFERENCES newsletter_issues (newsletter_issue_id),
+        delay_hours INT NOT NULL CHECK (delay_hours >= 0),
+        PRIMARY KEY (sequence_id, position)
+    );
+
+    -- tasks are not delivered before execute_after, deleting a sequence
+    -- drops its pending steps
+    ALTER TABLE issue_delivery_queue ADD COLUMN execute_after timestamptz NOT NULL DEFAULT now();
+    ALTER TABLE issue_delivery_queue ADD COLUMN sequence_id uuid NULL
+        REFERENCES sequences (sequence_id) ON DELETE CASCADE;
+    CREATE INDEX issue_delivery_queue_execute_after_idx ON issue_delivery_queue (execute_after);
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+    DROP TABLE engagement_events;
+    ALTER TABLE subscriptions DROP COLUMN tracking_opt_out;
+    ALTER TABLE newsletter_issues DROP COLUMN track_clicks;
+    ALTER TABLE newsletter_issues DROP COLUMN track_opens;
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+    -- tracking is opted into per issue
+    ALTER TABLE newsletter_issues ADD COLUMN track_opens BOOLEAN NOT NULL DEFAULT false;
+    ALTER TABLE newsletter_issues ADD COLUMN track_clicks BOOLEAN NOT NULL DEFAULT false;
+
+    -- subscribers opting out receive untracked mail on every list
+    ALTER TABLE subscriptions ADD COLUMN tracking_opt_out BOOLEAN NOT NULL DEFAULT false;
+
+    -- opens and clicks attributed through the delivery, url is set for clicks
+    CREATE TABLE engagement_events(
+        event_id uuid NOT NULL,
+        delivery_id uuid NOT NULL,
+        newsletter_issue_id uuid NOT NULL
+            REFERENCES newsletter_issues (newsletter_issue_id),
+        list_id uuid NOT NULL,
+        subscriber_email TEXT NOT NULL,
+        type TEXT NOT NULL CHECK (type IN ('open', 'click')),
+        url TEXT NULL,
+        created timestamptz NOT NULL,
+        PRIMARY KEY (event_id)
+    );
+
+    CREATE INDEX engagement_events_newsletter_issue_id_idx ON engagement_events (newsletter_issue_id);
+    CREATE INDEX engagement_events_subscriber_email_idx ON engagement_events (subscriber_email);
+    -- matched by every stored form of the subscriber's address
+    CREATE INDEX engagement_events_normalized_email_idx ON engagement_events (normalize_email(subscriber_email));
+COMMIT;
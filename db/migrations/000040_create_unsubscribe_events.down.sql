@@ -0,0 +1 @@
+DROP TABLE unsubscribe_events;
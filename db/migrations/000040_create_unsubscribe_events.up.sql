@@ -0,0 +1,15 @@
+BEGIN;
+    -- unsubscribes are attributed to the last issue delivered on the list
+    -- within the attribution window, NULL when there is none
+    CREATE TABLE unsubscribe_events(
+        list_id uuid NOT NULL,
+        subscriber_email TEXT NOT NULL,
+        newsletter_issue_id uuid NULL
+            REFERENCES newsletter_issues (newsletter_issue_id),
+        created timestamptz NOT NULL,
+        PRIMARY KEY (list_id, subscriber_email, created)
+    );
+
+    CREATE INDEX unsubscribe_events_newsletter_issue_id_idx ON unsubscribe_events (newsletter_issue_id);
+    CREATE INDEX unsubscribe_events_subscriber_email_idx ON unsubscribe_events (subscriber_email);
+COMMIT;
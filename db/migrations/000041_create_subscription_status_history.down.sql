@@ -0,0 +1,6 @@
+BEGIN;
+    DROP TRIGGER IF EXISTS subscriptions_status_history_update ON subscriptions;
+    DROP TRIGGER IF EXISTS subscriptions_status_history_insert ON subscriptions;
+    DROP FUNCTION IF EXISTS record_status_change();
+    DROP TABLE IF EXISTS subscription_status_history;
+COMMIT;
@@ -0,0 +1,45 @@
+BEGIN;
+    -- every status a subscription enters, previous_status is NULL when the
+    -- subscription is created. Rows are kept after the subscription is
+    -- deleted so reports do not change retroactively.
+    CREATE TABLE subscription_status_history(
+        subscriber_id uuid NOT NULL,
+        list_id uuid NOT NULL,
+        subscriber_email TEXT NOT NULL,
+        previous_status TEXT NULL,
+        status TEXT NOT NULL,
+        changed timestamptz NOT NULL
+    );
+
+    CREATE INDEX subscription_status_history_changed_idx ON subscription_status_history (changed);
+    CREATE INDEX subscription_status_history_subscriber_email_idx ON subscription_status_history (subscriber_email);
+
+    CREATE FUNCTION record_status_change() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+    BEGIN
+        INSERT INTO subscription_status_history (subscriber_id, list_id, subscriber_email, previous_status, status, changed)
+        VALUES (
+            NEW.id,
+            NEW.list_id,
+            NEW.email,
+            CASE WHEN TG_OP = 'UPDATE' THEN OLD.status END,
+            NEW.status,
+            now()
+        );
+        RETURN NEW;
+    END
+    $$;
+    CREATE TRIGGER subscriptions_status_history_insert
+        AFTER INSERT ON subscriptions
+        FOR EACH ROW EXECUTE FUNCTION record_status_change();
+    CREATE TRIGGER subscriptions_status_history_update
+        AFTER UPDATE OF status ON subscriptions
+        FOR EACH ROW WHEN (OLD.status IS DISTINCT FROM NEW.status)
+        EXECUTE FUNCTION record_status_change();
+
+    -- earlier transitions were not recorded, existing subscriptions enter
+    -- their current status when they were created
+    INSERT INTO subscription_status_history (subscriber_id, list_id, subscriber_email, previous_status, status, changed)
+        SELECT id, list_id, email, NULL, status, created
+        FROM subscriptions;
+COMMIT;
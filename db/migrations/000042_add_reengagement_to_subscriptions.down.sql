@@ -0,0 +1,4 @@
+BEGIN;
+    DROP INDEX IF EXISTS engagement_events_delivery_id_idx;
+    ALTER TABLE subscriptions DROP COLUMN reengagement_sent;
+COMMIT;
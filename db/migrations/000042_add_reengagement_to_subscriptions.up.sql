@@ -0,0 +1,8 @@
+BEGIN;
+    -- set when the sunset policy sends the re-engagement email, cleared
+    -- once the subscriber engages again
+    ALTER TABLE subscriptions ADD COLUMN reengagement_sent timestamptz NULL;
+
+    -- engagement is looked up per delivery when scoring subscribers
+    CREATE INDEX engagement_events_delivery_id_idx ON engagement_events (delivery_id);
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+    ALTER TABLE delivery_log DROP COLUMN variant;
+    ALTER TABLE issue_delivery_queue DROP COLUMN variant;
+    DROP TABLE IF EXISTS subject_variants;
+    DROP TABLE IF EXISTS subject_tests;
+COMMIT;
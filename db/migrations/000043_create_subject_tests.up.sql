@@ -0,0 +1,29 @@
+BEGIN;
+    -- subject lines tested on a sample of the audience, the rest of the
+    -- audience waits in the queue until a winner is picked
+    CREATE TABLE subject_tests(
+        newsletter_issue_id uuid NOT NULL
+            REFERENCES newsletter_issues (newsletter_issue_id),
+        sample_percent INTEGER NOT NULL CHECK (sample_percent BETWEEN 1 AND 90),
+        metric TEXT NOT NULL CHECK (metric IN ('open', 'click')),
+        decide_after timestamptz NOT NULL,
+        winner INTEGER NULL,
+        decided timestamptz NULL,
+        PRIMARY KEY (newsletter_issue_id)
+    );
+
+    CREATE INDEX subject_tests_decide_after_idx ON subject_tests (decide_after) WHERE winner IS NULL;
+
+    CREATE TABLE subject_variants(
+        newsletter_issue_id uuid NOT NULL
+            REFERENCES subject_tests (newsletter_issue_id) ON DELETE CASCADE,
+        variant INTEGER NOT NULL CHECK (variant > 0),
+        subject TEXT NOT NULL,
+        PRIMARY KEY (newsletter_issue_id, variant)
+    );
+
+    -- NULL for issues without a test, and for held back recipients until the
+    -- winner is picked
+    ALTER TABLE issue_delivery_queue ADD COLUMN variant INTEGER NULL;
+    ALTER TABLE delivery_log ADD COLUMN variant INTEGER NULL;
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+    ALTER TABLE newsletter_issues DROP COLUMN IF EXISTS local_time;
+    ALTER TABLE newsletter_issues DROP COLUMN IF EXISTS send_timezone;
+    ALTER TABLE newsletter_issues DROP COLUMN IF EXISTS send_at;
+    ALTER TABLE subscriptions DROP COLUMN IF EXISTS timezone;
+COMMIT;
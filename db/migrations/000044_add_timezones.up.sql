@@ -0,0 +1,11 @@
+BEGIN;
+    -- IANA zone of the subscriber, local time sends fall back to the zone
+    -- of the issue without one
+    ALTER TABLE subscriptions ADD COLUMN timezone TEXT NULL;
+
+    -- scheduled issues keep the wall clock time they were scheduled for,
+    -- local_time sends it in each recipient's zone instead of send_timezone
+    ALTER TABLE newsletter_issues ADD COLUMN send_at timestamp NULL;
+    ALTER TABLE newsletter_issues ADD COLUMN send_timezone TEXT NULL;
+    ALTER TABLE newsletter_issues ADD COLUMN local_time BOOLEAN NOT NULL DEFAULT false;
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+    DELETE FROM newsletter_issues WHERE newsletter_issue_id = '00000000-0000-0000-0000-000000000003'::uuid;
+    DROP TABLE IF EXISTS data_export_requests;
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+    -- short lived links emailed to subscribers asking for their data, the
+    -- permanent preferences link is never enough to download it
+    CREATE TABLE data_export_requests(
+        export_token TEXT NOT NULL,
+        subscriber_id uuid NOT NULL
+            REFERENCES subscriptions (id) ON DELETE CASCADE,
+        created timestamptz NOT NULL,
+        PRIMARY KEY (export_token)
+    );
+
+    CREATE INDEX data_export_requests_subscriber_id_idx ON data_export_requests (subscriber_id);
+
+    INSERT INTO newsletter_issues(
+        newsletter_issue_id,
+        title,
+        text_content,
+        html_content,
+        published_at
+    ) VALUES (
+        '00000000-0000-0000-0000-000000000003'::uuid,
+        'Your data export',
+        'We received a request to download the data stored about {{.email}}. The archive is available for one hour at: {{.link}}',
+        '<p>We received a request to download the data stored about {{.email}}. The archive is available for one hour at: {{.link}}</p>',
+        NOW()
+    );
+COMMIT;
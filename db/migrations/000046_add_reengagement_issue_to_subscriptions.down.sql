@@ -0,0 +1,3 @@
+BEGIN;
+    ALTER TABLE subscriptions DROP COLUMN IF EXISTS reengagement_issue_id;
+COMMIT;
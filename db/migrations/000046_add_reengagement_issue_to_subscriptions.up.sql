@@ -0,0 +1,8 @@
+BEGIN;
+    -- reengagement_sent marks the re-engagement email as queued, the grace
+    -- period is measured from its delivery to the subscriber instead so a
+    -- backed up or rate limited queue does not shorten it
+    ALTER TABLE subscriptions ADD COLUMN reengagement_issue_id uuid NULL
+        REFERENCES newsletter_issues (newsletter_issue_id)
+        ON DELETE SET NULL;
+COMMIT;
@@ -10,16 +10,20 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pashagolub/pgxmock/v3"
 	"github.com/solomonbaez/hyacinth/api/clients"
+	"github.com/solomonbaez/hyacinth/api/configs"
 	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/signing"
 )
 
 type App struct {
-	Recorder *httptest.ResponseRecorder
-	Context  *gin.Context
-	Router   *gin.Engine
-	Database pgxmock.PgxConnIface
-	DH       *handlers.DatabaseHandler
-	Client   *clients.SMTPClient
+	Recorder    *httptest.ResponseRecorder
+	Context     *gin.Context
+	Router      *gin.Engine
+	Database    pgxmock.PgxConnIface
+	DH          *handlers.DatabaseHandler
+	Client      *clients.SMTPClient
+	Attachments *configs.AttachmentSettings
+	Signer      *signing.Signer
 }
 
 func newMockDatabase() (database pgxmock.PgxConnIface) {
@@ -35,17 +39,35 @@ func newMockClient() (client *clients.SMTPClient) {
 	return client
 }
 
+// depends on newMockClient pointing the configuration at dev.yaml
+func newMockAttachmentSettings() (settings *configs.AttachmentSettings) {
+	settings, _ = configs.ConfigureAttachments()
+
+	return settings
+}
+
+// depends on newMockClient pointing the configuration at dev.yaml
+func newMockSigner() (signer *signing.Signer) {
+	settings, _ := configs.ConfigureSigning()
+
+	return signing.NewSigner(settings.Secret, 0)
+}
+
 func NewMockApp() App {
 	var recorder *httptest.ResponseRecorder
 	var context *gin.Context
 	var database pgxmock.PgxConnIface
 	var client *clients.SMTPClient
+	var attachments *configs.AttachmentSettings
+	var signer *signing.Signer
 	var dh *handlers.DatabaseHandler
 	var store cookie.Store
 
 	recorder = httptest.NewRecorder()
 	database = newMockDatabase()
 	client = newMockClient()
+	attachments = newMockAttachmentSettings()
+	signer = newMockSigner()
 	dh = handlers.NewDatabaseHandler(database)
 
 	router := gin.Default()
@@ -56,12 +78,14 @@ func NewMockApp() App {
 	router.Use(sessions.Sessions("test", store))
 
 	return App{
-		Recorder: recorder,
-		Context:  context,
-		Router:   router,
-		Database: database,
-		DH:       dh,
-		Client:   client,
+		Recorder:    recorder,
+		Context:     context,
+		Router:      router,
+		Database:    database,
+		DH:          dh,
+		Client:      client,
+		Attachments: attachments,
+		Signer:      signer,
 	}
 }
 
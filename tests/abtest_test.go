@@ -0,0 +1,169 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/workers"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func subjectTest(metric string, subjects ...string) *models.SubjectTest {
+	test := &models.SubjectTest{SamplePercent: 20, WindowHours: 4, Metric: metric}
+	for _, subject := range subjects {
+		test.Variants = append(test.Variants, &models.SubjectVariant{Subject: subject})
+	}
+
+	return test
+}
+
+func TestParseSubjectTest(t *testing.T) {
+	opens := models.Tracking{Opens: true}
+	testCases := []struct {
+		name     string
+		test     *models.SubjectTest
+		tracking models.Tracking
+		valid    bool
+	}{
+		{"(+) Test case 1 -> two subjects tested on opens -> passes", subjectTest("open", "Hello", "Hi"), opens, true},
+		{"(-) Test case 2 -> a single subject -> fails", subjectTest("open", "Hello"), opens, false},
+		{"(-) Test case 3 -> repeated subject -> fails", subjectTest("open", "Hello", " Hello "), opens, false},
+		{"(-) Test case 4 -> invalid template -> fails", subjectTest("open", "Hello", "Hi {{.name"), opens, false},
+		{"(-) Test case 5 -> clicks tested without click tracking -> fails", subjectTest("click", "Hello", "Hi"), opens, false},
+		{"(-) Test case 6 -> opens tested without tracking -> fails", subjectTest("open", "Hello", "Hi"), models.Tracking{}, false},
+		{"(-) Test case 7 -> too many subjects -> fails", subjectTest("open", "a", "b", "c", "d", "e", "f"), opens, false},
+	}
+
+	for _, tc := range testCases {
+		e := models.ParseSubjectTest(tc.test, tc.tracking)
+		if tc.valid && e != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, e)
+		}
+		if !tc.valid && e == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+
+	sample := subjectTest("open", "Hello", "Hi")
+	sample.SamplePercent = 95
+	if e := models.ParseSubjectTest(sample, opens); e == nil {
+		t.Errorf("Expected a sample above 90 percent to fail")
+	}
+
+	test := subjectTest("open", "Hello", "Hi")
+	if e := models.ParseSubjectTest(test, opens); e != nil || test.Variants[1].Variant != 2 {
+		t.Errorf("Expected variants to be numbered from 1")
+	}
+}
+
+func TestPickWinner(t *testing.T) {
+	test := &models.SubjectTest{Variants: []*models.SubjectVariant{
+		{Variant: 1, Sent: 100, Engaged: 20},
+		{Variant: 2, Sent: 100, Engaged: 35},
+		{Variant: 3, Sent: 100, Engaged: 35},
+	}}
+	if winner := test.PickWinner(); winner != 2 {
+		t.Errorf("Expected variant 2 to win, got: %d", winner)
+	}
+
+	tied := &models.SubjectTest{Variants: []*models.SubjectVariant{{Variant: 1}, {Variant: 2}}}
+	if winner := tied.PickWinner(); winner != 1 {
+		t.Errorf("Expected variant 1 to win a test without deliveries, got: %d", winner)
+	}
+}
+
+func expectSubjectTest(app utils.App, issueID string, decideAfter time.Time) {
+	app.Database.ExpectQuery("SELECT sample_percent, metric, decide_after, winner, decided FROM subject_tests").
+		WithArgs(issueID).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"sample_percent", "metric", "decide_after", "winner", "decided"}).
+				AddRow(20, "open", &decideAfter, (*int)(nil), (*time.Time)(nil)),
+		)
+	app.Database.ExpectQuery("SELECT subject_variants.variant").
+		WithArgs(issueID, "open").
+		WillReturnRows(
+			pgxmock.NewRows([]string{"variant", "subject", "sent", "engaged"}).
+				AddRow(1, "Hello", 50, 10).
+				AddRow(2, "Hi", 50, 20),
+		)
+}
+
+func TestGetSubjectTest(t *testing.T) {
+	issueID := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.GET("/issues/:title/subject-test", func(c *gin.Context) { adminRoutes.GetSubjectTest(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	expectSubjectTest(app, issueID, time.Now())
+
+	request, _ := http.NewRequest("GET", "/issues/"+issueID+"/subject-test", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Fatalf("Expected status code %v, but got %v: %s", http.StatusOK, responseStatus, app.Recorder.Body.String())
+	}
+
+	var body struct {
+		Test models.SubjectTest `json:"test"`
+	}
+	if e := json.Unmarshal(app.Recorder.Body.Bytes(), &body); e != nil {
+		t.Fatalf("Failed to decode subject test: %v", e)
+	}
+	if len(body.Test.Variants) != 2 || body.Test.Variants[1].Rate != 0.4 {
+		t.Errorf("Unexpected variants: %+v", body.Test.Variants)
+	}
+	if body.Test.Winner != nil {
+		t.Errorf("Expected an undecided test, got winner %d", *body.Test.Winner)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestGetSubjectTest_InvalidID(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/issues/:title/subject-test", func(c *gin.Context) { adminRoutes.GetSubjectTest(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	request, _ := http.NewRequest("GET", "/issues/welcome/subject-test", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusNotFound {
+		t.Errorf("Expected status code %v, but got %v", http.StatusNotFound, responseStatus)
+	}
+}
+
+func TestDecideSubjectTests(t *testing.T) {
+	issueID := uuid.NewString()
+
+	app := utils.NewMockApp()
+	defer app.Database.Close(app.Context)
+
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT newsletter_issue_id FROM subject_tests WHERE winner IS NULL").
+		WillReturnRows(pgxmock.NewRows([]string{"newsletter_issue_id"}).AddRow(issueID))
+	expectSubjectTest(app, issueID, time.Now().Add(-time.Hour))
+	app.Database.ExpectExec("UPDATE subject_tests SET winner").
+		WithArgs(issueID, 2).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	app.Database.ExpectExec("UPDATE issue_delivery_queue SET variant").
+		WithArgs(issueID, 2).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 400))
+	app.Database.ExpectCommit()
+
+	if e := workers.DecideSubjectTests(app.Context, app.DH); e != nil {
+		t.Errorf("Failed to decide subject tests: %s", e)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -145,9 +146,13 @@ func TestGetAdminDashboard(t *testing.T) {
 	// initialize
 	app := utils.NewMockApp()
 	admin := app.Router.Group("/admin")
-	admin.GET("/dashboard", adminRoutes.GetAdminDashboard)
+	admin.GET("/dashboard", func(c *gin.Context) { adminRoutes.GetAdminDashboard(c, app.DH) })
 	defer app.Database.Close(app.Context)
 
+	app.Database.ExpectQuery("SELECT (.+) FROM generate_series").
+		WithArgs(models.DefaultReportRange, (*string)(nil)).
+		WillReturnRows(growthRows(time.Now().UTC().Truncate(24 * time.Hour)))
+
 	// this is not a precise mock of the behvior due to param injection
 	// but the end-to-end behavior is exact
 	request, _ := http.NewRequest("GET", "/admin/dashboard", nil)
@@ -0,0 +1,141 @@
+package api_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func expectIssueAnalytics(app utils.App, issueID string) {
+	hour := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	app.Database.ExpectQuery("SELECT title, (.+) FROM newsletter_issues").
+		WithArgs(issueID).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"title", "sent", "bounced", "opens", "clicks", "unsubscribes"}).
+				AddRow("Spring", 10, 2, 4, 2, 1),
+		)
+	app.Database.ExpectQuery("SELECT url, (.+) FROM engagement_events").
+		WithArgs(issueID).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"url", "count", "unique"}).
+				AddRow("https://example.com/post", 3, 2),
+		)
+	app.Database.ExpectQuery("SELECT date_trunc(.+) FROM engagement_events").
+		WithArgs(issueID).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"hour", "opens"}).
+				AddRow(hour, 3).
+				AddRow(hour.Add(time.Hour), 1),
+		)
+}
+
+func TestGetIssueAnalytics(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/issues/:title/analytics", func(c *gin.Context) { adminRoutes.GetIssueAnalytics(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	issueID := uuid.NewString()
+	expectIssueAnalytics(app, issueID)
+
+	request, _ := http.NewRequest("GET", "/issues/"+issueID+"/analytics?format=json", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Fatalf("Expected status code %v, but got %v: %s", http.StatusOK, responseStatus, app.Recorder.Body.String())
+	}
+
+	var body struct {
+		Analytics models.IssueAnalytics `json:"analytics"`
+	}
+	if e := json.Unmarshal(app.Recorder.Body.Bytes(), &body); e != nil {
+		t.Fatalf("Failed to decode analytics: %v", e)
+	}
+
+	analytics := body.Analytics
+	if analytics.Delivered != 8 || analytics.OpenRate != 0.5 || analytics.ClickRate != 0.25 {
+		t.Errorf("Expected delivered and rates to exclude bounces, got: %+v", analytics)
+	}
+	if len(analytics.Links) != 1 || analytics.Links[0].UniqueClicks != 2 || len(analytics.Opens) != 2 {
+		t.Errorf("Expected links and opens series, got: %+v", analytics)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestGetIssueAnalytics_CSV(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/issues/:title/analytics", func(c *gin.Context) { adminRoutes.GetIssueAnalytics(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	issueID := uuid.NewString()
+	expectIssueAnalytics(app, issueID)
+
+	request, _ := http.NewRequest("GET", "/issues/"+issueID+"/analytics?format=csv", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Fatalf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	if disposition := app.Recorder.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment") {
+		t.Errorf("Expected an attachment, got: %s", disposition)
+	}
+
+	records, e := csv.NewReader(app.Recorder.Body).ReadAll()
+	if e != nil {
+		t.Fatalf("Failed to parse CSV: %v", e)
+	}
+	// header, six summary rows, one link and two hours
+	if len(records) != 10 {
+		t.Fatalf("Expected 10 records, got: %v", records)
+	}
+	if link := records[7]; link[0] != "link" || link[1] != "https://example.com/post" || link[2] != "3" || link[3] != "2" {
+		t.Errorf("Unexpected link record: %v", link)
+	}
+	if opens := records[8]; opens[0] != "opens" || opens[1] != "2024-03-01T09:00:00Z" || opens[2] != "3" {
+		t.Errorf("Unexpected opens record: %v", opens)
+	}
+}
+
+func TestGetIssueAnalytics_NotFound(t *testing.T) {
+	testCases := []struct {
+		name    string
+		issueID string
+	}{
+		{"(-) Test case 1 -> invalid ID -> not found", "spring-issue"},
+		{"(-) Test case 2 -> unknown issue -> not found", uuid.NewString()},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.GET("/issues/:title/analytics", func(c *gin.Context) { adminRoutes.GetIssueAnalytics(c, app.DH) })
+
+		if _, e := uuid.Parse(tc.issueID); e == nil {
+			app.Database.ExpectQuery("SELECT title, (.+) FROM newsletter_issues").
+				WithArgs(tc.issueID).
+				WillReturnError(pgx.ErrNoRows)
+		}
+
+		request, _ := http.NewRequest("GET", "/issues/"+tc.issueID+"/analytics?format=json", nil)
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusNotFound {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, http.StatusNotFound, responseStatus)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
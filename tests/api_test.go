@@ -5,12 +5,14 @@ import (
 	"net/http"
 
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pashagolub/pgxmock/v3"
 
 	"github.com/solomonbaez/hyacinth/api/handlers"
@@ -75,18 +77,19 @@ func TestGetSubscribers(t *testing.T) {
 			"(+) Test case 1 -> GET request to /subscribers with no subscribers -> passes",
 			false,
 			http.StatusOK,
-			`{"requestID":"","subscribers":[]}`,
+			`{"next_cursor":"","requestID":"","subscribers":[],"total":0}`,
 		},
 		{
 			"(+) Test case 2 -> GET request to /subscribers with subscribers -> passes",
 			true,
 			http.StatusOK,
 			fmt.Sprintf(
-				`{"requestID":"","subscribers":[{"id":"%s","email":"%s","name":"%s","status":"%s"}]}`,
+				`{"next_cursor":"","requestID":"","subscribers":[{"id":"%s","email":"%s","name":"%s","status":"%s","created":"%s"}],"total":1}`,
 				seedSubscriber.id,
 				seedSubscriber.email,
 				seedSubscriber.name,
 				seedSubscriber.status,
+				seedSubscriber.created.Format(time.RFC3339Nano),
 			),
 		},
 	}
@@ -101,8 +104,16 @@ func TestGetSubscribers(t *testing.T) {
 
 		request, _ := http.NewRequest("GET", "/admin/subscribers", nil)
 
+		total := 0
 		if tc.subscribers {
-			app.Database.ExpectQuery(`SELECT \* FROM subscriptions`).
+			total = 1
+		}
+		app.Database.ExpectQuery(`SELECT count\(\*\) FROM subscriptions`).
+			WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(total))
+
+		if tc.subscribers {
+			app.Database.ExpectQuery(`SELECT id, email, name, created, status FROM subscriptions`).
+				WithArgs(models.DefaultPageSize + 1).
 				WillReturnRows(
 					pgxmock.NewRows([]string{"id", "email", "name", "created", "status"}).
 						AddRow(
@@ -114,7 +125,8 @@ func TestGetSubscribers(t *testing.T) {
 						),
 				)
 		} else {
-			app.Database.ExpectQuery(`SELECT \* FROM subscriptions`).
+			app.Database.ExpectQuery(`SELECT id, email, name, created, status FROM subscriptions`).
+				WithArgs(models.DefaultPageSize + 1).
 				WillReturnRows(
 					pgxmock.NewRows([]string{"id", "email", "name", "created", "status"}),
 				)
@@ -186,7 +198,7 @@ func TestGetConfirmedSubscribers(t *testing.T) {
 	responseArray := adminRoutes.GetConfirmedSubscribers(app.Context, app.DH)
 	defer app.Database.ExpectationsWereMet()
 
-	if *responseArray[0] != *test.expectedArray[0] {
+	if !reflect.DeepEqual(*responseArray[0], *test.expectedArray[0]) {
 		t.Errorf("Expected array: %v, got: %v", *test.expectedArray[0], *responseArray[0])
 	}
 }
@@ -345,18 +357,21 @@ func TestPostSubscribe(t *testing.T) {
 		for _, d := range tc.data {
 			// initialization
 			app := utils.NewMockApp()
-			app.Router.POST("/subscribe", func(c *gin.Context) { routes.Subscribe(c, app.DH) })
+			app.Router.POST("/subscribe", func(c *gin.Context) { routes.Subscribe(c, app.DH, nil) })
 			request, _ := http.NewRequest("POST", "/subscribe", strings.NewReader(d))
 
 			app.Database.ExpectBegin()
+			app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+				WithArgs("default").
+				WillReturnRows(defaultListRows())
 			app.Database.ExpectExec("INSERT INTO subscriptions").
-				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), (*string)(nil), (*string)(nil)).
 				WillReturnResult(pgxmock.NewResult("INSERT", 1))
 			app.Database.ExpectExec("INSERT INTO subscription_tokens").
 				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
 				WillReturnResult(pgxmock.NewResult("INSERT", 1))
 			app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
-				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
 				WillReturnResult(pgxmock.NewResult("INSERT", 1))
 			app.Database.ExpectCommit()
 
@@ -421,9 +436,14 @@ func TestConfirmSubscriber(t *testing.T) {
 			query.WillReturnError(errors.New("invalid token"))
 		}
 
-		app.Database.ExpectExec(`UPDATE subscriptions SET status = 'confirmed' WHERE`).
+		app.Database.ExpectBegin()
+		app.Database.ExpectQuery(`UPDATE subscriptions SET status = 'confirmed'`).
 			WithArgs(pgxmock.AnyArg()).
-			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			WillReturnRows(pgxmock.NewRows([]string{"status"}).AddRow("pending"))
+		app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
+			WithArgs(pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+		app.Database.ExpectCommit()
 
 		app.NewMockRequest(request)
 		defer app.Database.ExpectationsWereMet()
@@ -439,3 +459,28 @@ func TestConfirmSubscriber(t *testing.T) {
 		}
 	}
 }
+
+func TestSubscribe_DuplicateEmail(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.POST("/subscribe", func(c *gin.Context) { routes.Subscribe(c, app.DH, nil) })
+	defer app.Database.Close(app.Context)
+
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+		WithArgs("default").
+		WillReturnRows(defaultListRows())
+	app.Database.ExpectExec("INSERT INTO subscriptions").
+		WithArgs(pgxmock.AnyArg(), "User@Example.com", "user", "pending", pgxmock.AnyArg(), pgxmock.AnyArg(), (*string)(nil), (*string)(nil)).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "subscriptions_list_id_normalized_email_key"})
+	app.Database.ExpectRollback()
+
+	request, _ := http.NewRequest("POST", "/subscribe", strings.NewReader(`{"email": "User@Example.com", "name": "user"}`))
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusConflict {
+		t.Errorf("Expected status code %v, but got %v", http.StatusConflict, responseStatus)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -0,0 +1,211 @@
+package api_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	mock "github.com/mocktools/go-smtp-mock"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/solomonbaez/hyacinth/api/attachments"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/models"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+// minimal PNG signature, sufficient for content sniffing
+var pngContent = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+var attachmentSettings = &configs.AttachmentSettings{
+	Storage:      configs.AttachmentStoragePostgres,
+	MaxFileSize:  64,
+	MaxTotalSize: 96,
+	AllowedTypes: []string{"image/png", "text/plain"},
+}
+
+func newMultipartForm(t *testing.T, files map[string][][2]string) *multipart.Form {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	for field, entries := range files {
+		for _, entry := range entries {
+			part, _ := writer.CreateFormFile(field, entry[0])
+			part.Write([]byte(entry[1]))
+		}
+	}
+	writer.Close()
+
+	request, _ := http.NewRequest("POST", "/", &buffer)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	if e := request.ParseMultipartForm(1 << 20); e != nil {
+		t.Fatalf("Failed to parse multipart form: %s", e)
+	}
+
+	return request.MultipartForm
+}
+
+func TestParseFilename(t *testing.T) {
+	testCases := []string{
+		"", " ", ".", "..", "/", "a\"b.png", "a\r\nb.png", strings.Repeat("a", 256),
+	}
+
+	for _, tc := range testCases {
+		if s, e := models.ParseFilename(tc); e == nil {
+			t.Errorf("Expected %q to be rejected, got: %s", tc, s)
+		}
+	}
+
+	if s, e := models.ParseFilename("../../etc/logo.png"); e != nil || s != "logo.png" {
+		t.Errorf("Expected logo.png, got: %s, %v", s, e)
+	}
+}
+
+func TestParseUploads(t *testing.T) {
+	testCases := []struct {
+		name          string
+		files         map[string][][2]string
+		expectedCount int
+		expectedError bool
+	}{
+		{
+			"(+) Test case 1 -> attachment and inline image -> passes",
+			map[string][][2]string{
+				attachments.AttachmentField: {{"notes.txt", "hello"}},
+				attachments.InlineField:     {{"logo.png", string(pngContent)}},
+			},
+			2,
+			false,
+		},
+		{
+			"(-) Test case 2 -> disallowed content type -> fails",
+			map[string][][2]string{
+				attachments.AttachmentField: {{"page.html", "<html><body></body></html>"}},
+			},
+			0,
+			true,
+		},
+		{
+			"(-) Test case 3 -> inline content that is not an image -> fails",
+			map[string][][2]string{
+				attachments.InlineField: {{"notes.txt", "hello"}},
+			},
+			0,
+			true,
+		},
+		{
+			"(-) Test case 4 -> file exceeding maximum size -> fails",
+			map[string][][2]string{
+				attachments.AttachmentField: {{"notes.txt", strings.Repeat("a", 65)}},
+			},
+			0,
+			true,
+		},
+		{
+			"(-) Test case 5 -> uploads exceeding maximum total size -> fails",
+			map[string][][2]string{
+				attachments.AttachmentField: {
+					{"a.txt", strings.Repeat("a", 60)},
+					{"b.txt", strings.Repeat("b", 60)},
+				},
+			},
+			0,
+			true,
+		},
+		{
+			"(-) Test case 6 -> duplicate inline content ID -> fails",
+			map[string][][2]string{
+				attachments.InlineField: {
+					{"logo.png", string(pngContent)},
+					{"logo.png", string(pngContent)},
+				},
+			},
+			0,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		form := newMultipartForm(t, tc.files)
+
+		uploads, e := attachments.ParseUploads(form, attachmentSettings)
+		if (e != nil) != tc.expectedError {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+			continue
+		}
+		if len(uploads) != tc.expectedCount {
+			t.Errorf("%s: expected %d uploads, got %d", tc.name, tc.expectedCount, len(uploads))
+		}
+	}
+}
+
+func TestMockEmail_Attachments_Passes(t *testing.T) {
+	cfg := mock.ConfigurationAttr{}
+	server := mock.New(cfg)
+	server.Start()
+	port := server.PortNumber
+	defer server.Stop()
+
+	client := mockClient
+	client.SmtpPort = port
+
+	sender := models.SubscriberEmail("user@example.com")
+	client.Sender = &sender
+
+	body := models.Body{
+		Title: "testing",
+		Text:  "testing",
+		Html:  `<p>testing</p><img src="cid:logo.png">`,
+	}
+
+	recipient := models.SubscriberEmail("test@example.com")
+	emailContent := models.Newsletter{
+		Recipient: recipient,
+		Content:   &body,
+		Attachments: []*models.Attachment{
+			{Filename: "notes.txt", ContentType: "text/plain", Content: []byte("hello")},
+			{Filename: "logo.png", ContentType: "image/png", ContentID: "logo.png", Inline: true, Content: pngContent},
+		},
+	}
+
+	if e := client.SendEmail(&emailContent); e != nil {
+		t.Errorf("Failed to send email: %s", e)
+		return
+	}
+}
+
+func TestAttachmentCache(t *testing.T) {
+	app := utils.NewMockApp()
+	defer app.Database.Close(app.Context)
+
+	issueID := uuid.NewString()
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT (.+) FROM newsletter_attachments").
+		WithArgs(issueID).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"attachment_id", "filename", "content_type", "content_id", "inline", "content", "path"}).
+				AddRow(uuid.NewString(), "notes.txt", "text/plain", (*string)(nil), false, []byte("hello"), (*string)(nil)),
+		)
+
+	tx, e := app.Database.Begin(app.Context)
+	if e != nil {
+		t.Fatalf("Failed to begin transaction: %s", e)
+	}
+
+	// the second recipient of the issue is served from memory
+	cache := attachments.NewCache()
+	for i := 0; i < 2; i++ {
+		loaded, e := cache.Get(app.Context, tx, issueID)
+		if e != nil {
+			t.Fatalf("Failed to load attachments: %s", e)
+		}
+		if len(loaded) != 1 || string(loaded[0].Content) != "hello" {
+			t.Errorf("Unexpected attachments: %v", loaded)
+		}
+	}
+
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
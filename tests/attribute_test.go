@@ -0,0 +1,186 @@
+package api_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/segments"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+var testFieldDefinitions = []*models.FieldDefinition{
+	{Key: "company", Label: "Company", Type: models.FieldTypeString},
+	{Key: "seats", Label: "Seats", Type: models.FieldTypeNumber},
+	{Key: "renewal", Label: "Renewal", Type: models.FieldTypeDate},
+	{Key: "trial", Label: "Trial", Type: models.FieldTypeBoolean},
+}
+
+func TestParseAttributes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		raw           map[string]interface{}
+		expectedError bool
+	}{
+		{
+			"(+) Test case 1 -> native values -> passes",
+			map[string]interface{}{"company": "Acme", "seats": float64(12), "renewal": "2024-03-01", "trial": true},
+			false,
+		},
+		{
+			"(+) Test case 2 -> form encoded values -> passes",
+			map[string]interface{}{"seats": "12", "trial": "false"},
+			false,
+		},
+		{
+			"(+) Test case 3 -> null clears value -> passes",
+			map[string]interface{}{"company": nil},
+			false,
+		},
+		{
+			"(-) Test case 4 -> unknown field -> fails",
+			map[string]interface{}{"plan": "pro"},
+			true,
+		},
+		{
+			"(-) Test case 5 -> invalid number -> fails",
+			map[string]interface{}{"seats": "many"},
+			true,
+		},
+		{
+			"(-) Test case 6 -> invalid date -> fails",
+			map[string]interface{}{"renewal": "next week"},
+			true,
+		},
+		{
+			"(-) Test case 7 -> markup in string -> fails",
+			map[string]interface{}{"company": "<b>Acme</b>"},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		if _, e := models.ParseAttributes(tc.raw, testFieldDefinitions); (e != nil) != tc.expectedError {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+		}
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	body := &models.Body{
+		Title: "Hello {{.name}}",
+		Text:  "Plan for {{.attributes.company}}{{.attributes.missing}}",
+		Html:  "<p>{{.attributes.company}}</p>",
+	}
+	attributes := models.Attributes{"company": "Acme & Co", "seats": float64(12)}
+	data := models.NewTemplateData("user@example.com", "user", attributes)
+
+	rendered, e := models.RenderBody(body, data)
+	if e != nil {
+		t.Fatalf("Failed to render body: %s", e)
+	}
+
+	if rendered.Title != "Hello user" {
+		t.Errorf("Expected rendered title, got: %s", rendered.Title)
+	}
+	if rendered.Text != "Plan for Acme & Co" {
+		t.Errorf("Expected missing attributes to render empty, got: %s", rendered.Text)
+	}
+	if rendered.Html != "<p>Acme &amp; Co</p>" {
+		t.Errorf("Expected escaped html, got: %s", rendered.Html)
+	}
+
+	invalid := &models.Body{Title: "{{.name", Text: "text", Html: "html"}
+	if e := models.ParseTemplates(invalid); e == nil {
+		t.Errorf("Failed to reject invalid template")
+	}
+}
+
+func TestAttributeRecipientFilter(t *testing.T) {
+	audience := []*models.Segment{
+		{
+			Name:  "large accounts",
+			Match: models.SegmentMatchAll,
+			Rules: []*models.SegmentRule{
+				{Field: "attribute", Key: "seats", Operator: "greater_than", Value: "10"},
+				{Field: "attribute", Key: "trial", Operator: "is_not_set"},
+			},
+		},
+	}
+	for _, segment := range audience {
+		if e := models.ParseSegment(segment); e != nil {
+			t.Fatalf("Failed to parse segment: %s", e)
+		}
+	}
+
+	filter, args, e := segments.RecipientFilter("list", audience, []interface{}{"issue"})
+	if e != nil {
+		t.Fatalf("Failed to build filter: %s", e)
+	}
+
+	if len(args) != 5 || args[2] != "seats" || args[3] != "10" || args[4] != "trial" {
+		t.Errorf("Unexpected arguments: %v", args)
+	}
+	for _, expected := range []string{"jsonb_typeof", "::numeric", "NOT subscriptions.attributes ? $5::text"} {
+		if !strings.Contains(filter, expected) {
+			t.Errorf("Expected filter to contain %q, got: %s", expected, filter)
+		}
+	}
+
+	invalid := &models.Segment{
+		Name:  "invalid",
+		Rules: []*models.SegmentRule{{Field: "attribute", Key: "seats", Operator: "greater_than", Value: "many"}},
+	}
+	if e := models.ParseSegment(invalid); e == nil {
+		t.Errorf("Failed to reject non-numeric comparison")
+	}
+}
+
+func TestPutSubscriberAttributes(t *testing.T) {
+	id := uuid.NewString()
+
+	testCases := []struct {
+		name           string
+		data           string
+		expectedStatus int
+	}{
+		{
+			"(+) Test case 1 -> valid attributes -> passes",
+			`{"company": "Acme", "seats": 12}`,
+			http.StatusOK,
+		},
+		{
+			"(-) Test case 2 -> unknown field -> fails",
+			`{"plan": "pro"}`,
+			http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.PUT("/subscribers/:id/attributes", func(c *gin.Context) { adminRoutes.PutSubscriberAttributes(c, app.DH) })
+		request, _ := http.NewRequest("PUT", "/subscribers/"+id+"/attributes", strings.NewReader(tc.data))
+
+		rows := pgxmock.NewRows([]string{"field_key", "label", "field_type"})
+		for _, definition := range testFieldDefinitions {
+			rows.AddRow(definition.Key, definition.Label, definition.Type)
+		}
+		app.Database.ExpectQuery("SELECT field_key, label, field_type FROM subscriber_fields").
+			WillReturnRows(rows)
+		app.Database.ExpectExec("UPDATE subscriptions").
+			WithArgs(id, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != tc.expectedStatus {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, tc.expectedStatus, responseStatus)
+		}
+	}
+}
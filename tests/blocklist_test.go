@@ -0,0 +1,211 @@
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/blocklist"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/routes"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func testBlocklist(t *testing.T, entries string) (list *blocklist.Blocklist, path string) {
+	path = filepath.Join(t.TempDir(), "blocklist.txt")
+	if e := os.WriteFile(path, []byte(entries), 0600); e != nil {
+		t.Fatalf("Failed to write blocklist: %s", e)
+	}
+
+	list, e := blocklist.NewBlocklist(path)
+	if e != nil {
+		t.Fatalf("Failed to load blocklist: %s", e)
+	}
+
+	return
+}
+
+func TestBlocklistMatch(t *testing.T) {
+	list, path := testBlocklist(t, "# disposable\nmailinator.com\nmüll.de\n\nNoReply@\nadmin@\n")
+
+	testCases := []struct {
+		email    models.SubscriberEmail
+		expected string
+	}{
+		{"user@mailinator.com", models.BlockReasonDisposable},
+		{"user@eu.MAILINATOR.com", models.BlockReasonDisposable},
+		{"noreply@example.com", models.BlockReasonRole},
+		{"Admin+news@example.com", models.BlockReasonRole},
+		{"user@example.com", ""},
+		{"user@notmailinator.com", ""},
+		// only ASCII letters are folded, as in normalized addresses
+		{"user@Müll.DE", models.BlockReasonDisposable},
+		{"user@MÜLL.de", ""},
+	}
+	for _, tc := range testCases {
+		if reason := list.Match(tc.email); reason != tc.expected {
+			t.Errorf("Expected %s to match %q, got: %q", tc.email, tc.expected, reason)
+		}
+	}
+
+	// invalid edits keep the loaded entries
+	os.WriteFile(path, []byte("not a domain\n"), 0600)
+	if e := list.Reload(); e == nil {
+		t.Errorf("Expected invalid blocklist to be rejected")
+	}
+	if reason := list.Match("user@mailinator.com"); reason != models.BlockReasonDisposable {
+		t.Errorf("Expected entries to survive a failed reload, got: %q", reason)
+	}
+
+	os.WriteFile(path, []byte("yopmail.com\n"), 0600)
+	if e := list.Reload(); e != nil {
+		t.Fatalf("Failed to reload blocklist: %s", e)
+	}
+	if list.Match("user@mailinator.com") != "" || list.Match("user@yopmail.com") != models.BlockReasonDisposable {
+		t.Errorf("Expected reload to replace the entries")
+	}
+
+	var disabled *blocklist.Blocklist
+	if reason := disabled.Match("user@mailinator.com"); reason != "" {
+		t.Errorf("Expected a nil blocklist to match nothing, got: %q", reason)
+	}
+}
+
+func TestParseListRules(t *testing.T) {
+	rules := models.ListRules{RolePolicy: models.BlockPolicyFlag}
+	if e := models.ParseListRules(&rules); e != nil {
+		t.Fatalf("Failed to parse list rules: %s", e)
+	}
+	if rules.DisposablePolicy != models.BlockPolicyAllow {
+		t.Errorf("Expected missing policy to default to %s, got: %s", models.BlockPolicyAllow, rules.DisposablePolicy)
+	}
+
+	invalid := models.ListRules{DisposablePolicy: "block"}
+	if e := models.ParseListRules(&invalid); e == nil {
+		t.Errorf("Expected invalid policy to be rejected")
+	}
+}
+
+func TestSubscribe_Blocklisted(t *testing.T) {
+	list, _ := testBlocklist(t, "mailinator.com\nadmin@\n")
+	flag := models.BlockReasonRole
+
+	testCases := []struct {
+		name           string
+		email          string
+		expectedStatus int
+		expect         func(app utils.App)
+	}{
+		{
+			"(-) Test case 1 -> rejected disposable address -> fails",
+			"user@mailinator.com",
+			http.StatusUnprocessableEntity,
+			func(app utils.App) {
+				app.Database.ExpectRollback()
+			},
+		},
+		{
+			"(+) Test case 2 -> flagged role account -> passes",
+			"admin@example.com",
+			http.StatusCreated,
+			func(app utils.App) {
+				app.Database.ExpectExec("INSERT INTO subscriptions").
+					WithArgs(pgxmock.AnyArg(), "admin@example.com", "user", "pending", pgxmock.AnyArg(), pgxmock.AnyArg(), &flag, (*string)(nil)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				app.Database.ExpectExec("INSERT INTO subscription_tokens").
+					WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
+					WithArgs(lists.DefaultListID, lists.DefaultListID, "admin@example.com").
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				app.Database.ExpectCommit()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.POST("/subscribe", func(c *gin.Context) { routes.Subscribe(c, app.DH, list) })
+
+		app.Database.ExpectBegin()
+		app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+			WithArgs("default").
+			WillReturnRows(
+				pgxmock.NewRows([]string{"list_id", "slug", "name", "sender_name", "sender_email", "confirmation_issue_id", "disposable_policy", "role_policy"}).
+					AddRow(lists.DefaultListID, "default", "Newsletter", nil, nil, lists.BaseConfirmationIssueID, models.BlockPolicyReject, models.BlockPolicyFlag),
+			)
+		tc.expect(app)
+
+		data := `{"email": "` + tc.email + `", "name": "user"}`
+		request, _ := http.NewRequest("POST", "/subscribe", strings.NewReader(data))
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != tc.expectedStatus {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, tc.expectedStatus, responseStatus)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
+
+func TestPutListRules(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.PUT("/lists/:id/rules", func(c *gin.Context) { adminRoutes.PutListRules(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	app.Database.ExpectBegin()
+	app.Database.ExpectExec("UPDATE lists SET disposable_policy").
+		WithArgs(lists.DefaultListID, models.BlockPolicyReject, models.BlockPolicyAllow).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	app.Database.ExpectCommit()
+
+	data := `{"disposable_policy": "reject"}`
+	request, _ := http.NewRequest("PUT", "/lists/"+lists.DefaultListID+"/rules", strings.NewReader(data))
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestGetFlaggedSubscribers(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/subscribers/flagged", func(c *gin.Context) { adminRoutes.GetFlaggedSubscribers(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	id := uuid.NewString()
+	app.Database.ExpectQuery("SELECT (.+) FROM subscriptions WHERE flag IS NOT NULL").
+		WillReturnRows(
+			pgxmock.NewRows([]string{"id", "email", "name", "status", "list_id", "flag", "created"}).
+				AddRow(id, models.SubscriberEmail("user@mailinator.com"), models.SubscriberName("user"), "pending", lists.DefaultListID, models.BlockReasonDisposable, time.Now()),
+		)
+
+	request, _ := http.NewRequest("GET", "/subscribers/flagged", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	body := app.Recorder.Body.String()
+	for _, expected := range []string{id, "user@mailinator.com", models.BlockReasonDisposable} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected response to contain %q, got: %s", expected, body)
+		}
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
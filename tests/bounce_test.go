@@ -0,0 +1,377 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	mock "github.com/mocktools/go-smtp-mock"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/bounces"
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	"github.com/solomonbaez/hyacinth/api/workers"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func testBounceSettings() *configs.BounceSettings {
+	return &configs.BounceSettings{
+		Domain:        "bounces.example.com",
+		Prefix:        "bounces",
+		Format:        configs.BounceFormatMaildir,
+		Interval:      60,
+		HardThreshold: 1,
+		SoftThreshold: 3,
+		SoftWindow:    30,
+	}
+}
+
+func testDSN(to string, action string, status string) string {
+	return "From: MAILER-DAEMON@mx.example.org\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: Undelivered Mail Returned to Sender\r\n" +
+		"Message-ID: <dsn-" + status + "@mx.example.org>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=delivery-status; boundary=\"BOUNDARY\"\r\n" +
+		"\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Your message could not be delivered.\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: message/delivery-status\r\n" +
+		"\r\n" +
+		"Reporting-MTA: dns; mx.example.org\r\n" +
+		"\r\n" +
+		"Final-Recipient: rfc822; user@example.com\r\n" +
+		"Action: " + action + "\r\n" +
+		"Status: " + status + "\r\n" +
+		"Diagnostic-Code: smtp; 550 " + status + " mailbox unavailable\r\n" +
+		"\r\n" +
+		"--BOUNDARY--\r\n"
+}
+
+func TestVERP(t *testing.T) {
+	app := utils.NewMockApp()
+	verp := bounces.NewVERP(testBounceSettings(), app.Signer)
+	deliveryID := uuid.NewString()
+
+	address := verp.Address(deliveryID)
+	if _, e := models.ParseEmail(address.String()); e != nil {
+		t.Errorf("Expected a valid email address, got: %s", address)
+	}
+	if local, _, _ := strings.Cut(address.String(), "@"); len(local) > 64 {
+		t.Errorf("Expected local part within 64 characters, got: %d", len(local))
+	}
+
+	for _, candidate := range []string{address.String(), strings.ToUpper(address.String())} {
+		if parsed, e := verp.Parse(candidate); e != nil || parsed != deliveryID {
+			t.Errorf("Expected delivery %s from %s, got: %s, %v", deliveryID, candidate, parsed, e)
+		}
+	}
+
+	local, _, _ := strings.Cut(address.String(), "@")
+	forged := bounces.NewVERP(testBounceSettings(), signing.NewSigner("forged", 0)).Address(deliveryID)
+	invalid := []string{
+		"user@example.com",
+		local + "@other.example.com",
+		forged.String(),
+		strings.Replace(address.String(), strings.ReplaceAll(deliveryID, "-", "")[:4], "0000", 1),
+	}
+	for _, candidate := range invalid {
+		if _, e := verp.Parse(candidate); !errors.Is(e, bounces.ErrInvalidVERP) {
+			t.Errorf("Expected %s to be rejected, got: %v", candidate, e)
+		}
+	}
+
+	settings := testBounceSettings()
+	settings.Domain = ""
+	if bounces.NewVERP(settings, app.Signer) != nil {
+		t.Errorf("Expected VERP to be disabled without a domain")
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	testCases := []struct {
+		name          string
+		message       string
+		expectedKind  string
+		expectedError error
+	}{
+		{"(+) Test case 1 -> unknown user -> hard", testDSN("bounces@example.com", "failed", "5.1.1"), models.BounceKindHard, nil},
+		{"(+) Test case 2 -> mailbox full -> soft", testDSN("bounces@example.com", "failed", "5.2.2"), models.BounceKindSoft, nil},
+		{"(+) Test case 3 -> delayed -> soft", testDSN("bounces@example.com", "delayed", "4.4.1"), models.BounceKindSoft, nil},
+		{"(-) Test case 4 -> relayed -> not a bounce", testDSN("bounces@example.com", "relayed", "2.0.0"), "", bounces.ErrNotBounce},
+		{"(-) Test case 5 -> plain message -> not a dsn", "From: user@example.com\r\nSubject: hi\r\n\r\nhello\r\n", "", bounces.ErrNotDSN},
+	}
+
+	for _, tc := range testCases {
+		dsn, e := bounces.ParseDSN(strings.NewReader(tc.message))
+		if e == nil {
+			_, e = bounces.Classify(dsn)
+		}
+		if !errors.Is(e, tc.expectedError) {
+			t.Errorf("%s: expected error %v, got: %v", tc.name, tc.expectedError, e)
+			continue
+		}
+		if tc.expectedError != nil {
+			continue
+		}
+
+		kind, _ := bounces.Classify(dsn)
+		if kind != tc.expectedKind || len(dsn.Recipients) != 1 || !strings.HasPrefix(dsn.MessageID, "<dsn-") {
+			t.Errorf("%s: unexpected dsn: %s, %+v", tc.name, kind, dsn)
+		}
+		if !strings.Contains(dsn.Diagnostic, "mailbox unavailable") {
+			t.Errorf("%s: expected diagnostic, got: %s", tc.name, dsn.Diagnostic)
+		}
+	}
+}
+
+func TestReadBounceSources(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		os.MkdirAll(filepath.Join(dir, sub), 0o755)
+	}
+	os.WriteFile(filepath.Join(dir, "new", "1"), []byte(testDSN("a@example.com", "failed", "5.1.1")), 0o600)
+	os.WriteFile(filepath.Join(dir, "new", "2"), []byte(testDSN("b@example.com", "failed", "5.1.1")), 0o600)
+
+	var read int
+	count := func(r io.Reader) error {
+		read++
+		return nil
+	}
+	if e := bounces.ReadMaildir(dir, count); e != nil {
+		t.Fatalf("Failed to read maildir: %s", e)
+	}
+	if remaining, _ := os.ReadDir(filepath.Join(dir, "new")); read != 2 || len(remaining) != 0 {
+		t.Errorf("Expected 2 messages moved out of new, read %d, %d remaining", read, len(remaining))
+	}
+	if seen, _ := os.ReadDir(filepath.Join(dir, "cur")); len(seen) != 2 || !strings.HasSuffix(seen[0].Name(), ":2,S") {
+		t.Errorf("Expected 2 seen messages in cur, got: %v", seen)
+	}
+
+	mbox := filepath.Join(t.TempDir(), "bounces.mbox")
+	content := "From MAILER-DAEMON Mon Jan  1 00:00:00 2024\n" + testDSN("a@example.com", "failed", "5.1.1") +
+		"\nFrom MAILER-DAEMON Mon Jan  1 00:00:01 2024\n" + testDSN("b@example.com", "delayed", "4.4.1")
+	os.WriteFile(mbox, []byte(content), 0o600)
+
+	var kinds []string
+	e := bounces.ReadMbox(mbox, func(r io.Reader) error {
+		dsn, e := bounces.ParseDSN(r)
+		if e != nil {
+			return e
+		}
+		kind, _ := bounces.Classify(dsn)
+		kinds = append(kinds, kind)
+		return nil
+	})
+	if e != nil {
+		t.Fatalf("Failed to read mbox: %s", e)
+	}
+	if len(kinds) != 2 || kinds[0] != models.BounceKindHard || kinds[1] != models.BounceKindSoft {
+		t.Errorf("Unexpected bounces: %v", kinds)
+	}
+	if _, e := os.Stat(mbox); !errors.Is(e, os.ErrNotExist) {
+		t.Errorf("Expected mbox to be consumed")
+	}
+	if e := bounces.ReadMbox(mbox, nil); e != nil {
+		t.Errorf("Expected a missing mbox to be skipped, got: %s", e)
+	}
+}
+
+func TestProcessBounce(t *testing.T) {
+	testCases := []struct {
+		name           string
+		status         string
+		soft           int
+		expectedBounce bool
+		reason         string
+	}{
+		{"(+) Test case 1 -> hard bounce -> bounced", "5.1.1", 0, true, "hard bounce"},
+		{"(+) Test case 2 -> soft bounce below threshold -> kept", "4.4.1", 1, false, ""},
+		{"(+) Test case 3 -> soft bounce at threshold -> bounced", "4.4.1", 3, true, "repeated soft bounces"},
+	}
+
+	email := "user@example.com"
+	hash := models.HashEmail(models.SubscriberEmail(email))
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		settings := testBounceSettings()
+		verp := bounces.NewVERP(settings, app.Signer)
+		deliveryID, issueID, listID := uuid.NewString(), uuid.NewString(), lists.DefaultListID
+
+		hard := 0
+		if strings.HasPrefix(tc.status, "5") {
+			hard = 1
+		}
+
+		app.Database.ExpectBegin()
+		app.Database.ExpectQuery("SELECT (.+) FROM delivery_log").
+			WithArgs(deliveryID).
+			WillReturnRows(
+				pgxmock.NewRows([]string{"newsletter_issue_id", "list_id", "subscriber_email"}).
+					AddRow(&issueID, &listID, models.SubscriberEmail("user@example.com")),
+			)
+		app.Database.ExpectExec("INSERT INTO bounces").
+			WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), &deliveryID, &issueID, &listID, "user@example.com", pgxmock.AnyArg(), tc.status, pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		app.Database.ExpectQuery("SELECT (.+) FROM bounces").
+			WithArgs("user@example.com", settings.SoftWindow).
+			WillReturnRows(pgxmock.NewRows([]string{"hard", "soft"}).AddRow(hard, tc.soft))
+		if tc.expectedBounce {
+			app.Database.ExpectExec("UPDATE subscriptions SET status = 'bounced'").
+				WithArgs("user@example.com").
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			app.Database.ExpectExec("DELETE FROM issue_delivery_queue").
+				WithArgs("user@example.com").
+				WillReturnResult(pgxmock.NewResult("DELETE", 0))
+			app.Database.ExpectExec("INSERT INTO suppressions").
+				WithArgs(pgxmock.AnyArg(), &email, &hash, (*string)(nil), tc.reason, models.SuppressionSourceBounce).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		}
+		app.Database.ExpectCommit()
+
+		action := "failed"
+		if hard == 0 {
+			action = "delayed"
+		}
+		message := testDSN(verp.Address(deliveryID).String(), action, tc.status)
+		if e := workers.ProcessBounce(app.Context, app.DH, settings, verp, strings.NewReader(message)); e != nil {
+			t.Errorf("%s: failed to process bounce: %s", tc.name, e)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+
+	// unattributed bounces are consumed without touching subscribers
+	app := utils.NewMockApp()
+	settings := testBounceSettings()
+	app.Database.ExpectBegin()
+	app.Database.ExpectRollback()
+	message := testDSN("bounces+forged-0000@bounces.example.com", "failed", "5.1.1")
+	if e := workers.ProcessBounce(app.Context, app.DH, settings, bounces.NewVERP(settings, app.Signer), strings.NewReader(message)); e != nil {
+		t.Errorf("Expected unattributed bounce to be skipped, got: %s", e)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestProcessBounce_ReimportSkipped(t *testing.T) {
+	app := utils.NewMockApp()
+	settings := testBounceSettings()
+	verp := bounces.NewVERP(settings, app.Signer)
+	app.Router.POST("/subscribers/import", func(c *gin.Context) { adminRoutes.PostSubscriberImport(c, app.DH) })
+
+	deliveryID, issueID, listID := uuid.NewString(), uuid.NewString(), lists.DefaultListID
+	email := "user@example.com"
+	hash := models.HashEmail(models.SubscriberEmail(email))
+
+	// the hard bounce lands the address on the suppression list
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT (.+) FROM delivery_log").
+		WithArgs(deliveryID).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"newsletter_issue_id", "list_id", "subscriber_email"}).
+				AddRow(&issueID, &listID, models.SubscriberEmail(email)),
+		)
+	app.Database.ExpectExec("INSERT INTO bounces").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), &deliveryID, &issueID, &listID, email, pgxmock.AnyArg(), "5.1.1", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectQuery("SELECT (.+) FROM bounces").
+		WithArgs(email, settings.SoftWindow).
+		WillReturnRows(pgxmock.NewRows([]string{"hard", "soft"}).AddRow(1, 0))
+	app.Database.ExpectExec("UPDATE subscriptions SET status = 'bounced'").
+		WithArgs(email).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	app.Database.ExpectExec("DELETE FROM issue_delivery_queue").
+		WithArgs(email).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	app.Database.ExpectExec("INSERT INTO suppressions").
+		WithArgs(pgxmock.AnyArg(), &email, &hash, (*string)(nil), "hard bounce", models.SuppressionSourceBounce).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectCommit()
+
+	message := testDSN(verp.Address(deliveryID).String(), "failed", "5.1.1")
+	if e := workers.ProcessBounce(app.Context, app.DH, settings, verp, strings.NewReader(message)); e != nil {
+		t.Fatalf("Failed to process bounce: %s", e)
+	}
+
+	// re-importing the address skips it as suppressed
+	app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+		WithArgs("default").
+		WillReturnRows(defaultListRows())
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT normalized_email FROM subscriptions WHERE list_id").
+		WithArgs(lists.DefaultListID, []string{email}).
+		WillReturnRows(pgxmock.NewRows([]string{"normalized_email"}))
+	app.Database.ExpectQuery("SELECT candidate.email FROM unnest").
+		WithArgs([]string{email}).
+		WillReturnRows(pgxmock.NewRows([]string{"email"}).AddRow(email))
+	app.Database.ExpectCommit()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "subscribers.csv")
+	part.Write([]byte("Name,E-Mail\nuser," + email + "\n"))
+	writer.WriteField("email_column", "e-mail")
+	writer.WriteField("confirmed", "true")
+	writer.WriteField("consent_source", "signup form 2023")
+	writer.Close()
+
+	request, _ := http.NewRequest("POST", "/subscribers/import", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	app.NewMockRequest(request)
+
+	var response struct {
+		Report models.ImportReport `json:"report"`
+	}
+	json.Unmarshal(app.Recorder.Body.Bytes(), &response)
+	if response.Report.Imported != 0 || response.Report.Suppressed != 1 {
+		t.Errorf("Expected bounced address to be skipped, got: %+v", response.Report)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestMockEmail_ReturnPath(t *testing.T) {
+	server := mock.New(mock.ConfigurationAttr{})
+	server.Start()
+	defer server.Stop()
+
+	sender := models.SubscriberEmail("user@example.com")
+	client := mockClient
+	client.SmtpPort = server.PortNumber
+	client.Sender = &sender
+
+	newsletter := models.Newsletter{
+		Recipient:  "test@example.com",
+		Content:    &models.Body{Title: "testing", Text: "testing", Html: "<p>testing</p>"},
+		ReturnPath: "bounces+id-tag@bounces.example.com",
+	}
+	if e := client.SendEmail(&newsletter); e != nil {
+		t.Fatalf("Failed to send email: %s", e)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 || !strings.Contains(messages[0].MailfromRequest(), "bounces+id-tag@bounces.example.com") {
+		t.Errorf("Expected envelope sender to be the return path, got: %v", messages)
+	}
+}
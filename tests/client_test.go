@@ -1,7 +1,11 @@
 package api_test
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
 	"testing"
 
 	mock "github.com/mocktools/go-smtp-mock"
@@ -145,3 +149,89 @@ func TestMockEmail_InvalidBody_Fails(t *testing.T) {
 		}
 	}
 }
+
+// fakeSMTPServer advertises SMTPUTF8 and records the MAIL FROM command
+func fakeSMTPServer(t *testing.T) (port int, mailFrom chan string) {
+	listener, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatalf("Failed to listen: %s", e)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	mailFrom = make(chan string, 1)
+	go func() {
+		conn, e := listener.Accept()
+		if e != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ready\r\n")
+		for {
+			line, e := reader.ReadString('\n')
+			if e != nil {
+				return
+			}
+
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"):
+				fmt.Fprint(conn, "250-localhost\r\n250-8BITMIME\r\n250 SMTPUTF8\r\n")
+			case strings.HasPrefix(command, "MAIL"):
+				mailFrom <- strings.TrimSpace(line)
+				fmt.Fprint(conn, "250 OK\r\n")
+			case strings.HasPrefix(command, "DATA"):
+				fmt.Fprint(conn, "354 Go ahead\r\n")
+				for {
+					data, e := reader.ReadString('\n')
+					if e != nil || data == ".\r\n" {
+						break
+					}
+				}
+				fmt.Fprint(conn, "250 OK\r\n")
+			case strings.HasPrefix(command, "QUIT"):
+				fmt.Fprint(conn, "221 Bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 OK\r\n")
+			}
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, mailFrom
+}
+
+func TestMockEmail_SMTPUTF8(t *testing.T) {
+	port, mailFrom := fakeSMTPServer(t)
+
+	sender := models.SubscriberEmail("user@example.com")
+	client := &clients.SMTPClient{SmtpServer: "127.0.0.1", SmtpPort: port, Sender: &sender}
+	newsletter := models.Newsletter{
+		Recipient: "josé@example.com",
+		Content:   &models.Body{Title: "testing", Text: "testing", Html: "<p>testing</p>"},
+	}
+	if e := client.SendEmail(&newsletter); e != nil {
+		t.Fatalf("Failed to send email: %s", e)
+	}
+
+	if command := <-mailFrom; !strings.HasSuffix(command, "SMTPUTF8") {
+		t.Errorf("Expected SMTPUTF8 to be requested, got: %s", command)
+	}
+}
+
+func TestMockEmail_SMTPUTF8Unsupported_Fails(t *testing.T) {
+	server := mock.New(mock.ConfigurationAttr{})
+	server.Start()
+	defer server.Stop()
+
+	sender := models.SubscriberEmail("user@example.com")
+	client := &clients.SMTPClient{SmtpPort: server.PortNumber, Sender: &sender}
+	newsletter := models.Newsletter{
+		Recipient: "josé@example.com",
+		Content:   &models.Body{Title: "testing", Text: "testing", Html: "<p>testing</p>"},
+	}
+	if e := client.SendEmail(&newsletter); !errors.Is(e, clients.ErrSMTPUTF8Unsupported) {
+		t.Errorf("Expected SMTPUTF8 to be required, got: %v", e)
+	}
+}
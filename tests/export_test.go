@@ -0,0 +1,131 @@
+package api_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/subscribers"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func TestParseSubscriberFilter(t *testing.T) {
+	testCases := []struct {
+		name          string
+		values        [5]string
+		expectedError bool
+	}{
+		{"(+) Test case 1 -> empty filter -> passes", [5]string{}, false},
+		{"(+) Test case 2 -> every filter -> passes", [5]string{"", "Confirmed", "VIP", "2023-01-01", "2024-01-01"}, false},
+		{"(-) Test case 3 -> unknown status -> fails", [5]string{"", "deleted", "", "", ""}, true},
+		{"(-) Test case 4 -> invalid tag -> fails", [5]string{"", "", "a b", "", ""}, true},
+		{"(-) Test case 5 -> invalid date -> fails", [5]string{"", "", "", "yesterday", ""}, true},
+		{"(-) Test case 6 -> inverted range -> fails", [5]string{"", "", "", "2024-01-01", "2023-01-01"}, true},
+	}
+
+	for _, tc := range testCases {
+		_, e := models.ParseSubscriberFilter(tc.values[0], tc.values[1], tc.values[2], tc.values[3], tc.values[4])
+		if (e != nil) != tc.expectedError {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+		}
+	}
+}
+
+func TestBuildSubscriberFilter(t *testing.T) {
+	clause, args := subscribers.BuildFilter(&models.SubscriberFilter{}, []interface{}{})
+	if clause != "true" || len(args) != 0 {
+		t.Errorf("Expected unfiltered clause, got: %s, %v", clause, args)
+	}
+
+	filter, _ := models.ParseSubscriberFilter("", "confirmed", "vip", "2023-01-01", "")
+	filter.ListID = "list"
+	clause, args = subscribers.BuildFilter(filter, []interface{}{"cursor"})
+	if len(args) != 5 || args[1] != "confirmed" || args[2] != "list" || args[3] != "vip" {
+		t.Errorf("Unexpected arguments: %v", args)
+	}
+	for _, expected := range []string{"status = $2", "list_id = $3", "tag = $4", "created >= $5"} {
+		if !strings.Contains(clause, expected) {
+			t.Errorf("Expected clause to contain %q, got: %s", expected, clause)
+		}
+	}
+}
+
+func exportRows() *pgxmock.Rows {
+	source := "signup form"
+	return pgxmock.NewRows([]string{"id", "email", "name", "status", "slug", "created", "tags", "attributes", "consent_source"}).
+		AddRow(uuid.NewString(), models.SubscriberEmail("alice@example.com"), models.SubscriberName("alice"), "confirmed", "default", time.Now(), []string{"vip"}, models.Attributes{"company": "Acme"}, &source).
+		AddRow(uuid.NewString(), models.SubscriberEmail("bob@example.com"), models.SubscriberName("bob"), "confirmed", "default", time.Now(), []string{}, models.Attributes{}, nil)
+}
+
+func TestGetSubscriberExport(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+	}{
+		{"(+) Test case 1 -> csv export -> passes", "csv"},
+		{"(+) Test case 2 -> ndjson export -> passes", "ndjson"},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.GET("/subscribers/export", func(c *gin.Context) { adminRoutes.GetSubscriberExport(c, app.DH) })
+
+		app.Database.ExpectBegin()
+		app.Database.ExpectExec("DECLARE subscriber_export").
+			WithArgs("confirmed").
+			WillReturnResult(pgxmock.NewResult("DECLARE CURSOR", 0))
+		app.Database.ExpectQuery("FETCH FORWARD").
+			WillReturnRows(exportRows())
+		app.Database.ExpectExec("CLOSE subscriber_export").
+			WillReturnResult(pgxmock.NewResult("CLOSE CURSOR", 0))
+		app.Database.ExpectRollback()
+
+		request, _ := http.NewRequest("GET", "/subscribers/export?status=confirmed&format="+tc.format, nil)
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, http.StatusOK, responseStatus)
+		}
+
+		body := app.Recorder.Body.String()
+		switch tc.format {
+		case "csv":
+			records, e := csv.NewReader(strings.NewReader(body)).ReadAll()
+			if e != nil || len(records) != 3 || records[1][1] != "alice@example.com" || records[1][6] != "vip" {
+				t.Errorf("%s: unexpected csv: %q, %v", tc.name, body, e)
+			}
+		case "ndjson":
+			lines := strings.Split(strings.TrimSpace(body), "\n")
+			var subscriber models.ExportedSubscriber
+			if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &subscriber) != nil || subscriber.Email != "bob@example.com" {
+				t.Errorf("%s: unexpected ndjson: %q", tc.name, body)
+			}
+		}
+
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
+
+func TestGetSubscriberExport_InvalidFormat_Fails(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/subscribers/export", func(c *gin.Context) { adminRoutes.GetSubscriberExport(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	request, _ := http.NewRequest("GET", "/subscribers/export?format=xml", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusBadRequest {
+		t.Errorf("Expected status code %v, but got %v", http.StatusBadRequest, responseStatus)
+	}
+}
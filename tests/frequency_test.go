@@ -0,0 +1,77 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/frequency"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/preferences"
+	"github.com/solomonbaez/hyacinth/api/workers"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+var testFrequencyCap = &configs.FrequencyCapSettings{MaxMessages: 3, Window: 24}
+
+func TestIsTransactional(t *testing.T) {
+	list := &models.List{ID: lists.DefaultListID, ConfirmationIssueID: uuid.NewString()}
+	testCases := []struct {
+		name          string
+		issueID       string
+		transactional bool
+	}{
+		{"(+) Test case 1 -> list confirmation -> exempt", list.ConfirmationIssueID, true},
+		{"(+) Test case 2 -> email change confirmation -> exempt", preferences.EmailChangeConfirmationIssueID, true},
+		{"(+) Test case 3 -> email change notification -> exempt", preferences.EmailChangeNotificationIssueID, true},
+		{"(-) Test case 4 -> newsletter issue -> capped", uuid.NewString(), false},
+	}
+
+	for _, tc := range testCases {
+		if transactional := frequency.IsTransactional(tc.issueID, list); transactional != tc.transactional {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.transactional, transactional)
+		}
+	}
+}
+
+func TestTryExecuteTask_FrequencyCapped(t *testing.T) {
+	app := utils.NewMockApp()
+	defer app.Database.Close(app.Context)
+
+	issueID := uuid.NewString()
+	next := time.Now().Add(3 * time.Hour)
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT (.+) FROM issue_delivery_queue WHERE execute_after <= now()").
+		WillReturnRows(
+			pgxmock.NewRows([]string{"newsletter_issue_id", "list_id", "subscriber_email", "sequence_id", "variant"}).
+				AddRow(issueID, lists.DefaultListID, models.SubscriberEmail("user@example.com"), (*string)(nil), (*int)(nil)),
+		)
+	app.Database.ExpectQuery("SELECT (.+) FROM suppressions").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE list_id").
+		WithArgs(lists.DefaultListID).
+		WillReturnRows(defaultListRows())
+	app.Database.ExpectExec("SELECT pg_advisory_xact_lock").
+		WithArgs("user@example.com").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	app.Database.ExpectQuery("SELECT (.+) FROM delivery_log WHERE normalize_email\\(subscriber_email\\)").
+		WithArgs("user@example.com", testFrequencyCap.MaxMessages, testFrequencyCap.Window, pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"next"}).AddRow(&next))
+	app.Database.ExpectExec("UPDATE issue_delivery_queue SET execute_after").
+		WithArgs(issueID, lists.DefaultListID, "user@example.com", next).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	app.Database.ExpectCommit()
+
+	outcome := workers.TryExecuteTask(app.Context, app.DH, app.Client, app.Signer, nil, testFrequencyCap, nil)
+	if outcome != workers.ExecutionOutcomeTaskCompleted {
+		t.Errorf("Expected capped delivery to be deferred, got: %v", outcome)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -0,0 +1,154 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/imports"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+const testImportCSV = "Name,E-Mail\n" +
+	"alice,alice@example.com\n" +
+	"bob,bob@example.com\n" +
+	"alice,alice@example.com\n" +
+	"<carol>,carol@example.com\n" +
+	"dave,dave@\n" +
+	"erin\n"
+
+func TestParseCSV(t *testing.T) {
+	options := &models.ImportOptions{EmailColumn: "e-mail"}
+	if e := models.ParseImportOptions(options); e != nil {
+		t.Fatalf("Failed to parse options: %s", e)
+	}
+
+	rows, report, e := imports.ParseCSV(strings.NewReader(testImportCSV), options)
+	if e != nil {
+		t.Fatalf("Failed to parse csv: %s", e)
+	}
+
+	if len(rows) != 2 || rows[0].Email != "alice@example.com" || rows[1].Row != 3 {
+		t.Errorf("Unexpected rows: %v", rows)
+	}
+	if report.Duplicates != 1 {
+		t.Errorf("Expected 1 duplicate, got: %d", report.Duplicates)
+	}
+
+	expectedRows := []int{5, 6, 7}
+	if len(report.Errors) != len(expectedRows) {
+		t.Fatalf("Expected %d errors, got: %d", len(expectedRows), len(report.Errors))
+	}
+	for i, row := range expectedRows {
+		if report.Errors[i].Row != row {
+			t.Errorf("Expected error on row %d, got: %d", row, report.Errors[i].Row)
+		}
+	}
+
+	if _, _, e := imports.ParseCSV(strings.NewReader("address,name\n"), options); e == nil {
+		t.Errorf("Failed to reject csv without an email column")
+	}
+}
+
+func TestParseImportOptions(t *testing.T) {
+	if e := models.ParseImportOptions(&models.ImportOptions{Confirmed: true}); e == nil {
+		t.Errorf("Failed to reject pre-confirmed import without a consent source")
+	}
+
+	options := &models.ImportOptions{ConsentSource: "ignored"}
+	if e := models.ParseImportOptions(options); e != nil || options.ConsentSource != "" {
+		t.Errorf("Expected consent source to be dropped for pending imports, got: %q, %v", options.ConsentSource, e)
+	}
+}
+
+func TestPostSubscriberImport(t *testing.T) {
+	testCases := []struct {
+		name       string
+		confirmed  string
+		suppressed bool
+	}{
+		{"(+) Test case 1 -> pending import -> enqueues confirmations", "false", false},
+		{"(+) Test case 2 -> pre-confirmed import -> records consent", "true", false},
+		{"(+) Test case 3 -> suppressed address -> skipped", "true", true},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.POST("/subscribers/import", func(c *gin.Context) { adminRoutes.PostSubscriberImport(c, app.DH) })
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("file", "subscribers.csv")
+		part.Write([]byte(testImportCSV))
+		writer.WriteField("email_column", "e-mail")
+		writer.WriteField("confirmed", tc.confirmed)
+		writer.WriteField("consent_source", "signup form 2023")
+		writer.Close()
+
+		columns := []string{"id", "email", "name", "status", "list_id", "created", "consent_source", "consented_at"}
+		app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+			WithArgs("default").
+			WillReturnRows(defaultListRows())
+		app.Database.ExpectBegin()
+		app.Database.ExpectQuery("SELECT normalized_email FROM subscriptions WHERE list_id").
+			WithArgs(lists.DefaultListID, []string{"alice@example.com", "bob@example.com"}).
+			WillReturnRows(pgxmock.NewRows([]string{"normalized_email"}).AddRow("bob@example.com"))
+		suppressedRows := pgxmock.NewRows([]string{"email"})
+		if tc.suppressed {
+			suppressedRows.AddRow("alice@example.com")
+		}
+		app.Database.ExpectQuery("SELECT candidate.email FROM unnest").
+			WithArgs([]string{"alice@example.com", "bob@example.com"}).
+			WillReturnRows(suppressedRows)
+		if !tc.suppressed {
+			app.Database.ExpectCopyFrom([]string{"subscriptions"}, columns).
+				WillReturnResult(1)
+		}
+		if tc.confirmed == "false" {
+			app.Database.ExpectCopyFrom([]string{"subscription_tokens"}, []string{"subscription_token", "subscriber_id"}).
+				WillReturnResult(1)
+			app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		}
+		if tc.confirmed == "true" && !tc.suppressed {
+			app.Database.ExpectExec("INSERT INTO issue_delivery_queue (.+) JOIN sequences").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		}
+		app.Database.ExpectCommit()
+
+		request, _ := http.NewRequest("POST", "/subscribers/import", &body)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, http.StatusOK, responseStatus)
+		}
+
+		var response struct {
+			Report models.ImportReport `json:"report"`
+		}
+		json.Unmarshal(app.Recorder.Body.Bytes(), &response)
+		imported, suppressed := 1, 0
+		if tc.suppressed {
+			imported, suppressed = 0, 1
+		}
+		report := response.Report
+		if report.Imported != imported || report.Suppressed != suppressed || report.Duplicates != 2 || len(report.Errors) != 3 {
+			t.Errorf("%s: unexpected report: %+v", tc.name, response.Report)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
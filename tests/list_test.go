@@ -0,0 +1,102 @@
+package api_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/routes"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func TestParseList(t *testing.T) {
+	testCases := []struct {
+		name          string
+		list          models.List
+		expectedError bool
+	}{
+		{
+			"(+) Test case 1 -> valid list with sender identity -> passes",
+			models.List{Slug: "weekly-digest", Name: "Weekly Digest", SenderName: "The Editors", SenderEmail: "editors@example.com"},
+			false,
+		},
+		{
+			"(+) Test case 2 -> valid list without sender identity -> passes",
+			models.List{Slug: "news", Name: "News"},
+			false,
+		},
+		{
+			"(-) Test case 3 -> invalid slug -> fails",
+			models.List{Slug: "Weekly Digest", Name: "Weekly Digest"},
+			true,
+		},
+		{
+			"(-) Test case 4 -> invalid name -> fails",
+			models.List{Slug: "news", Name: "<b>News</b>"},
+			true,
+		},
+		{
+			"(-) Test case 5 -> invalid sender email -> fails",
+			models.List{Slug: "news", Name: "News", SenderEmail: "editors"},
+			true,
+		},
+		{
+			"(-) Test case 6 -> slug exceeding maximum length -> fails",
+			models.List{Slug: strings.Repeat("a", 51), Name: "News"},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		if e := models.ParseList(&tc.list); (e != nil) != tc.expectedError {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+		}
+	}
+}
+
+func TestParseConfirmationTemplate(t *testing.T) {
+	valid := &models.Body{
+		Title: "Confirm",
+		Text:  "Confirm at: {{.link}}",
+		Html:  "<p>Confirm at: {{.link}}</p>",
+	}
+	if e := models.ParseConfirmationTemplate(valid); e != nil {
+		t.Errorf("Failed to parse confirmation template: %s", e)
+	}
+
+	missingLink := &models.Body{
+		Title: "Confirm",
+		Text:  "Confirm at: {{.link}}",
+		Html:  "<p>Confirm</p>",
+	}
+	if e := models.ParseConfirmationTemplate(missingLink); e == nil {
+		t.Errorf("Failed to reject confirmation template without a link")
+	}
+}
+
+func TestPostSubscribe_UnknownList_Fails(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.POST("/subscribe", func(c *gin.Context) { routes.Subscribe(c, app.DH, nil) })
+	defer app.Database.Close(app.Context)
+
+	data := `{"email": "user@example.com", "name": "user", "list": "unknown"}`
+	request, _ := http.NewRequest("POST", "/subscribe", strings.NewReader(data))
+
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+		WithArgs("unknown").
+		WillReturnRows(
+			pgxmock.NewRows([]string{"list_id", "slug", "name", "sender_name", "sender_email", "confirmation_issue_id", "disposable_policy", "role_policy"}),
+		)
+	app.Database.ExpectRollback()
+
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusNotFound {
+		t.Errorf("Expected status code %v, but got %v", http.StatusNotFound, responseStatus)
+	}
+}
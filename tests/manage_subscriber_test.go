@@ -0,0 +1,305 @@
+package api_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func expectSubscriber(app utils.App, id string, status string) {
+	app.Database.ExpectQuery("SELECT id, email, name, status, list_id, created FROM subscriptions WHERE id").
+		WithArgs(id).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"id", "email", "name", "status", "list_id", "created"}).
+				AddRow(id, models.SubscriberEmail("user@example.com"), models.SubscriberName("user"), status, lists.DefaultListID, time.Now()),
+		)
+}
+
+func expectAudit(app utils.App, action string) {
+	app.Database.ExpectExec("INSERT INTO audit_log").
+		WithArgs(pgxmock.AnyArg(), "unknown", action, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectCommit()
+}
+
+func TestGetManageSubscriber(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.GET("/subscribers/:id/manage", func(c *gin.Context) { adminRoutes.GetManageSubscriber(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	expectSubscriber(app, id, "pending")
+	app.Database.ExpectQuery("SELECT (.+) FROM audit_log").
+		WithArgs(id).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"audit_id", "actor", "action", "subscriber_id", "details", "created"}).
+				AddRow(uuid.NewString(), "admin", models.AuditActionUpdate, id, map[string]interface{}{}, time.Now()),
+		)
+
+	request, _ := http.NewRequest("GET", "/subscribers/"+id+"/manage", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	body := app.Recorder.Body.String()
+	for _, expected := range []string{"user@example.com", "Resend Confirmation", models.AuditActionUpdate} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected page to contain %q", expected)
+		}
+	}
+}
+
+func TestGetManageSubscriber_Suppressed(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.GET("/subscribers/:id/manage", func(c *gin.Context) { adminRoutes.GetManageSubscriber(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	expectSubscriber(app, id, "suppressed")
+	app.Database.ExpectQuery("SELECT (.+) FROM audit_log").
+		WithArgs(id).
+		WillReturnRows(pgxmock.NewRows([]string{"audit_id", "actor", "action", "subscriber_id", "details", "created"}))
+
+	request, _ := http.NewRequest("GET", "/subscribers/"+id+"/manage", nil)
+	app.NewMockRequest(request)
+
+	// the status can not be changed from the edit form
+	body := app.Recorder.Body.String()
+	if !strings.Contains(body, `<select name="status" disabled>`) || strings.Contains(body, `<option value="pending"`) {
+		t.Errorf("Expected a disabled status select, got: %s", body)
+	}
+}
+
+func TestPostSubscriberMutations(t *testing.T) {
+	id := uuid.NewString()
+	email, hash := "user@example.com", models.HashEmail("user@example.com")
+
+	testCases := []struct {
+		name   string
+		path   string
+		status string
+		form   url.Values
+		expect func(app utils.App)
+	}{
+		{
+			"(+) Test case 1 -> edit name and status -> passes",
+			"edit",
+			"pending",
+			url.Values{"name": {"renamed"}, "status": {"unsubscribed"}},
+			func(app utils.App) {
+				app.Database.ExpectBegin()
+				app.Database.ExpectExec("UPDATE subscriptions SET name").
+					WithArgs(id, "renamed", "unsubscribed").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				expectAudit(app, models.AuditActionUpdate)
+			},
+		},
+		{
+			"(-) Test case 2 -> edit with invalid name -> no mutation",
+			"edit",
+			"pending",
+			url.Values{"name": {"<name>"}, "status": {"confirmed"}},
+			func(app utils.App) {},
+		},
+		{
+			"(-) Test case 3 -> edit to suppressed -> no mutation",
+			"edit",
+			"pending",
+			url.Values{"name": {"user"}, "status": {"suppressed"}},
+			func(app utils.App) {},
+		},
+		{
+			"(+) Test case 4 -> manual confirm -> passes",
+			"confirm",
+			"pending",
+			nil,
+			func(app utils.App) {
+				app.Database.ExpectBegin()
+				app.Database.ExpectExec("UPDATE subscriptions SET name").
+					WithArgs(id, "user", "confirmed").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				// the welcome sequence starts as for a confirmation link
+				app.Database.ExpectExec("INSERT INTO issue_delivery_queue (.+) JOIN sequences").
+					WithArgs([]string{id}).
+					WillReturnResult(pgxmock.NewResult("INSERT", 2))
+				expectAudit(app, models.AuditActionConfirm)
+			},
+		},
+		{
+			"(+) Test case 5 -> resend confirmation -> passes",
+			"resend",
+			"pending",
+			nil,
+			func(app utils.App) {
+				app.Database.ExpectBegin()
+				app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE list_id").
+					WithArgs(lists.DefaultListID).
+					WillReturnRows(defaultListRows())
+				app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
+					WithArgs(pgxmock.AnyArg(), lists.DefaultListID, "user@example.com").
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				expectAudit(app, models.AuditActionResendConfirmation)
+			},
+		},
+		{
+			"(-) Test case 6 -> resend to confirmed subscriber -> no mutation",
+			"resend",
+			"confirmed",
+			nil,
+			func(app utils.App) {},
+		},
+		{
+			"(+) Test case 7 -> suppress -> passes",
+			"suppress",
+			"confirmed",
+			url.Values{"reason": {"requested by phone"}},
+			func(app utils.App) {
+				app.Database.ExpectBegin()
+				app.Database.ExpectExec("INSERT INTO suppressions").
+					WithArgs(pgxmock.AnyArg(), &email, &hash, (*string)(nil), "requested by phone", models.SuppressionSourceAdmin).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				app.Database.ExpectExec("UPDATE subscriptions SET status = 'suppressed'").
+					WithArgs("user@example.com").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				app.Database.ExpectExec("DELETE FROM issue_delivery_queue").
+					WithArgs("user@example.com").
+					WillReturnResult(pgxmock.NewResult("DELETE", 0))
+				expectAudit(app, models.AuditActionSuppress)
+			},
+		},
+		{
+			"(+) Test case 8 -> delete -> passes",
+			"delete",
+			"confirmed",
+			nil,
+			func(app utils.App) {
+				app.Database.ExpectBegin()
+				app.Database.ExpectExec("DELETE FROM subscription_tokens").
+					WithArgs(id).
+					WillReturnResult(pgxmock.NewResult("DELETE", 1))
+				app.Database.ExpectExec("DELETE FROM issue_delivery_queue").
+					WithArgs(lists.DefaultListID, "user@example.com").
+					WillReturnResult(pgxmock.NewResult("DELETE", 0))
+				app.Database.ExpectExec("DELETE FROM subscriptions").
+					WithArgs(id).
+					WillReturnResult(pgxmock.NewResult("DELETE", 1))
+				expectAudit(app, models.AuditActionDelete)
+			},
+		},
+		{
+			"(+) Test case 9 -> edit to confirmed -> starts sequences",
+			"edit",
+			"unsubscribed",
+			url.Values{"name": {"user"}, "status": {"confirmed"}},
+			func(app utils.App) {
+				app.Database.ExpectBegin()
+				app.Database.ExpectExec("UPDATE subscriptions SET name").
+					WithArgs(id, "user", "confirmed").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				app.Database.ExpectExec("INSERT INTO issue_delivery_queue (.+) JOIN sequences").
+					WithArgs([]string{id}).
+					WillReturnResult(pgxmock.NewResult("INSERT", 2))
+				expectAudit(app, models.AuditActionUpdate)
+			},
+		},
+		{
+			"(+) Test case 10 -> rename suppressed subscriber -> keeps status",
+			"edit",
+			"suppressed",
+			url.Values{"name": {"renamed"}},
+			func(app utils.App) {
+				app.Database.ExpectBegin()
+				app.Database.ExpectExec("UPDATE subscriptions SET name").
+					WithArgs(id, "renamed", "suppressed").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				expectAudit(app, models.AuditActionUpdate)
+			},
+		},
+		{
+			"(-) Test case 11 -> edit bounced subscriber to pending -> no mutation",
+			"edit",
+			"bounced",
+			url.Values{"name": {"user"}, "status": {"pending"}},
+			func(app utils.App) {},
+		},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.POST("/subscribers/:id/"+tc.path, func(c *gin.Context) {
+			switch tc.path {
+			case "edit":
+				adminRoutes.PostSubscriberEdit(c, app.DH)
+			case "confirm":
+				adminRoutes.PostSubscriberConfirm(c, app.DH)
+			case "resend":
+				adminRoutes.PostSubscriberResendConfirmation(c, app.DH)
+			case "suppress":
+				adminRoutes.PostSubscriberSuppress(c, app.DH)
+			case "delete":
+				adminRoutes.PostSubscriberDelete(c, app.DH)
+			}
+		})
+
+		expectSubscriber(app, id, tc.status)
+		tc.expect(app)
+
+		request, _ := http.NewRequest("POST", "/subscribers/"+id+"/"+tc.path, strings.NewReader(tc.form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusSeeOther {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, http.StatusSeeOther, responseStatus)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
+
+func TestGetEmailCollisions(t *testing.T) {
+	app := utils.NewMockApp()
+	settings := &configs.NormalizationSettings{ProviderRules: true}
+	app.Router.GET("/subscribers/collisions", func(c *gin.Context) { adminRoutes.GetEmailCollisions(c, app.DH, settings) })
+	defer app.Database.Close(app.Context)
+
+	duplicate, provider, original := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	app.Database.ExpectQuery("SELECT (.+) FROM email_collisions (.+) provider_email").
+		WithArgs(true).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"rule", "id", "email", "id", "email", "list_id", "detected"}).
+				AddRow(models.CollisionRuleNormalized, duplicate, models.SubscriberEmail("user@Example.com"), original, models.SubscriberEmail("user@example.com"), lists.DefaultListID, time.Now()).
+				AddRow(models.CollisionRuleProvider, provider, models.SubscriberEmail("first.last@gmail.com"), original, models.SubscriberEmail("firstlast@gmail.com"), lists.DefaultListID, time.Now()),
+		)
+
+	request, _ := http.NewRequest("GET", "/subscribers/collisions", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	body := app.Recorder.Body.String()
+	for _, expected := range []string{duplicate, provider, original, "user@Example.com", `"rule":"provider"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected response to contain %q, got: %s", expected, body)
+		}
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pashagolub/pgxmock/v3"
@@ -50,7 +51,7 @@ func TestPostNewsletter(t *testing.T) {
 		// initialize
 		app = utils.NewMockApp()
 		admin := app.Router.Group("/admin")
-		admin.POST("/newsletter", func(c *gin.Context) { adminRoutes.PostNewsletter(c, app.DH, app.Client) })
+		admin.POST("/newsletter", func(c *gin.Context) { adminRoutes.PostNewsletter(c, app.DH, app.Client, app.Attachments) })
 		defer app.Database.Close(app.Context)
 
 		// Create a URL-encoded form data string
@@ -64,6 +65,10 @@ func TestPostNewsletter(t *testing.T) {
 		request, _ := http.NewRequest("POST", "/admin/newsletter", strings.NewReader(formData))
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
+		app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+			WithArgs("default").
+			WillReturnRows(defaultListRows())
+
 		app.Database.ExpectBegin()
 
 		query := "INSERT INTO idempotency"
@@ -78,12 +83,12 @@ func TestPostNewsletter(t *testing.T) {
 
 		query = "INSERT INTO newsletter_issues"
 		app.Database.ExpectExec(query).
-			WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), false, false, (*time.Time)(nil), (*string)(nil), false).
 			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 		query = "INSERT INTO issue_delivery_queue"
 		app.Database.ExpectExec(query).
-			WithArgs(pgxmock.AnyArg()).
+			WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
 			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 		app.Database.ExpectCommit()
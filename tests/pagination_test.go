@@ -0,0 +1,108 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func TestParsePage(t *testing.T) {
+	page, e := models.ParsePage("", "", "")
+	if e != nil || page.Sort != models.SortCreated || !page.Descending || page.Limit != models.DefaultPageSize {
+		t.Errorf("Unexpected default page: %+v, %v", page, e)
+	}
+
+	cursor := (&models.PageCursor{Sort: "email", Value: "a@example.com", ID: uuid.NewString()}).Encode()
+	if page, e = models.ParsePage("email", "10", cursor); e != nil || page.After.Value != "a@example.com" {
+		t.Errorf("Failed to decode cursor: %+v, %v", page, e)
+	}
+
+	testCases := [][3]string{
+		{"password", "", ""},
+		{"", "0", ""},
+		{"", "1000", ""},
+		{"", "", "not-a-cursor"},
+		{"-email", "", cursor},
+	}
+	for _, tc := range testCases {
+		if _, e := models.ParsePage(tc[0], tc[1], tc[2]); e == nil {
+			t.Errorf("Expected page %v to be rejected", tc)
+		}
+	}
+	if _, e := models.ParsePage("-email", "", cursor); !errors.Is(e, models.ErrInvalidCursor) {
+		t.Errorf("Expected cursor from another sort to be invalid, got: %v", e)
+	}
+
+	// tampered cursors are rejected before reaching the database
+	tampered := []*models.PageCursor{
+		{Sort: "email", Value: "a@example.com", ID: "not-a-uuid"},
+		{Sort: "-created", Value: "yesterday", ID: uuid.NewString()},
+	}
+	for _, tc := range tampered {
+		if _, e := models.ParsePage(tc.Sort, "", tc.Encode()); !errors.Is(e, models.ErrInvalidCursor) {
+			t.Errorf("Expected cursor %+v to be invalid, got: %v", tc, e)
+		}
+	}
+	created := (&models.PageCursor{Sort: "-created", Value: time.Now().Format(time.RFC3339Nano), ID: uuid.NewString()}).Encode()
+	if _, e := models.ParsePage("", "", created); e != nil {
+		t.Errorf("Failed to decode created cursor: %v", e)
+	}
+}
+
+func TestGetSubscribers_Paginated(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/subscribers", func(c *gin.Context) { adminRoutes.GetSubscribers(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	after := (&models.PageCursor{Sort: "email", Value: "a@example.com", ID: uuid.NewString()}).Encode()
+	ids := []string{uuid.NewString(), uuid.NewString(), uuid.NewString()}
+
+	app.Database.ExpectQuery(`SELECT count\(\*\) FROM subscriptions WHERE (.+) ILIKE`).
+		WithArgs("%example\\_%", "confirmed").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(5))
+	app.Database.ExpectQuery(`\(subscriptions.email, subscriptions.id\) > \(\$3::text, \$4::uuid\) ORDER BY subscriptions.email ASC`).
+		WithArgs("%example\\_%", "confirmed", "a@example.com", pgxmock.AnyArg(), 3).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"id", "email", "name", "created", "status"}).
+				AddRow(ids[0], models.SubscriberEmail("b@example_a.com"), models.SubscriberName("b"), time.Now(), "confirmed").
+				AddRow(ids[1], models.SubscriberEmail("c@example_a.com"), models.SubscriberName("c"), time.Now(), "confirmed").
+				AddRow(ids[2], models.SubscriberEmail("d@example_a.com"), models.SubscriberName("d"), time.Now(), "confirmed"),
+		)
+
+	request, _ := http.NewRequest("GET", "/subscribers?q=example_&status=confirmed&sort=email&limit=2&cursor="+after, nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Fatalf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+
+	var response struct {
+		Subscribers []*models.Subscriber `json:"subscribers"`
+		Total       int                  `json:"total"`
+		NextCursor  string               `json:"next_cursor"`
+	}
+	if e := json.Unmarshal(app.Recorder.Body.Bytes(), &response); e != nil {
+		t.Fatalf("Failed to decode response: %s", e)
+	}
+	if len(response.Subscribers) != 2 || response.Total != 5 {
+		t.Errorf("Unexpected page: %d subscribers of %d", len(response.Subscribers), response.Total)
+	}
+
+	page, e := models.ParsePage("email", "", response.NextCursor)
+	if e != nil || page.After.ID != ids[1] || page.After.Value != "c@example_a.com" {
+		t.Errorf("Expected cursor after the last returned subscriber, got: %+v, %v", page, e)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -0,0 +1,443 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/routes"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func TestSigner(t *testing.T) {
+	signer := signing.NewSigner(strings.Repeat("k", 32), 0)
+	id := uuid.NewString()
+
+	token := signer.Sign(signing.PurposePreferences, id)
+	if payload, e := signer.Verify(signing.PurposePreferences, token); e != nil || payload != id {
+		t.Errorf("Expected payload %s, got: %s, %v", id, payload, e)
+	}
+
+	other := signing.NewSigner(strings.Repeat("o", 32), 0)
+	tampered := signer.Sign(signing.PurposePreferences, uuid.NewString())
+	testCases := []string{
+		"",
+		id,
+		other.Sign(signing.PurposePreferences, id),
+		strings.Split(tampered, ".")[0] + "." + strings.Split(token, ".")[1],
+		// tokens issued for another use
+		signer.Sign(signing.PurposeOpen, id),
+	}
+	for _, tc := range testCases {
+		if _, e := signer.Verify(signing.PurposePreferences, tc); e == nil {
+			t.Errorf("Expected token %q to be rejected", tc)
+		}
+	}
+}
+
+func TestSigner_Expiring(t *testing.T) {
+	id := uuid.NewString()
+
+	signer := signing.NewSigner(strings.Repeat("k", 32), time.Hour)
+	token := signer.SignExpiring(signing.PurposePreferences, id)
+	if payload, e := signer.Verify(signing.PurposePreferences, token); e != nil || payload != id {
+		t.Errorf("Expected payload %s, got: %s, %v", id, payload, e)
+	}
+
+	parts := strings.Split(token, ".")
+	extended := parts[0] + ".9999999999." + parts[2]
+	if _, e := signer.Verify(signing.PurposePreferences, extended); !errors.Is(e, signing.ErrInvalidToken) {
+		t.Errorf("Expected a tampered expiry to be rejected, got: %v", e)
+	}
+
+	expiring := signing.NewSigner(strings.Repeat("k", 32), time.Nanosecond)
+	token = expiring.SignExpiring(signing.PurposePreferences, id)
+	if payload, e := expiring.Verify(signing.PurposePreferences, token); !errors.Is(e, signing.ErrExpiredToken) || payload != id {
+		t.Errorf("Expected an expired token carrying %s, got: %s, %v", id, payload, e)
+	}
+
+	// links issued before the expiry was configured never verify as valid
+	legacy := signing.NewSigner(strings.Repeat("k", 32), 0).Sign(signing.PurposePreferences, id)
+	if payload, e := signer.Verify(signing.PurposePreferences, legacy); !errors.Is(e, signing.ErrExpiredToken) || payload != id {
+		t.Errorf("Expected a token without expiry to be expired, got: %s, %v", payload, e)
+	}
+	// tracking tokens never expire
+	if _, e := signer.Verify(signing.PurposeOpen, signer.Sign(signing.PurposeOpen, id)); e != nil {
+		t.Errorf("Expected a tracking token to verify, got: %v", e)
+	}
+}
+
+func TestAppendPreferencesLink(t *testing.T) {
+	link := "http://localhost:8000/preferences/token"
+
+	body := &models.Body{Title: "title", Text: "text", Html: "<html><body><p>html</p></body></html>"}
+	models.AppendPreferencesLink(body, link)
+	if !strings.HasSuffix(body.Text, link) {
+		t.Errorf("Expected text footer, got: %s", body.Text)
+	}
+	if !strings.Contains(body.Html, `<a href="`+link+`">`) || !strings.HasSuffix(body.Html, "</body></html>") {
+		t.Errorf("Expected html footer inside body, got: %s", body.Html)
+	}
+
+	linked := &models.Body{Title: "title", Text: "manage: " + link, Html: `<a href="` + link + `">manage</a>`}
+	models.AppendPreferencesLink(linked, link)
+	if linked.Text != "manage: "+link || linked.Html != `<a href="`+link+`">manage</a>` {
+		t.Errorf("Expected linked content to be untouched, got: %s, %s", linked.Text, linked.Html)
+	}
+}
+
+func expectPreferences(app utils.App, id string) {
+	app.Database.ExpectQuery("SELECT email, name, paused_until, tracking_opt_out, timezone FROM subscriptions WHERE id").
+		WithArgs(id).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"email", "name", "paused_until", "tracking_opt_out", "timezone"}).
+				AddRow(models.SubscriberEmail("user@example.com"), models.SubscriberName("user"), nil, false, (*string)(nil)),
+		)
+	app.Database.ExpectQuery("SELECT (.+) FROM lists LEFT JOIN subscriptions").
+		WithArgs("user@example.com").
+		WillReturnRows(
+			pgxmock.NewRows([]string{"list_id", "name", "subscribed"}).
+				AddRow(lists.DefaultListID, "Newsletter", true),
+		)
+}
+
+func TestGetPreferences(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.GET("/preferences/:token", func(c *gin.Context) { routes.GetPreferences(c, app.DH, app.Signer) })
+	defer app.Database.Close(app.Context)
+
+	expectPreferences(app, id)
+	request, _ := http.NewRequest("GET", "/preferences/"+app.Signer.Sign(signing.PurposePreferences, id), nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	if !strings.Contains(app.Recorder.Body.String(), "user@example.com") {
+		t.Errorf("Expected preferences page for subscriber")
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestGetPreferences_InvalidToken_Fails(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/preferences/:token", func(c *gin.Context) { routes.GetPreferences(c, app.DH, app.Signer) })
+	defer app.Database.Close(app.Context)
+
+	forged := signing.NewSigner(strings.Repeat("f", 32), 0).Sign(signing.PurposePreferences, uuid.NewString())
+	request, _ := http.NewRequest("GET", "/preferences/"+forged, nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusNotFound {
+		t.Errorf("Expected status code %v, but got %v", http.StatusNotFound, responseStatus)
+	}
+}
+
+func TestPostPreferences(t *testing.T) {
+	id := uuid.NewString()
+	berlin := "Europe/Berlin"
+
+	testCases := []struct {
+		name   string
+		form   url.Values
+		update bool
+	}{
+		{
+			"(+) Test case 1 -> valid preferences -> updates",
+			url.Values{"name": {"newname"}, "lists": {lists.DefaultListID}, "pause": {"30"}, "tracking_opt_out": {"on"}, "timezone": {"Europe/Berlin"}},
+			true,
+		},
+		{
+			"(-) Test case 2 -> invalid name -> does not update",
+			url.Values{"name": {"<name>"}, "lists": {lists.DefaultListID}},
+			false,
+		},
+		{
+			"(-) Test case 3 -> unknown list -> does not update",
+			url.Values{"name": {"user"}, "lists": {uuid.NewString()}},
+			false,
+		},
+		{
+			"(-) Test case 4 -> invalid pause -> does not update",
+			url.Values{"name": {"user"}, "pause": {"forever"}},
+			false,
+		},
+		{
+			"(-) Test case 5 -> unknown timezone -> does not update",
+			url.Values{"name": {"user"}, "timezone": {"Mars/Olympus"}},
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.POST("/preferences/:token", func(c *gin.Context) { routes.PostPreferences(c, app.DH, app.Signer) })
+
+		expectPreferences(app, id)
+		if tc.update {
+			app.Database.ExpectBegin()
+			app.Database.ExpectExec("UPDATE subscriptions SET name").
+				WithArgs("user@example.com", "newname", pgxmock.AnyArg(), true, &berlin).
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			// bounced and suppressed subscriptions are left alone
+			app.Database.ExpectQuery(`INSERT INTO subscriptions (.+) WHERE subscriptions.status IN \('pending', 'unsubscribed'\)`).
+				WithArgs(pgxmock.AnyArg(), "user@example.com", "newname", lists.DefaultListID, pgxmock.AnyArg(), true, &berlin).
+				WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(id))
+			app.Database.ExpectExec("INSERT INTO issue_delivery_queue (.+) JOIN sequences").
+				WithArgs([]string{id}).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			app.Database.ExpectExec("UPDATE subscriptions SET status = 'unsubscribed'").
+				WithArgs("user@example.com", []string{lists.DefaultListID}).
+				WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+			app.Database.ExpectCommit()
+		}
+
+		request, _ := http.NewRequest("POST", "/preferences/"+app.Signer.Sign(signing.PurposePreferences, id), strings.NewReader(tc.form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusSeeOther {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, http.StatusSeeOther, responseStatus)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
+
+func TestPostPreferences_BouncedSurvives(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.POST("/preferences/:token", func(c *gin.Context) { routes.PostPreferences(c, app.DH, app.Signer) })
+	defer app.Database.Close(app.Context)
+
+	// deselecting the bounced list must not unsubscribe it, and selecting it
+	// again must not revive it
+	for _, form := range []url.Values{
+		{"name": {"user"}},
+		{"name": {"user"}, "lists": {lists.DefaultListID}},
+	} {
+		expectPreferences(app, id)
+		app.Database.ExpectBegin()
+		app.Database.ExpectExec("UPDATE subscriptions SET name").
+			WithArgs("user@example.com", "user", pgxmock.AnyArg(), false, pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		selected := []string{}
+		if len(form["lists"]) > 0 {
+			selected = form["lists"]
+			app.Database.ExpectQuery(`INSERT INTO subscriptions (.+) WHERE subscriptions.status IN \('pending', 'unsubscribed'\)`).
+				WithArgs(pgxmock.AnyArg(), "user@example.com", "user", lists.DefaultListID, pgxmock.AnyArg(), false, pgxmock.AnyArg()).
+				WillReturnError(pgx.ErrNoRows)
+		}
+		app.Database.ExpectExec(`UPDATE subscriptions SET status = 'unsubscribed' (.+) AND status IN \('pending', 'confirmed'\)`).
+			WithArgs("user@example.com", selected).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+		app.Database.ExpectCommit()
+
+		request, _ := http.NewRequest("POST", "/preferences/"+app.Signer.Sign(signing.PurposePreferences, id), strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusSeeOther {
+			t.Errorf("Expected status code %v, but got %v", http.StatusSeeOther, responseStatus)
+		}
+	}
+
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestPostOneClickUnsubscribe(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.POST("/preferences/:token/unsubscribe", func(c *gin.Context) { routes.PostOneClickUnsubscribe(c, app.DH, app.Signer) })
+	defer app.Database.Close(app.Context)
+
+	expectPreferences(app, id)
+	app.Database.ExpectExec("UPDATE subscriptions SET status = 'unsubscribed'").
+		WithArgs("user@example.com").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	data := strings.NewReader("List-Unsubscribe=One-Click")
+	request, _ := http.NewRequest("POST", "/preferences/"+app.Signer.Sign(signing.PurposePreferences, id)+"/unsubscribe", data)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestPreferences_ExpiredLink(t *testing.T) {
+	id := uuid.NewString()
+	signer := signing.NewSigner(strings.Repeat("k", 32), time.Nanosecond)
+	token := signer.SignExpiring(signing.PurposePreferences, id)
+
+	app := utils.NewMockApp()
+	app.Router.POST("/preferences/:token/email", func(c *gin.Context) { routes.PostEmailChange(c, app.DH, signer) })
+	form := url.Values{"email": {"new@example.com"}}
+	request, _ := http.NewRequest("POST", "/preferences/"+token+"/email", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	app.NewMockRequest(request)
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusGone {
+		t.Errorf("Expected status code %v, but got %v", http.StatusGone, responseStatus)
+	}
+
+	// unsubscribing keeps working
+	unsubscribe := utils.NewMockApp()
+	unsubscribe.Router.POST("/preferences/:token/unsubscribe", func(c *gin.Context) { routes.PostOneClickUnsubscribe(c, unsubscribe.DH, signer) })
+	expectPreferences(unsubscribe, id)
+	unsubscribe.Database.ExpectExec("UPDATE subscriptions SET status = 'unsubscribed'").
+		WithArgs("user@example.com").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	request, _ = http.NewRequest("POST", "/preferences/"+token+"/unsubscribe", strings.NewReader("List-Unsubscribe=One-Click"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	unsubscribe.NewMockRequest(request)
+	if responseStatus := unsubscribe.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	if e := unsubscribe.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestPostEmailChange(t *testing.T) {
+	id := uuid.NewString()
+
+	testCases := []struct {
+		name  string
+		email string
+		taken bool
+	}{
+		{"(+) Test case 1 -> new email -> enqueues confirmation", "new@example.com", false},
+		{"(-) Test case 2 -> subscribed email -> rejected", "new@example.com", true},
+		{"(-) Test case 3 -> current email -> rejected", "user@example.com", false},
+		{"(-) Test case 4 -> invalid email -> rejected", "new@", false},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.POST("/preferences/:token/email", func(c *gin.Context) { routes.PostEmailChange(c, app.DH, app.Signer) })
+
+		expectPreferences(app, id)
+		if tc.email == "new@example.com" {
+			app.Database.ExpectQuery("SELECT EXISTS").
+				WithArgs(tc.email).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(tc.taken))
+		}
+		if tc.email == "new@example.com" && !tc.taken {
+			app.Database.ExpectBegin()
+			app.Database.ExpectExec("DELETE FROM email_change_requests WHERE normalize_email\\(subscriber_email\\)").
+				WithArgs("user@example.com").
+				WillReturnResult(pgxmock.NewResult("DELETE", 0))
+			app.Database.ExpectExec("INSERT INTO email_change_requests").
+				WithArgs(pgxmock.AnyArg(), "user@example.com", tc.email).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
+				WithArgs("00000000-0000-0000-0000-000000000001", lists.DefaultListID, tc.email).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			app.Database.ExpectCommit()
+		}
+
+		form := url.Values{"email": {tc.email}}
+		request, _ := http.NewRequest("POST", "/preferences/"+app.Signer.Sign(signing.PurposePreferences, id)+"/email", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusSeeOther {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, http.StatusSeeOther, responseStatus)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	testCases := []struct {
+		name           string
+		found          bool
+		taken          bool
+		expectedStatus int
+	}{
+		{"(+) Test case 1 -> pending request -> changes email", true, false, http.StatusAccepted},
+		{"(-) Test case 2 -> expired request -> fails", false, false, http.StatusNotFound},
+		{"(-) Test case 3 -> address taken since request -> fails", true, true, http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.GET("/confirm-email/:token", func(c *gin.Context) { routes.ConfirmEmailChange(c, app.DH) })
+
+		rows := pgxmock.NewRows([]string{"subscriber_email", "new_email"})
+		if tc.found {
+			rows.AddRow(models.SubscriberEmail("user@example.com"), models.SubscriberEmail("new@example.com"))
+		}
+
+		app.Database.ExpectBegin()
+		app.Database.ExpectQuery("SELECT subscriber_email, new_email FROM email_change_requests").
+			WithArgs("token", pgxmock.AnyArg()).
+			WillReturnRows(rows)
+		if tc.found {
+			app.Database.ExpectQuery("SELECT EXISTS").
+				WithArgs("new@example.com").
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(tc.taken))
+		}
+		if tc.found && !tc.taken {
+			app.Database.ExpectExec("UPDATE subscriptions SET email = \\$2 WHERE normalized_email = normalize_email\\(\\$1\\)").
+				WithArgs("user@example.com", "new@example.com").
+				WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+			app.Database.ExpectExec("UPDATE issue_delivery_queue SET subscriber_email = \\$2 WHERE normalize_email\\(subscriber_email\\)").
+				WithArgs("user@example.com", "new@example.com").
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			for _, table := range []string{"delivery_log", "engagement_events", "bounces", "unsubscribe_events", "subscription_status_history"} {
+				app.Database.ExpectExec("UPDATE "+table+" SET subscriber_email").
+					WithArgs("user@example.com", "new@example.com").
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			}
+			app.Database.ExpectExec("UPDATE email_change_requests SET confirmed").
+				WithArgs("token").
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			app.Database.ExpectExec("DELETE FROM email_change_requests WHERE normalize_email\\(subscriber_email\\)").
+				WithArgs("user@example.com").
+				WillReturnResult(pgxmock.NewResult("DELETE", 0))
+			app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
+				WithArgs("00000000-0000-0000-0000-000000000002", lists.DefaultListID, "user@example.com").
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			app.Database.ExpectCommit()
+		} else {
+			app.Database.ExpectRollback()
+		}
+
+		request, _ := http.NewRequest("GET", "/confirm-email/token", nil)
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != tc.expectedStatus {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, tc.expectedStatus, responseStatus)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
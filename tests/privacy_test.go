@@ -0,0 +1,223 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/privacy"
+	"github.com/solomonbaez/hyacinth/api/routes"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func TestHashEmail(t *testing.T) {
+	hash := models.HashEmail("user@example.com")
+	if len(hash) != 64 {
+		t.Errorf("Expected a hex encoded sha256, got: %s", hash)
+	}
+	if models.HashEmail(" user@Example.COM ") != hash {
+		t.Errorf("Expected hash to ignore domain case and surrounding whitespace")
+	}
+	if models.HashEmail("User@example.com") == hash {
+		t.Errorf("Expected hash to keep the case of the local part")
+	}
+	if models.HashEmail("other@example.com") == hash {
+		t.Errorf("Expected distinct emails to hash differently")
+	}
+}
+
+func expectArchive(app utils.App, id string) {
+	now := time.Now()
+	consent := "signup form"
+	app.Database.ExpectQuery("SELECT (.+) FROM subscriptions JOIN lists").
+		WithArgs("user@example.com").
+		WillReturnRows(
+			pgxmock.NewRows([]string{"id", "list_id", "list_name", "name", "status", "created", "consent_source", "consented_at", "paused_until", "timezone", "attributes", "tags"}).
+				AddRow(id, lists.DefaultListID, "Newsletter", models.SubscriberName("user"), "confirmed", now, &consent, &now, nil, (*string)(nil), models.Attributes{"company": "Acme"}, []string{"vip"}),
+		)
+	app.Database.ExpectQuery("SELECT subscription_token, subscriber_id FROM subscription_tokens").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"subscription_token", "subscriber_id"}).AddRow("token", id))
+	app.Database.ExpectQuery("SELECT (.+) FROM email_change_requests").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"subscriber_email", "new_email", "created", "confirmed"}))
+	app.Database.ExpectQuery("SELECT (.+) FROM delivery_log").
+		WithArgs("user@example.com").
+		WillReturnRows(
+			pgxmock.NewRows([]string{"newsletter_issue_id", "list_id", "title", "status", "sent"}).
+				AddRow(uuid.NewString(), lists.DefaultListID, "Welcome", "sent", &now),
+		)
+	app.Database.ExpectQuery("SELECT (.+) FROM bounces").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"bounce_id", "message_id", "delivery_id", "newsletter_issue_id", "list_id", "subscriber_email", "kind", "status", "diagnostic", "received"}))
+	app.Database.ExpectQuery("SELECT (.+) FROM engagement_events").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"event_id", "delivery_id", "newsletter_issue_id", "list_id", "subscriber_email", "type", "url", "created"}))
+	app.Database.ExpectQuery("SELECT (.+) FROM unsubscribe_events").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"list_id", "newsletter_issue_id", "created"}))
+	app.Database.ExpectQuery("SELECT (.+) FROM subscription_status_history").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"list_id", "previous_status", "status", "changed"}))
+	app.Database.ExpectQuery("SELECT (.+) FROM audit_log").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"audit_id", "actor", "action", "subscriber_id", "details", "created"}))
+	app.Database.ExpectQuery("SELECT EXISTS (.+) suppressions").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+}
+
+func checkArchive(t *testing.T, app utils.App) {
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Fatalf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	if disposition := app.Recorder.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment") {
+		t.Errorf("Expected an attachment, got: %s", disposition)
+	}
+
+	var archive models.DataArchive
+	if e := json.Unmarshal(app.Recorder.Body.Bytes(), &archive); e != nil {
+		t.Fatalf("Failed to decode archive: %v", e)
+	}
+	if archive.Email != "user@example.com" || len(archive.Subscriptions) != 1 || len(archive.Tokens) != 1 || len(archive.Deliveries) != 1 {
+		t.Errorf("Unexpected archive: %+v", archive)
+	}
+	if subscription := archive.Subscriptions[0]; len(subscription.Tags) != 1 || subscription.Attributes["company"] != "Acme" {
+		t.Errorf("Unexpected subscription: %+v", subscription)
+	}
+}
+
+func TestGetSubscriberData(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.GET("/subscribers/:id/data", func(c *gin.Context) { adminRoutes.GetSubscriberData(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	expectSubscriber(app, id, "confirmed")
+	expectArchive(app, id)
+	app.Database.ExpectExec("INSERT INTO audit_log").
+		WithArgs(pgxmock.AnyArg(), "unknown", models.AuditActionExport, id, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	request, _ := http.NewRequest("GET", "/subscribers/"+id+"/data", nil)
+	app.NewMockRequest(request)
+
+	checkArchive(t, app)
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestPostDataExport(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.POST("/preferences/:token/data", func(c *gin.Context) { routes.PostDataExport(c, app.DH, app.Signer) })
+	defer app.Database.Close(app.Context)
+
+	expectPreferences(app, id)
+	app.Database.ExpectBegin()
+	app.Database.ExpectExec("DELETE FROM data_export_requests").
+		WithArgs(id).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	app.Database.ExpectExec("INSERT INTO data_export_requests").
+		WithArgs(pgxmock.AnyArg(), id).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
+		WithArgs(privacy.DataExportIssueID, lists.DefaultListID, "user@example.com").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectCommit()
+
+	request, _ := http.NewRequest("POST", "/preferences/"+app.Signer.Sign(signing.PurposePreferences, id)+"/data", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusSeeOther {
+		t.Errorf("Expected status code %v, but got %v", http.StatusSeeOther, responseStatus)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestGetDataExport(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.GET("/data-export/:token", func(c *gin.Context) { routes.GetDataExport(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	app.Database.ExpectQuery("SELECT (.+) FROM data_export_requests").
+		WithArgs("token", "1 hour").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "email"}).AddRow(id, models.SubscriberEmail("user@example.com")))
+	expectArchive(app, id)
+	app.Database.ExpectExec("INSERT INTO audit_log").
+		WithArgs(pgxmock.AnyArg(), models.AuditActorSubscriber, models.AuditActionExport, id, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	request, _ := http.NewRequest("GET", "/data-export/token", nil)
+	app.NewMockRequest(request)
+
+	checkArchive(t, app)
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+
+	expired := utils.NewMockApp()
+	expired.Router.GET("/data-export/:token", func(c *gin.Context) { routes.GetDataExport(c, expired.DH) })
+	expired.Database.ExpectQuery("SELECT (.+) FROM data_export_requests").
+		WithArgs("expired", "1 hour").
+		WillReturnError(pgx.ErrNoRows)
+	request, _ = http.NewRequest("GET", "/data-export/expired", nil)
+	expired.NewMockRequest(request)
+	if responseStatus := expired.Recorder.Code; responseStatus != http.StatusNotFound {
+		t.Errorf("Expected status code %v for an expired token, but got %v", http.StatusNotFound, responseStatus)
+	}
+}
+
+func TestPostSubscriberErase(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.POST("/subscribers/:id/erase", func(c *gin.Context) { adminRoutes.PostSubscriberErase(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	expectSubscriber(app, id, "confirmed")
+	app.Database.ExpectBegin()
+	for _, table := range []string{"subscription_tokens", "subscriber_tags", "audit_log", "issue_delivery_queue", "bounces", "engagement_events", "delivery_log", "unsubscribe_events", "subscription_status_history", "subscriptions"} {
+		app.Database.ExpectExec("DELETE FROM " + table).
+			WithArgs("user@example.com").
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	}
+	app.Database.ExpectExec("UPDATE suppressions SET email = NULL").
+		WithArgs("user@example.com").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	app.Database.ExpectExec("DELETE FROM email_change_requests (.+) email_change_requests.confirmed IS NOT NULL").
+		WithArgs("user@example.com").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	app.Database.ExpectExec("INSERT INTO suppressions (.+) ON CONFLICT").
+		WithArgs(pgxmock.AnyArg(), models.HashEmail("user@example.com"), "erased", models.SuppressionSourceErasure).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAudit(app, models.AuditActionErase)
+
+	request, _ := http.NewRequest("POST", "/subscribers/"+id+"/erase", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusSeeOther {
+		t.Errorf("Expected status code %v, but got %v", http.StatusSeeOther, responseStatus)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -0,0 +1,104 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+// growthRows returns two days of status changes ending on day
+func growthRows(day time.Time) *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"day", "subscriptions", "confirmations", "unsubscribes", "bounces", "net_growth"}).
+		AddRow(day.AddDate(0, 0, -1), 4, 3, 0, 0, 3).
+		AddRow(day, 4, 1, 2, 1, -2)
+}
+
+func TestParseReportRange(t *testing.T) {
+	testCases := []struct {
+		raw           string
+		days          int
+		expectedError bool
+	}{
+		{"", models.DefaultReportRange, false},
+		{"90", 90, false},
+		{"14", 0, true},
+		{"month", 0, true},
+	}
+
+	for _, tc := range testCases {
+		days, e := models.ParseReportRange(tc.raw)
+		if (e != nil) != tc.expectedError || (e == nil && days != tc.days) {
+			t.Errorf("%q: expected %d, got %d, %v", tc.raw, tc.days, days, e)
+		}
+	}
+}
+
+func TestGrowthReportChart(t *testing.T) {
+	report := &models.GrowthReport{Series: []*models.GrowthDay{{NetGrowth: 4}, {NetGrowth: -2}, {}}}
+
+	bars := report.Chart("net_growth")
+	if len(bars) != 3 || bars[0].Height != 100 || bars[1].Height != 50 || !bars[1].Negative || bars[2].Height != 0 {
+		t.Errorf("Expected bars scaled to the largest day, got: %+v %+v %+v", bars[0], bars[1], bars[2])
+	}
+}
+
+func TestGetGrowthReport(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/reports/growth", func(c *gin.Context) { adminRoutes.GetGrowthReport(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	listID := lists.DefaultListID
+	app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+		WithArgs("default").
+		WillReturnRows(defaultListRows())
+	app.Database.ExpectQuery("SELECT (.+) FROM generate_series").
+		WithArgs(7, &listID).
+		WillReturnRows(growthRows(time.Now().UTC().Truncate(24 * time.Hour)))
+
+	request, _ := http.NewRequest("GET", "/reports/growth?days=7&list=default", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Fatalf("Expected status code %v, but got %v: %s", http.StatusOK, responseStatus, app.Recorder.Body.String())
+	}
+
+	var body struct {
+		Report models.GrowthReport `json:"report"`
+	}
+	if e := json.Unmarshal(app.Recorder.Body.Bytes(), &body); e != nil {
+		t.Fatalf("Failed to decode report: %v", e)
+	}
+
+	report := body.Report
+	if report.Totals.Subscriptions != 8 || report.Totals.Confirmations != 4 || report.Totals.NetGrowth != 1 {
+		t.Errorf("Unexpected totals: %+v", report.Totals)
+	}
+	if report.ConfirmationRate != 0.5 || len(report.Series) != 2 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestGetGrowthReport_InvalidRange(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/reports/growth", func(c *gin.Context) { adminRoutes.GetGrowthReport(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	request, _ := http.NewRequest("GET", "/reports/growth?days=-1", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusBadRequest {
+		t.Errorf("Expected status code %v, but got %v", http.StatusBadRequest, responseStatus)
+	}
+}
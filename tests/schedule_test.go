@@ -0,0 +1,170 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/routes"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name      string
+		sendAt    string
+		timezone  string
+		localTime bool
+		valid     bool
+	}{
+		{"(+) Test case 1 -> upcoming send time -> passes", "2024-03-02T09:00", "Europe/Berlin", false, true},
+		{"(+) Test case 2 -> empty zone defaults to UTC -> passes", "2024-03-02T09:00", "", false, true},
+		{"(-) Test case 3 -> passed send time -> fails", "2024-03-01T09:00", "UTC", false, false},
+		{"(+) Test case 4 -> local time still ahead somewhere -> passes", "2024-03-01T09:00", "UTC", true, true},
+		{"(-) Test case 5 -> local time passed everywhere -> fails", "2024-02-29T09:00", "UTC", true, false},
+		{"(-) Test case 6 -> unknown zone -> fails", "2024-03-02T09:00", "Mars/Olympus", false, false},
+		{"(-) Test case 7 -> server zone -> fails", "2024-03-02T09:00", "Local", false, false},
+		{"(-) Test case 8 -> invalid send time -> fails", "tomorrow", "UTC", false, false},
+		{"(-) Test case 9 -> local time without send time -> fails", "", "UTC", true, false},
+	}
+
+	for _, tc := range testCases {
+		schedule, e := models.ParseSchedule(tc.sendAt, tc.timezone, tc.localTime, now)
+		if tc.valid && (e != nil || schedule == nil) {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+		}
+		if !tc.valid && e == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+
+	if schedule, e := models.ParseSchedule("", "", false, now); e != nil || schedule != nil {
+		t.Errorf("Expected no schedule without a send time")
+	}
+
+	schedule, _ := models.ParseSchedule("2024-03-02T09:00", "Europe/Berlin", false, now)
+	if expected := time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC); !schedule.Instant().Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, schedule.Instant())
+	}
+}
+
+func TestPostNewsletter_LocalTime(t *testing.T) {
+	app := utils.NewMockApp()
+	admin := app.Router.Group("/admin")
+	admin.POST("/newsletter", func(c *gin.Context) { adminRoutes.PostNewsletter(c, app.DH, app.Client, app.Attachments) })
+	defer app.Database.Close(app.Context)
+
+	sendAt := time.Now().Add(48 * time.Hour).Format("2006-01-02T15:04")
+	wallClock, _ := time.Parse("2006-01-02T15:04", sendAt)
+	timezone := "America/New_York"
+
+	data := url.Values{
+		"title":         {"test"},
+		"text":          {"test"},
+		"html":          {"test"},
+		"send_at":       {sendAt},
+		"send_timezone": {timezone},
+		"local_time":    {"on"},
+	}
+	request, _ := http.NewRequest("POST", "/admin/newsletter", strings.NewReader(data.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+		WithArgs("default").
+		WillReturnRows(defaultListRows())
+	app.Database.ExpectBegin()
+	app.Database.ExpectExec("INSERT INTO idempotency").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectExec("INSERT INTO idempotency_headers").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectExec("INSERT INTO newsletter_issues").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), false, false, &wallClock, &timezone, true).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectExec("INSERT INTO issue_delivery_queue (.+) AT TIME ZONE COALESCE\\(subscriptions.timezone, \\$4\\)").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), wallClock, timezone).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectCommit()
+	app.Database.ExpectBegin()
+	app.Database.ExpectExec("UPDATE idempotency SET").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	app.Database.ExpectExec("UPDATE idempotency_headers SET").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	app.Database.ExpectCommit()
+
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusSeeOther {
+		t.Errorf("Expected status code %v, but got %v: %s", http.StatusSeeOther, responseStatus, app.Recorder.Body.String())
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestPostNewsletter_InvalidSchedule(t *testing.T) {
+	sendAt := time.Now().Add(48 * time.Hour).Format("2006-01-02T15:04")
+	testCases := []struct {
+		name string
+		form url.Values
+	}{
+		{"(-) Test case 1 -> passed send time -> fails", url.Values{"send_at": {"2000-01-01T09:00"}}},
+		{"(-) Test case 2 -> unknown zone -> fails", url.Values{"send_at": {sendAt}, "send_timezone": {"Mars/Olympus"}}},
+		{
+			"(-) Test case 3 -> subject test in local time -> fails",
+			url.Values{"send_at": {sendAt}, "local_time": {"on"}, "track_opens": {"on"}, "subject_variants": {"other"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		admin := app.Router.Group("/admin")
+		admin.POST("/newsletter", func(c *gin.Context) { adminRoutes.PostNewsletter(c, app.DH, app.Client, app.Attachments) })
+
+		tc.form.Set("title", "test")
+		tc.form.Set("text", "test")
+		tc.form.Set("html", "test")
+		request, _ := http.NewRequest("POST", "/admin/newsletter", strings.NewReader(tc.form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, http.StatusBadRequest, responseStatus)
+		}
+		app.Database.Close(app.Context)
+	}
+}
+
+func TestSubscribe_InvalidTimezone(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.POST("/subscribe", func(c *gin.Context) { routes.Subscribe(c, app.DH, nil) })
+	defer app.Database.Close(app.Context)
+
+	app.Database.ExpectBegin()
+	app.Database.ExpectRollback()
+
+	body, _ := json.Marshal(map[string]string{"email": "user@example.com", "name": "user", "timezone": "Mars/Olympus"})
+	request, _ := http.NewRequest("POST", "/subscribe", bytes.NewBuffer(body))
+	request.Header.Set("Content-Type", "application/json")
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusBadRequest {
+		t.Errorf("Expected status code %v, but got %v", http.StatusBadRequest, responseStatus)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
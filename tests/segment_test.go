@@ -0,0 +1,269 @@
+package api_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/segments"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func TestParseTag(t *testing.T) {
+	testCases := []string{
+		"", " ", "a b", "<tag>", strings.Repeat("a", 51),
+	}
+
+	for _, tc := range testCases {
+		if s, e := models.ParseTag(tc); e == nil {
+			t.Errorf("Expected %q to be rejected, got: %s", tc, s)
+		}
+	}
+
+	if s, e := models.ParseTag(" VIP "); e != nil || s != "vip" {
+		t.Errorf("Expected vip, got: %s, %v", s, e)
+	}
+}
+
+func TestParseSegment(t *testing.T) {
+	testCases := []struct {
+		name          string
+		segment       models.Segment
+		expectedError bool
+	}{
+		{
+			"(+) Test case 1 -> tag and created rules -> passes",
+			models.Segment{
+				Name: "recent vips",
+				Rules: []*models.SegmentRule{
+					{Field: "tag", Operator: "has", Value: "vip"},
+					{Field: "created", Operator: "after", Value: "2023-01-01"},
+				},
+			},
+			false,
+		},
+		{
+			"(-) Test case 2 -> no rules -> fails",
+			models.Segment{Name: "empty"},
+			true,
+		},
+		{
+			"(-) Test case 3 -> unknown field -> fails",
+			models.Segment{
+				Name:  "unknown",
+				Rules: []*models.SegmentRule{{Field: "email", Operator: "has", Value: "a"}},
+			},
+			true,
+		},
+		{
+			"(-) Test case 4 -> invalid operator -> fails",
+			models.Segment{
+				Name:  "operator",
+				Rules: []*models.SegmentRule{{Field: "created", Operator: "has", Value: "2023-01-01"}},
+			},
+			true,
+		},
+		{
+			"(-) Test case 5 -> invalid match -> fails",
+			models.Segment{
+				Name:  "match",
+				Match: "some",
+				Rules: []*models.SegmentRule{{Field: "tag", Operator: "has", Value: "vip"}},
+			},
+			true,
+		},
+		{
+			"(-) Test case 6 -> invalid name -> fails",
+			models.Segment{
+				Name:  "<script>",
+				Rules: []*models.SegmentRule{{Field: "tag", Operator: "has", Value: "vip"}},
+			},
+			true,
+		},
+		{
+			"(+) Test case 7 -> engagement rules -> passes",
+			models.Segment{
+				Name: "engaged",
+				Rules: []*models.SegmentRule{
+					{Field: "engagement", Operator: "within_days", Value: " 30 "},
+					{Field: "engagement", Operator: "not_in_last_issues", Value: "5"},
+				},
+			},
+			false,
+		},
+		{
+			"(-) Test case 8 -> engagement without a count -> fails",
+			models.Segment{
+				Name:  "engaged",
+				Rules: []*models.SegmentRule{{Field: "engagement", Operator: "within_days", Value: "0"}},
+			},
+			true,
+		},
+		{
+			"(-) Test case 9 -> engagement over the issue limit -> fails",
+			models.Segment{
+				Name:  "engaged",
+				Rules: []*models.SegmentRule{{Field: "engagement", Operator: "in_last_issues", Value: "1000"}},
+			},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		if e := models.ParseSegment(&tc.segment); (e != nil) != tc.expectedError {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+		}
+	}
+}
+
+func TestRecipientFilter(t *testing.T) {
+	filter, args, e := segments.RecipientFilter("list", nil, []interface{}{"issue"})
+	expected := "subscriptions.list_id = $2 AND subscriptions.status = 'confirmed'" +
+		" AND (subscriptions.paused_until IS NULL OR subscriptions.paused_until <= now())" +
+		" AND NOT " + suppressions.Matches("subscriptions.email")
+	if e != nil || filter != expected || len(args) != 2 {
+		t.Errorf("Expected unfiltered audience, got: %s, %v, %v", filter, args, e)
+	}
+
+	audience := []*models.Segment{
+		{
+			Name:  "vips",
+			Match: models.SegmentMatchAll,
+			Rules: []*models.SegmentRule{
+				{Field: "tag", Operator: "has", Value: "VIP"},
+				{Field: "tag", Operator: "not_has", Value: "churned"},
+			},
+		},
+		{
+			Name:  "new",
+			Match: models.SegmentMatchAny,
+			Rules: []*models.SegmentRule{
+				{Field: "created", Operator: "after", Value: "2023-01-01"},
+			},
+		},
+	}
+
+	filter, args, e = segments.RecipientFilter("list", audience, []interface{}{"issue"})
+	if e != nil {
+		t.Fatalf("Failed to build filter: %s", e)
+	}
+
+	if len(args) != 5 || args[1] != "list" || args[2] != "vip" || args[3] != "churned" || args[4] != "2023-01-01T00:00:00Z" {
+		t.Errorf("Unexpected arguments: %v", args)
+	}
+	for _, expected := range []string{"$3", "$4", "$5", "NOT EXISTS", " OR "} {
+		if !strings.Contains(filter, expected) {
+			t.Errorf("Expected filter to contain %q, got: %s", expected, filter)
+		}
+	}
+}
+
+func TestBuildFilter_Engagement(t *testing.T) {
+	segment := &models.Segment{
+		Name:  "engaged",
+		Match: models.SegmentMatchAny,
+		Rules: []*models.SegmentRule{
+			{Field: "engagement", Operator: "within_days", Value: "30"},
+			{Field: "engagement", Operator: "not_in_last_issues", Value: "3"},
+		},
+	}
+
+	filter, args, e := segments.BuildFilter(segment, []interface{}{"list"})
+	if e != nil {
+		t.Fatalf("Failed to build filter: %s", e)
+	}
+	if len(args) != 3 || args[1] != "30" || args[2] != "3" {
+		t.Errorf("Unexpected arguments: %v", args)
+	}
+	for _, expected := range []string{"make_interval(days => $2::int)", "NOT EXISTS", "LIMIT $3::int", "track_opens", " OR "} {
+		if !strings.Contains(filter, expected) {
+			t.Errorf("Expected filter to contain %q, got: %s", expected, filter)
+		}
+	}
+}
+
+func TestGetSegmentPreview(t *testing.T) {
+	segmentID := uuid.NewString()
+
+	testCases := []struct {
+		name           string
+		url            string
+		segments       bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			"(+) Test case 1 -> GET preview without segments -> passes",
+			"/admin/segments/preview",
+			false,
+			http.StatusOK,
+			`{"recipients":3,"requestID":""}`,
+		},
+		{
+			"(+) Test case 2 -> GET preview with a segment -> passes",
+			"/admin/segments/preview?segment=" + segmentID,
+			true,
+			http.StatusOK,
+			`{"recipients":3,"requestID":""}`,
+		},
+		{
+			"(-) Test case 3 -> GET preview with an invalid segment ID -> fails",
+			"/admin/segments/preview?segment=invalid",
+			false,
+			http.StatusBadRequest,
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		admin := app.Router.Group("/admin")
+		admin.GET("/segments/preview", func(c *gin.Context) { adminRoutes.GetSegmentPreview(c, app.DH) })
+
+		request, _ := http.NewRequest("GET", tc.url, nil)
+
+		app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+			WithArgs("default").
+			WillReturnRows(defaultListRows())
+
+		if tc.segments {
+			app.Database.ExpectQuery("SELECT segment_id, name, match, rules FROM segments").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnRows(
+					pgxmock.NewRows([]string{"segment_id", "name", "match", "rules"}).
+						AddRow(segmentID, "vips", "all", []*models.SegmentRule{{Field: "tag", Operator: "has", Value: "vip"}}),
+				)
+			app.Database.ExpectQuery("SELECT count").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(3))
+		} else {
+			app.Database.ExpectQuery("SELECT count").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(3))
+		}
+
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != tc.expectedStatus {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, tc.expectedStatus, responseStatus)
+		}
+		if tc.expectedBody != "" && app.Recorder.Body.String() != tc.expectedBody {
+			t.Errorf("%s: expected body %v, but got %v", tc.name, tc.expectedBody, app.Recorder.Body.String())
+		}
+
+		app.Database.Close(app.Context)
+	}
+}
+
+func defaultListRows() *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"list_id", "slug", "name", "sender_name", "sender_email", "confirmation_issue_id", "disposable_policy", "role_policy"}).
+		AddRow(lists.DefaultListID, "default", "Newsletter", nil, nil, lists.BaseConfirmationIssueID, models.BlockPolicyAllow, models.BlockPolicyAllow)
+}
@@ -0,0 +1,165 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/routes"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/workers"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+func testSequenceStep(delay int) *models.SequenceStep {
+	return &models.SequenceStep{
+		DelayHours: delay,
+		Content:    &models.Body{Title: "Welcome", Text: "Hi {{.name}}", Html: "<p>Hi {{.name}}</p>"},
+	}
+}
+
+func TestParseSequence(t *testing.T) {
+	testCases := []struct {
+		name          string
+		sequence      models.Sequence
+		expectedError bool
+	}{
+		{
+			"(+) Test case 1 -> welcome email and follow ups -> passes",
+			models.Sequence{Name: "Onboarding", Steps: []*models.SequenceStep{testSequenceStep(0), testSequenceStep(24), testSequenceStep(72)}},
+			false,
+		},
+		{
+			"(-) Test case 2 -> no steps -> fails",
+			models.Sequence{Name: "Onboarding"},
+			true,
+		},
+		{
+			"(-) Test case 3 -> step scheduled before its predecessor -> fails",
+			models.Sequence{Name: "Onboarding", Steps: []*models.SequenceStep{testSequenceStep(24), testSequenceStep(0)}},
+			true,
+		},
+		{
+			"(-) Test case 4 -> step without content -> fails",
+			models.Sequence{Name: "Onboarding", Steps: []*models.SequenceStep{{DelayHours: 0}}},
+			true,
+		},
+		{
+			"(-) Test case 5 -> invalid template -> fails",
+			models.Sequence{Name: "Onboarding", Steps: []*models.SequenceStep{{Content: &models.Body{Title: "Hi", Text: "{{.name", Html: "<p>Hi</p>"}}}},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		if e := models.ParseSequence(&tc.sequence); (e != nil) != tc.expectedError {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+		}
+	}
+
+	sequence := models.Sequence{Name: "Onboarding", Steps: []*models.SequenceStep{testSequenceStep(0), testSequenceStep(24)}}
+	models.ParseSequence(&sequence)
+	if sequence.Steps[0].Position != 1 || sequence.Steps[1].Position != 2 {
+		t.Errorf("Expected steps to be numbered in order")
+	}
+}
+
+func TestPostSequence(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.POST("/sequences", func(c *gin.Context) { adminRoutes.PostSequence(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT (.+) FROM lists WHERE slug").
+		WithArgs("default").
+		WillReturnRows(defaultListRows())
+	app.Database.ExpectExec("INSERT INTO sequences").
+		WithArgs(pgxmock.AnyArg(), lists.DefaultListID, "Onboarding").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	for position, delay := range []int{0, 48} {
+		app.Database.ExpectExec("INSERT INTO newsletter_issues").
+			WithArgs(pgxmock.AnyArg(), "Welcome", pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		app.Database.ExpectExec("INSERT INTO sequence_steps").
+			WithArgs(pgxmock.AnyArg(), position+1, pgxmock.AnyArg(), delay).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	}
+	app.Database.ExpectCommit()
+
+	step := `{"title": "Welcome", "text": "Hi {{.name}}", "html": "<p>Hi {{.name}}</p>"}`
+	data := fmt.Sprintf(`{"name": "Onboarding", "steps": [{"delay_hours": 0, "content": %s}, {"delay_hours": 48, "content": %s}]}`, step, step)
+	request, _ := http.NewRequest("POST", "/sequences", strings.NewReader(data))
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusCreated {
+		t.Errorf("Expected status code %v, but got %v: %s", http.StatusCreated, responseStatus, app.Recorder.Body.String())
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestConfirmSubscriber_Repeated(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.GET("/confirm/:token", func(c *gin.Context) { routes.ConfirmSubscriber(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	// sequences are not restarted by revisiting the confirmation link
+	id := uuid.NewString()
+	app.Database.ExpectQuery("SELECT subscriber_id FROM subscription_tokens WHERE").
+		WithArgs("token").
+		WillReturnRows(pgxmock.NewRows([]string{"subscriber_id"}).AddRow(id))
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("UPDATE subscriptions SET status = 'confirmed'").
+		WithArgs(id).
+		WillReturnRows(pgxmock.NewRows([]string{"status"}).AddRow("confirmed"))
+	app.Database.ExpectCommit()
+
+	request, _ := http.NewRequest("GET", "/confirm/token", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusAccepted {
+		t.Errorf("Expected status code %v, but got %v", http.StatusAccepted, responseStatus)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestTryExecuteTask_SequenceStopped(t *testing.T) {
+	app := utils.NewMockApp()
+	defer app.Database.Close(app.Context)
+
+	issueID, sequenceID := uuid.NewString(), uuid.NewString()
+	app.Database.ExpectBegin()
+	app.Database.ExpectQuery("SELECT (.+) FROM issue_delivery_queue WHERE execute_after <= now()").
+		WillReturnRows(
+			pgxmock.NewRows([]string{"newsletter_issue_id", "list_id", "subscriber_email", "sequence_id", "variant"}).
+				AddRow(issueID, lists.DefaultListID, models.SubscriberEmail("user@example.com"), &sequenceID, (*int)(nil)),
+		)
+	app.Database.ExpectQuery("SELECT (.+) FROM suppressions").
+		WithArgs("user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	app.Database.ExpectQuery("SELECT EXISTS (.+) status = 'confirmed'").
+		WithArgs(lists.DefaultListID, "user@example.com").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	app.Database.ExpectExec("DELETE FROM issue_delivery_queue").
+		WithArgs(issueID, lists.DefaultListID, "user@example.com").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	app.Database.ExpectCommit()
+
+	outcome := workers.TryExecuteTask(app.Context, app.DH, app.Client, app.Signer, nil, nil, nil)
+	if outcome != workers.ExecutionOutcomeTaskCompleted {
+		t.Errorf("Expected unsubscribed sequence step to be dropped, got: %v", outcome)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -34,3 +34,58 @@ func TestParseName(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeEmail(t *testing.T) {
+	testCases := []struct {
+		email    models.SubscriberEmail
+		expected string
+	}{
+		{"User@Example.com", "User@example.com"},
+		{" user+news@example.com ", "user+news@example.com"},
+		{"First.Last+news@GoogleMail.com", "First.Last+news@googlemail.com"},
+		{`"a@b"@Example.com`, `"a@b"@example.com`},
+		{"user@ÉCOLE.Example", "user@École.example"},
+	}
+
+	for _, tc := range testCases {
+		if normalized := models.NormalizeEmail(tc.email); normalized != tc.expected {
+			t.Errorf("Expected %s to normalize to %s, got: %s", tc.email, tc.expected, normalized)
+		}
+	}
+}
+
+func TestParseEmail_International(t *testing.T) {
+	testCases := []struct {
+		email    string
+		expected string
+	}{
+		{"User@Example.com", "User@Example.com"},
+		{"o'brien+news@example.com", "o'brien+news@example.com"},
+		{`"john doe"@example.com`, `"john doe"@example.com`},
+		{`"john\"doe"@example.com`, `"john\"doe"@example.com`},
+		{"josé@example.com", "josé@example.com"},
+		{"user@bücher.de", "user@xn--bcher-kva.de"},
+		{"用户@例子.广告", "用户@xn--fsqu00a.xn--4rr70v"},
+	}
+
+	for _, tc := range testCases {
+		parsed, e := models.ParseEmail(tc.email)
+		if e != nil || parsed.String() != tc.expected {
+			t.Errorf("Expected %s to parse as %s, got: %s, %v", tc.email, tc.expected, parsed, e)
+		}
+	}
+
+	invalid := []string{
+		"a..b@example.com", ".a@example.com", "a.@example.com", `"unterminated@example.com`,
+		"a b@example.com", "user@-bad.com", "user@example.123", "user@localhost", "user@exa_mple.com",
+	}
+	for _, email := range invalid {
+		if parsed, e := models.ParseEmail(email); e == nil {
+			t.Errorf("Expected %s to be rejected, got: %s", email, parsed)
+		}
+	}
+
+	if !models.SubscriberEmail("josé@example.com").RequiresSMTPUTF8() || models.SubscriberEmail("user@xn--bcher-kva.de").RequiresSMTPUTF8() {
+		t.Errorf("Expected only non-ASCII local parts to require SMTPUTF8")
+	}
+}
@@ -0,0 +1,158 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/configs"
+	"github.com/solomonbaez/hyacinth/api/lists"
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/workers"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+var testSunsetSettings = &configs.SunsetSettings{
+	ScoreWindow:    10,
+	InactiveIssues: 5,
+	GracePeriod:    14,
+	Interval:       24,
+	Title:          "Still there?",
+	Text:           "Hi {{.name}}",
+	Html:           "<p>Hi {{.name}}</p>",
+}
+
+func TestEngagementScore(t *testing.T) {
+	testCases := []struct {
+		name     string
+		score    models.EngagementScore
+		expected *int
+	}{
+		{"(+) Test case 1 -> no tracked deliveries -> unscored", models.EngagementScore{}, nil},
+		{"(+) Test case 2 -> every delivery clicked -> 100", models.EngagementScore{Deliveries: 4, Opened: 4, Clicked: 4}, intPointer(100)},
+		{"(+) Test case 3 -> half opened, none clicked -> 25", models.EngagementScore{Deliveries: 4, Opened: 2}, intPointer(25)},
+		{"(+) Test case 4 -> nothing opened -> 0", models.EngagementScore{Deliveries: 4}, intPointer(0)},
+	}
+
+	for _, tc := range testCases {
+		tc.score.Compute()
+		if (tc.score.Score == nil) != (tc.expected == nil) || (tc.expected != nil && *tc.score.Score != *tc.expected) {
+			t.Errorf("%s: unexpected score %v", tc.name, tc.score.Score)
+		}
+	}
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func TestGetSubscriberEngagement(t *testing.T) {
+	id := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.GET("/subscribers/:id/engagement", func(c *gin.Context) {
+		adminRoutes.GetSubscriberEngagement(c, app.DH, testSunsetSettings)
+	})
+	defer app.Database.Close(app.Context)
+
+	lastEngaged := time.Now()
+	expectSubscriber(app, id, "confirmed")
+	app.Database.ExpectQuery("SELECT \\(SELECT delivery_log.sent (.+) FROM subscriptions LEFT JOIN LATERAL").
+		WithArgs(id, testSunsetSettings.ScoreWindow).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"reengagement_sent", "deliveries", "opened", "clicked", "last_engaged"}).
+				AddRow(nil, 10, 6, 2, &lastEngaged),
+		)
+
+	request, _ := http.NewRequest("GET", "/subscribers/"+id+"/engagement", nil)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Fatalf("Expected status code %v, but got %v: %s", http.StatusOK, responseStatus, app.Recorder.Body.String())
+	}
+
+	var body struct {
+		Engagement models.EngagementScore `json:"engagement"`
+	}
+	if e := json.Unmarshal(app.Recorder.Body.Bytes(), &body); e != nil {
+		t.Fatalf("Failed to decode engagement: %v", e)
+	}
+	if body.Engagement.Score == nil || *body.Engagement.Score != 40 {
+		t.Errorf("Expected a score of 40, got: %v", body.Engagement.Score)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestRunSunset(t *testing.T) {
+	app := utils.NewMockApp()
+	defer app.Database.Close(app.Context)
+
+	sunsetID, first, second := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	email := "Inactive@example.com"
+	hash := models.HashEmail(models.SubscriberEmail(email))
+	app.Database.ExpectBegin()
+	app.Database.ExpectExec("UPDATE subscriptions SET reengagement_sent = NULL, reengagement_issue_id = NULL WHERE reengagement_sent IS NOT NULL").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	app.Database.ExpectQuery("UPDATE subscriptions SET status = 'unsubscribed', (.+) AND \\(SELECT delivery_log.sent (.+)\\) <= now\\(\\) - make_interval").
+		WithArgs(testSunsetSettings.GracePeriod).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "email"}).AddRow(sunsetID, email))
+	app.Database.ExpectExec("INSERT INTO suppressions").
+		WithArgs(
+			pgxmock.AnyArg(),
+			[]*string{&email},
+			[]*string{&hash},
+			[]*string{nil},
+			[]string{"inactive"},
+			[]string{models.SuppressionSourceSunset},
+		).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectExec("INSERT INTO audit_log").
+		WithArgs(pgxmock.AnyArg(), models.AuditActorSunset, models.AuditActionSunset, sunsetID, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectQuery("SELECT subscriptions.id, subscriptions.list_id FROM subscriptions JOIN LATERAL").
+		WithArgs(testSunsetSettings.InactiveIssues).
+		WillReturnRows(
+			pgxmock.NewRows([]string{"id", "list_id"}).
+				AddRow(first, lists.DefaultListID).
+				AddRow(second, lists.DefaultListID),
+		)
+	app.Database.ExpectExec("INSERT INTO newsletter_issues").
+		WithArgs(pgxmock.AnyArg(), "Still there?", "Hi {{.name}}", "<p>Hi {{.name}}</p>", lists.DefaultListID).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	app.Database.ExpectExec("INSERT INTO issue_delivery_queue").
+		WithArgs(pgxmock.AnyArg(), []string{first, second}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	app.Database.ExpectExec("UPDATE subscriptions SET reengagement_sent = now\\(\\), reengagement_issue_id = \\$2").
+		WithArgs([]string{first, second}, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	app.Database.ExpectCommit()
+
+	if e := workers.RunSunset(app.Context, app.DH, testSunsetSettings); e != nil {
+		t.Errorf("Failed to run sunset policy: %s", e)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
+
+func TestRunSunset_Disabled(t *testing.T) {
+	app := utils.NewMockApp()
+	defer app.Database.Close(app.Context)
+
+	disabled := *testSunsetSettings
+	disabled.InactiveIssues = 0
+	if e := workers.RunSunset(app.Context, app.DH, &disabled); e != nil {
+		t.Errorf("Expected disabled policy to do nothing, got: %s", e)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -0,0 +1,212 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/models"
+	adminRoutes "github.com/solomonbaez/hyacinth/api/routes/admin"
+	"github.com/solomonbaez/hyacinth/api/suppressions"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+const testSuppressionCSV = "Entry,Reason\n" +
+	"Alice@Example.com,hard bounce\n" +
+	"@spam.example,\n" +
+	"Alice@EXAMPLE.com,complaint\n" +
+	"not a domain,\n"
+
+func TestParseSuppression(t *testing.T) {
+	testCases := []struct {
+		name          string
+		entry         string
+		reason        string
+		expectedKind  string
+		expectedKey   string
+		expectedError bool
+	}{
+		{"(+) Test case 1 -> email is trimmed", " User@Example.COM ", "complaint", models.SuppressionKindEmail, "User@Example.COM", false},
+		{"(+) Test case 2 -> bare domain", "Example.com", "spam trap", models.SuppressionKindDomain, "example.com", false},
+		{"(+) Test case 3 -> @domain", "@mail.example.com", "spam trap", models.SuppressionKindDomain, "mail.example.com", false},
+		{"(-) Test case 4 -> invalid email", "user@", "complaint", "", "", true},
+		{"(-) Test case 5 -> invalid domain", "not a domain", "complaint", "", "", true},
+		{"(-) Test case 6 -> empty reason", "user@example.com", " ", "", "", true},
+	}
+
+	for _, tc := range testCases {
+		suppression, e := models.ParseSuppression(tc.entry, tc.reason, models.SuppressionSourceAdmin)
+		if (e != nil) != tc.expectedError {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+			continue
+		}
+		if tc.expectedError {
+			continue
+		}
+
+		if suppression.Kind != tc.expectedKind {
+			t.Errorf("%s: expected kind %s, got: %s", tc.name, tc.expectedKind, suppression.Kind)
+		}
+		switch tc.expectedKind {
+		case models.SuppressionKindEmail:
+			if suppression.Email.String() != tc.expectedKey || *suppression.EmailHash != models.HashEmail(models.SubscriberEmail(tc.expectedKey)) {
+				t.Errorf("%s: unexpected email: %v", tc.name, suppression.Email)
+			}
+		case models.SuppressionKindDomain:
+			if *suppression.Domain != tc.expectedKey || suppression.Email != nil {
+				t.Errorf("%s: unexpected domain: %v", tc.name, suppression.Domain)
+			}
+		}
+	}
+}
+
+func TestParseSuppressionCSV(t *testing.T) {
+	entries, report, e := suppressions.ParseCSV(strings.NewReader(testSuppressionCSV), "default")
+	if e != nil {
+		t.Fatalf("Failed to parse csv: %s", e)
+	}
+
+	if len(entries) != 2 || entries[0].Reason != "hard bounce" || entries[1].Reason != "default" {
+		t.Errorf("Unexpected entries: %v", entries)
+	}
+	if report.Duplicates != 1 || len(report.Errors) != 1 || report.Errors[0].Row != 5 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	if _, _, e := suppressions.ParseCSV(strings.NewReader("email\n"), "default"); e == nil {
+		t.Errorf("Failed to reject csv without an entry column")
+	}
+}
+
+func expectApply(app utils.App) {
+	app.Database.ExpectExec("UPDATE subscriptions SET status = 'suppressed'").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	app.Database.ExpectExec("DELETE FROM issue_delivery_queue").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+}
+
+func TestPostSuppression(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           string
+		inserted       int64
+		expectedStatus int
+	}{
+		{"(+) Test case 1 -> domain -> created", `{"entry": "@spam.example", "reason": "spam trap"}`, 1, http.StatusCreated},
+		{"(-) Test case 2 -> already suppressed -> conflict", `{"entry": "user@example.com", "reason": "complaint"}`, 0, http.StatusConflict},
+		{"(-) Test case 3 -> invalid entry -> bad request", `{"entry": "user@", "reason": "complaint"}`, -1, http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.POST("/suppressions", func(c *gin.Context) { adminRoutes.PostSuppression(c, app.DH) })
+
+		if tc.inserted >= 0 {
+			app.Database.ExpectBegin()
+			app.Database.ExpectExec("INSERT INTO suppressions").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), models.SuppressionSourceAdmin).
+				WillReturnResult(pgxmock.NewResult("INSERT", tc.inserted))
+			if tc.inserted > 0 {
+				expectApply(app)
+				app.Database.ExpectCommit()
+			} else {
+				app.Database.ExpectRollback()
+			}
+		}
+
+		request, _ := http.NewRequest("POST", "/suppressions", strings.NewReader(tc.body))
+		request.Header.Set("Content-Type", "application/json")
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != tc.expectedStatus {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, tc.expectedStatus, responseStatus)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
+
+func TestDeleteSuppression(t *testing.T) {
+	testCases := []struct {
+		name           string
+		deleted        int64
+		exists         bool
+		expectedStatus int
+	}{
+		{"(+) Test case 1 -> deleted", 1, false, http.StatusOK},
+		{"(-) Test case 2 -> erasure tombstone -> conflict", 0, true, http.StatusConflict},
+		{"(-) Test case 3 -> missing -> not found", 0, false, http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		id := uuid.NewString()
+
+		app := utils.NewMockApp()
+		app.Router.DELETE("/suppressions/:id", func(c *gin.Context) { adminRoutes.DeleteSuppression(c, app.DH) })
+
+		app.Database.ExpectExec("DELETE FROM suppressions").
+			WithArgs(id, models.SuppressionSourceErasure).
+			WillReturnResult(pgxmock.NewResult("DELETE", tc.deleted))
+		if tc.deleted == 0 {
+			app.Database.ExpectQuery("SELECT EXISTS").
+				WithArgs(id).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(tc.exists))
+		}
+
+		request, _ := http.NewRequest("DELETE", "/suppressions/"+id, nil)
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != tc.expectedStatus {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, tc.expectedStatus, responseStatus)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
+
+func TestPostSuppressionImport(t *testing.T) {
+	app := utils.NewMockApp()
+	app.Router.POST("/suppressions/import", func(c *gin.Context) { adminRoutes.PostSuppressionImport(c, app.DH) })
+	defer app.Database.Close(app.Context)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "suppressions.csv")
+	part.Write([]byte(testSuppressionCSV))
+	writer.Close()
+
+	app.Database.ExpectBegin()
+	app.Database.ExpectExec("INSERT INTO suppressions (.+) unnest").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), []string{"hard bounce", "bulk import"}, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectApply(app)
+	app.Database.ExpectCommit()
+
+	request, _ := http.NewRequest("POST", "/suppressions/import", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+
+	var response struct {
+		Report models.SuppressionImportReport `json:"report"`
+	}
+	json.Unmarshal(app.Recorder.Body.Bytes(), &response)
+	if report := response.Report; report.Added != 1 || report.Duplicates != 2 || len(report.Errors) != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
@@ -0,0 +1,173 @@
+package api_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/handlers"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/routes"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	"github.com/solomonbaez/hyacinth/api/tracking"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+const readerAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko)"
+
+func TestIsAutomatedAgent(t *testing.T) {
+	testCases := []struct {
+		agent     string
+		automated bool
+	}{
+		{readerAgent, false},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"facebookexternalhit/1.1", true},
+		{"Go-http-client/1.1", true},
+		{"", true},
+	}
+
+	for _, tc := range testCases {
+		if automated := models.IsAutomatedAgent(tc.agent); automated != tc.automated {
+			t.Errorf("Expected %q automated to be %v", tc.agent, tc.automated)
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	signer := signing.NewSigner("tracking-secret", 0)
+	deliveryID := uuid.NewString()
+	preferencesLink := handlers.BaseURL + "/preferences/token"
+	content := `<p><a href="https://example.com/post?id=1">Read</a> <a href="mailto:editor@example.com">Reply</a> <a href="` + preferencesLink + `">Manage</a></p>`
+
+	body := &models.Body{Title: "Issue", Text: "Read https://example.com/post?id=1", Html: content}
+	if e := tracking.Instrument(body, signer, deliveryID, models.Tracking{Opens: true, Clicks: true}); e != nil {
+		t.Fatalf("Failed to instrument body: %s", e)
+	}
+
+	if strings.Contains(body.Html, `href="https://example.com/post`) || !strings.Contains(body.Html, handlers.BaseURL+"/t/c/") {
+		t.Errorf("Expected web links to be rewritten, got: %s", body.Html)
+	}
+	if !strings.Contains(body.Html, "mailto:editor@example.com") || !strings.Contains(body.Html, preferencesLink) {
+		t.Errorf("Expected mailto and preference links to be left alone, got: %s", body.Html)
+	}
+	if !strings.Contains(body.Html, handlers.BaseURL+"/t/o/") {
+		t.Errorf("Expected open pixel, got: %s", body.Html)
+	}
+	if body.Text != "Read https://example.com/post?id=1" {
+		t.Errorf("Expected text body to be untouched, got: %s", body.Text)
+	}
+
+	token := body.Html[strings.Index(body.Html, "/t/c/")+len("/t/c/"):]
+	token = token[:strings.Index(token, `"`)]
+	parsedID, target, e := tracking.ParseClickToken(signer, token)
+	if e != nil || parsedID != deliveryID || target != "https://example.com/post?id=1" {
+		t.Errorf("Expected click token to carry the delivery and target, got: %s, %s, %v", parsedID, target, e)
+	}
+
+	untracked := &models.Body{Title: "Issue", Text: "Read", Html: content}
+	if e := tracking.Instrument(untracked, signer, deliveryID, models.Tracking{}); e != nil || untracked.Html != content {
+		t.Errorf("Expected opted out body to be untouched, got: %s, %v", untracked.Html, e)
+	}
+}
+
+func TestParseClickToken_Forged(t *testing.T) {
+	signer := signing.NewSigner("tracking-secret", 0)
+	deliveryID := uuid.NewString()
+
+	forged := signing.NewSigner("other-secret", 0).Sign(signing.PurposeClick, deliveryID+"\nhttps://example.com")
+	if _, _, e := tracking.ParseClickToken(signer, forged); e == nil {
+		t.Errorf("Expected forged token to be rejected")
+	}
+
+	scripted := signer.Sign(signing.PurposeClick, deliveryID+"\njavascript:alert(1)")
+	if _, _, e := tracking.ParseClickToken(signer, scripted); e == nil {
+		t.Errorf("Expected non web target to be rejected")
+	}
+}
+
+func TestGetClickRedirect(t *testing.T) {
+	deliveryID := uuid.NewString()
+	target := "https://example.com/post?id=1"
+
+	testCases := []struct {
+		name   string
+		agent  string
+		record bool
+	}{
+		{"(+) Test case 1 -> reader click -> records", readerAgent, true},
+		{"(+) Test case 2 -> link scanner -> redirects without recording", "Mozilla/5.0 (compatible; Barracuda Sentinel)", false},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		app.Router.GET("/t/c/:token", func(c *gin.Context) { routes.GetClickRedirect(c, app.DH, app.Signer) })
+
+		if tc.record {
+			app.Database.ExpectExec("INSERT INTO engagement_events").
+				WithArgs(pgxmock.AnyArg(), deliveryID, models.EngagementClick, &target).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		}
+
+		link := tracking.ClickLink(app.Signer, deliveryID, target)
+		request, _ := http.NewRequest("GET", strings.TrimPrefix(link, handlers.BaseURL), nil)
+		request.Header.Set("User-Agent", tc.agent)
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != http.StatusFound {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, http.StatusFound, responseStatus)
+		}
+		if location := app.Recorder.Header().Get("Location"); location != target {
+			t.Errorf("%s: expected redirect to %s, got: %s", tc.name, target, location)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}
+
+func TestGetOpenPixel(t *testing.T) {
+	deliveryID := uuid.NewString()
+
+	app := utils.NewMockApp()
+	app.Router.GET("/t/o/:token", func(c *gin.Context) { routes.GetOpenPixel(c, app.DH, app.Signer) })
+	defer app.Database.Close(app.Context)
+
+	app.Database.ExpectExec("INSERT INTO engagement_events").
+		WithArgs(pgxmock.AnyArg(), deliveryID, models.EngagementOpen, (*string)(nil)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	link := tracking.OpenLink(app.Signer, deliveryID)
+	request, _ := http.NewRequest("GET", strings.TrimPrefix(link, handlers.BaseURL), nil)
+	request.Header.Set("User-Agent", readerAgent)
+	app.NewMockRequest(request)
+
+	if responseStatus := app.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	if contentType := app.Recorder.Header().Get("Content-Type"); contentType != "image/gif" {
+		t.Errorf("Expected a gif, got: %s", contentType)
+	}
+	if e := app.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+
+	// prefetches are served without recording
+	prefetch := utils.NewMockApp()
+	prefetch.Router.GET("/t/o/:token", func(c *gin.Context) { routes.GetOpenPixel(c, prefetch.DH, prefetch.Signer) })
+	request, _ = http.NewRequest("GET", strings.TrimPrefix(link, handlers.BaseURL), nil)
+	request.Header.Set("User-Agent", readerAgent)
+	request.Header.Set("Sec-Purpose", "prefetch")
+	prefetch.NewMockRequest(request)
+
+	if responseStatus := prefetch.Recorder.Code; responseStatus != http.StatusOK {
+		t.Errorf("Expected status code %v, but got %v", http.StatusOK, responseStatus)
+	}
+	if e := prefetch.Database.ExpectationsWereMet(); e != nil {
+		t.Errorf("Unfulfilled expectations: %s", e)
+	}
+}
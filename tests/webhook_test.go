@@ -0,0 +1,192 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v3"
+
+	"github.com/solomonbaez/hyacinth/api/bounces"
+	"github.com/solomonbaez/hyacinth/api/models"
+	"github.com/solomonbaez/hyacinth/api/routes"
+	"github.com/solomonbaez/hyacinth/api/signing"
+	"github.com/solomonbaez/hyacinth/api/webhooks"
+	utils "github.com/solomonbaez/hyacinth/test_utils"
+)
+
+var testWebhookSigner = signing.NewSigner("webhook-secret", 0)
+
+func testARF(feedbackType string, recipient string, returnPath string) string {
+	return "From: abuse@mx.example.org\r\n" +
+		"Message-ID: <arf-1@mx.example.org>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=feedback-report; boundary=\"BOUNDARY\"\r\n" +
+		"\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"This is an email abuse report.\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: message/feedback-report\r\n" +
+		"\r\n" +
+		"Feedback-Type: " + feedbackType + "\r\n" +
+		"User-Agent: ExampleFBL/1.0\r\n" +
+		"Version: 1\r\n" +
+		"Original-Rcpt-To: " + recipient + "\r\n" +
+		"\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: text/rfc822-headers\r\n" +
+		"\r\n" +
+		"Return-Path: <" + returnPath + ">\r\n" +
+		"To: " + recipient + "\r\n" +
+		"Subject: newsletter\r\n" +
+		"\r\n" +
+		"--BOUNDARY--\r\n"
+}
+
+func TestParseMailEvent(t *testing.T) {
+	testCases := []struct {
+		name          string
+		event         models.MailEvent
+		expectedError bool
+	}{
+		{"(+) Test case 1 -> hard bounce", models.MailEvent{ID: "1", Type: "bounce", Email: "user@example.com", Bounce: "hard"}, false},
+		{"(+) Test case 2 -> complaint by delivery", models.MailEvent{ID: "2", Type: "Complaint", DeliveryID: uuid.NewString()}, false},
+		{"(-) Test case 3 -> missing id", models.MailEvent{Type: "bounce", Email: "user@example.com", Bounce: "hard"}, true},
+		{"(-) Test case 4 -> unknown type", models.MailEvent{ID: "4", Type: "open", Email: "user@example.com"}, true},
+		{"(-) Test case 5 -> bounce without kind", models.MailEvent{ID: "5", Type: "bounce", Email: "user@example.com"}, true},
+		{"(-) Test case 6 -> no recipient", models.MailEvent{ID: "6", Type: "complaint"}, true},
+		{"(-) Test case 7 -> invalid delivery", models.MailEvent{ID: "7", Type: "complaint", DeliveryID: "delivery"}, true},
+	}
+
+	for _, tc := range testCases {
+		if e := models.ParseMailEvent(&tc.event); (e != nil) != tc.expectedError {
+			t.Errorf("%s: unexpected error: %v", tc.name, e)
+		}
+	}
+}
+
+func TestParseARF(t *testing.T) {
+	app := utils.NewMockApp()
+	verp := bounces.NewVERP(testBounceSettings(), app.Signer)
+	deliveryID := uuid.NewString()
+
+	event, e := webhooks.ParseARF(strings.NewReader(testARF("abuse", "user@example.com", "sender@example.com")), verp)
+	if e != nil || event.Type != models.MailEventComplaint || event.Email != "user@example.com" || event.ID != "<arf-1@mx.example.org>" {
+		t.Errorf("Unexpected complaint: %+v, %v", event, e)
+	}
+
+	event, e = webhooks.ParseARF(strings.NewReader(testARF("abuse", "redacted", verp.Address(deliveryID).String())), verp)
+	if e != nil || event.Email != "" || event.DeliveryID != deliveryID {
+		t.Errorf("Expected redacted complaint to be attributed to the delivery: %+v, %v", event, e)
+	}
+
+	if _, e := webhooks.ParseARF(strings.NewReader(testARF("not-spam", "user@example.com", "")), verp); !errors.Is(e, webhooks.ErrNotComplaint) {
+		t.Errorf("Expected not-spam report to be skipped, got: %v", e)
+	}
+	if _, e := webhooks.ParseARF(strings.NewReader(testDSN("user@example.com", "failed", "5.1.1")), verp); !errors.Is(e, webhooks.ErrNotARF) {
+		t.Errorf("Expected DSN to be rejected, got: %v", e)
+	}
+}
+
+func TestPostMailEvents(t *testing.T) {
+	testCases := []struct {
+		name              string
+		body              string
+		signed            bool
+		duplicate         bool
+		expectedStatus    int
+		expectedProcessed int
+	}{
+		{"(+) Test case 1 -> hard bounce -> bounced and suppressed", `{"id": "evt-1", "type": "bounce", "email": "user@example.com", "bounce": "hard", "status": "5.1.1"}`, true, false, http.StatusOK, 1},
+		{"(+) Test case 2 -> complaint -> suppressed", `[{"id": "evt-2", "type": "complaint", "email": "user@example.com"}]`, true, false, http.StatusOK, 1},
+		{"(+) Test case 3 -> replayed event -> skipped", `{"id": "evt-1", "type": "bounce", "email": "user@example.com", "bounce": "hard"}`, true, true, http.StatusOK, 0},
+		{"(-) Test case 4 -> unsigned -> rejected", `{"id": "evt-1", "type": "bounce", "email": "user@example.com", "bounce": "hard"}`, false, false, http.StatusUnauthorized, 0},
+		{"(-) Test case 5 -> invalid event -> rejected", `{"id": "evt-5", "type": "open", "email": "user@example.com"}`, true, false, http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range testCases {
+		app := utils.NewMockApp()
+		settings := testBounceSettings()
+		app.Router.POST("/webhooks/mail-events", func(c *gin.Context) {
+			routes.PostMailEvents(c, app.DH, testWebhookSigner, settings, nil)
+		})
+
+		email := "user@example.com"
+		if tc.expectedStatus == http.StatusOK {
+			result := pgxmock.NewResult("INSERT", 1)
+			if tc.duplicate {
+				result = pgxmock.NewResult("INSERT", 0)
+			}
+			eventType := "bounce"
+			if strings.Contains(tc.body, "complaint") {
+				eventType = "complaint"
+			}
+
+			app.Database.ExpectBegin()
+			app.Database.ExpectExec("INSERT INTO mail_events").
+				WithArgs(pgxmock.AnyArg(), eventType).
+				WillReturnResult(result)
+			switch {
+			case tc.duplicate:
+			case eventType == "complaint":
+				hash := models.HashEmail(models.SubscriberEmail(email))
+				app.Database.ExpectExec("INSERT INTO suppressions").
+					WithArgs(pgxmock.AnyArg(), &email, &hash, (*string)(nil), "spam complaint", models.SuppressionSourceWebhook).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				app.Database.ExpectExec("UPDATE subscriptions SET status = 'suppressed'").
+					WithArgs(email).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				app.Database.ExpectExec("DELETE FROM issue_delivery_queue").
+					WithArgs(email).
+					WillReturnResult(pgxmock.NewResult("DELETE", 0))
+			default:
+				app.Database.ExpectExec("INSERT INTO bounces").
+					WithArgs(pgxmock.AnyArg(), "evt-1", (*string)(nil), (*string)(nil), (*string)(nil), email, models.BounceKindHard, "5.1.1", "").
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				app.Database.ExpectQuery("SELECT (.+) FROM bounces").
+					WithArgs(email, settings.SoftWindow).
+					WillReturnRows(pgxmock.NewRows([]string{"hard", "soft"}).AddRow(1, 0))
+				app.Database.ExpectExec("UPDATE subscriptions SET status = 'bounced'").
+					WithArgs(email).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				app.Database.ExpectExec("DELETE FROM issue_delivery_queue").
+					WithArgs(email).
+					WillReturnResult(pgxmock.NewResult("DELETE", 0))
+				hash := models.HashEmail(models.SubscriberEmail(email))
+				app.Database.ExpectExec("INSERT INTO suppressions").
+					WithArgs(pgxmock.AnyArg(), &email, &hash, (*string)(nil), "hard bounce", models.SuppressionSourceWebhook).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			}
+			app.Database.ExpectCommit()
+		}
+
+		request, _ := http.NewRequest("POST", "/webhooks/mail-events", bytes.NewBufferString(tc.body))
+		request.Header.Set("Content-Type", "application/json")
+		if tc.signed {
+			request.Header.Set(routes.SignatureHeader, "sha256="+testWebhookSigner.Digest([]byte(tc.body)))
+		}
+		app.NewMockRequest(request)
+
+		if responseStatus := app.Recorder.Code; responseStatus != tc.expectedStatus {
+			t.Errorf("%s: expected status code %v, but got %v", tc.name, tc.expectedStatus, responseStatus)
+		}
+
+		var response struct {
+			Processed int `json:"processed"`
+		}
+		json.Unmarshal(app.Recorder.Body.Bytes(), &response)
+		if response.Processed != tc.expectedProcessed {
+			t.Errorf("%s: expected %d processed events, got: %d", tc.name, tc.expectedProcessed, response.Processed)
+		}
+		if e := app.Database.ExpectationsWereMet(); e != nil {
+			t.Errorf("%s: unfulfilled expectations: %s", tc.name, e)
+		}
+	}
+}